@@ -9,7 +9,6 @@ import (
 	"math/rand/v2"
 	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -33,17 +32,15 @@ type ValidRound struct {
 }
 
 // GetRound fetches and processes data from external sources.
-// It now returns an error to be handled by the caller.
+// Both feeds are served from the shared cache in feedcache.go; upstream is only hit on a miss.
 func GetRound(roundType string) ([]ValidRound, error) {
-	roundTime := strconv.FormatInt(time.Now().Unix(), 10)
-
-	products, err := GetAllProducts(roundTime)
+	products, err := shopProductsFeed.get()
 	if err != nil {
 		// Propagate the error instead of just logging
 		return nil, fmt.Errorf("GetAllProducts failed: %w", err)
 	}
 
-	rounds, err := GetOnlyRound(roundTime)
+	rounds, err := shopRoundsFeed.get()
 	if err != nil {
 		// Propagate the error
 		return nil, fmt.Errorf("GetOnlyRound failed: %w", err)
@@ -60,7 +57,7 @@ func GetRound(roundType string) ([]ValidRound, error) {
 
 // GetAllProducts fetches product data from the remote URL.
 func GetAllProducts(roundTime string) (map[string]map[string]any, error) {
-	req, err := http.NewRequest("GET", productsUrl+"?"+roundTime, nil)
+	req, err := http.NewRequest("GET", currentConfig().Upstream.ProductsURL+"?"+roundTime, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -91,7 +88,7 @@ func GetAllProducts(roundTime string) (map[string]map[string]any, error) {
 
 // GetOnlyRound fetches round data from the remote URL.
 func GetOnlyRound(roundTime string) ([]ProductRound, error) {
-	req, err := http.NewRequest("GET", roundUrl+"?"+roundTime, nil)
+	req, err := http.NewRequest("GET", currentConfig().Upstream.RoundURL+"?"+roundTime, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
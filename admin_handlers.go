@@ -0,0 +1,614 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// --- 管理接口 ---
+// 所有对 Key 的修改都经由 updateKeyFields / deleteKeyFields，保证各实例的 Key 缓存立即失效
+
+// getKeyHandler 返回 Key 在 Redis 中的原始字段以及最终生效的权限（不经过缓存）
+func getKeyHandler(c *gin.Context) {
+	key := c.Param("key")
+	fields, err := swordRdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load key"})
+		return
+	}
+	if len(fields) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Key not found"})
+		return
+	}
+
+	record := newKeyRecord(key, fields)
+	granted := []permission{}
+	for _, p := range allPermissions {
+		if record.Has(p) {
+			granted = append(granted, p)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"fields": fields, "roles": record.Roles(), "permissions": granted})
+}
+
+// updateKeyPermissionsHandler 授予/撤销权限并可整体替换角色列表
+// 撤销时字段置为 "false" 而不是删除，保持 /authenticate 的 X-Def 校验与历史数据一致
+func updateKeyPermissionsHandler(c *gin.Context) {
+	var req struct {
+		Grant  []permission `json:"grant"`
+		Revoke []permission `json:"revoke"`
+		Roles  *[]string    `json:"roles"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	fields := map[string]any{}
+	for _, p := range req.Grant {
+		if !slices.Contains(allPermissions, p) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + string(p)})
+			return
+		}
+		fields[string(p)] = "true"
+	}
+	for _, p := range req.Revoke {
+		if !slices.Contains(allPermissions, p) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + string(p)})
+			return
+		}
+		fields[string(p)] = "false"
+	}
+	if req.Roles != nil {
+		for _, role := range *req.Roles {
+			if _, ok := roleBundles[role]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + role})
+				return
+			}
+		}
+		fields["roles"] = strings.Join(*req.Roles, ",")
+	}
+	if len(fields) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	key, ok := existingAdminKey(c)
+	if !ok {
+		return
+	}
+	if err := updateKeyFields(key, fields); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update key"})
+		return
+	}
+	log.Printf("管理员修改了 Key '%s' 的权限: %v", key, fields)
+	c.JSON(http.StatusOK, gin.H{"updated": fields})
+}
+
+// banKeyHandler 封禁 Key，已签发的 JWT 在下一次请求时即被拒绝
+// ?mode=shadow 改为影子封禁：Key 照常可用但只能拿到诱饵数据，请求记录可通过 shadow-log 查看
+func banKeyHandler(c *gin.Context) {
+	status := keyStatusBanned
+	switch c.Query("mode") {
+	case "":
+	case "shadow":
+		status = keyStatusShadowBanned
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown ban mode: " + c.Query("mode")})
+		return
+	}
+
+	key, ok := existingAdminKey(c)
+	if !ok {
+		return
+	}
+	if err := updateKeyFields(key, "status", status); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ban key"})
+		return
+	}
+	log.Printf("管理员将 Key '%s' 的状态设置为 %s", key, status)
+	logSecurityEvent("key_"+status, "", key, "set by admin")
+	c.JSON(http.StatusOK, gin.H{"status": status})
+}
+
+// unbanKeyHandler 解封 Key
+func unbanKeyHandler(c *gin.Context) {
+	key, ok := existingAdminKey(c)
+	if !ok {
+		return
+	}
+	if err := deleteKeyFields(key, "status"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unban key"})
+		return
+	}
+	log.Printf("管理员解封了 Key '%s'", key)
+	c.JSON(http.StatusOK, gin.H{"status": "active"})
+}
+
+// getShadowLogHandler 返回影子封禁 Key 最近的请求记录
+func getShadowLogHandler(c *gin.Context) {
+	key, ok := existingAdminKey(c)
+	if !ok {
+		return
+	}
+	entries, err := shadowLog(key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shadow log"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"key": key, "requests": entries})
+}
+
+// identifyWatermarkHandler 根据泄露的配置样本找出下发它的 Key
+// 请求体为 {"sample": "..."}，样本可以是抓包内容、第三方工具的配置文件等任意文本
+func identifyWatermarkHandler(c *gin.Context) {
+	var req struct {
+		Sample string `json:"sample"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Sample == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing sample"})
+		return
+	}
+	matches, err := identifyWatermark(req.Sample)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to identify watermark"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"matches": matches})
+}
+
+// getGatewayTargetsHandler 列出当前生效的网关目标
+func getGatewayTargetsHandler(c *gin.Context) {
+	registry := currentGatewayRegistry()
+	c.JSON(http.StatusOK, gin.H{
+		"source":    registry.source,
+		"loaded_at": registry.loadedAt.Format(time.RFC3339),
+		"targets":   registry.summary(),
+	})
+}
+
+// reloadGatewayTargetsHandler 立即重新加载 GATEWAY_TARGETS_FILE
+func reloadGatewayTargetsHandler(c *gin.Context) {
+	if err := reloadGatewayTargets(); err != nil {
+		log.Printf("管理员重新加载网关目标失败: %v", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to reload gateway targets: " + err.Error()})
+		return
+	}
+	registry := currentGatewayRegistry()
+	c.JSON(http.StatusOK, gin.H{"source": registry.source, "loaded_at": registry.loadedAt.Format(time.RFC3339)})
+}
+
+// getConfigHandler 当前生效的配置（密码与密钥已脱敏）、来源与被环境变量覆盖的项
+func getConfigHandler(c *gin.Context) {
+	loaded := activeConfig.Load()
+	c.JSON(http.StatusOK, gin.H{
+		"source":          loaded.source,
+		"loaded_at":       loaded.loadedAt.Format(time.RFC3339),
+		"env_overrides":   loaded.envOverrides,
+		"restart_pending": loaded.restartPending,
+		"config":          loaded.cfg.redacted(),
+	})
+}
+
+// reloadConfigHandler 立即重新加载配置，与向进程发送 SIGHUP 相同
+func reloadConfigHandler(c *gin.Context) {
+	loaded, err := reloadConfig()
+	if err != nil {
+		log.Printf("管理员重新加载配置失败: %v", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to reload config: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"source":          loaded.source,
+		"loaded_at":       loaded.loadedAt.Format(time.RFC3339),
+		"restart_pending": loaded.restartPending,
+	})
+}
+
+// listShopProductsHandler 列出商品目录，参数 category 可选；active 表示当前是否会下发
+func listShopProductsHandler(c *gin.Context) {
+	products, err := listShopProducts(c.Query("category"), false)
+	if err != nil {
+		log.Printf("查询商品目录失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shop products"})
+		return
+	}
+	now := time.Now()
+	items := make([]gin.H, len(products))
+	for i, p := range products {
+		items[i] = gin.H{"product": p, "active": p.activeAt(now)}
+	}
+	c.JSON(http.StatusOK, gin.H{"products": items})
+}
+
+// createShopProductHandler 新增商品
+func createShopProductHandler(c *gin.Context) {
+	var in shopProductInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	product, err := in.product()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err = insertShopProduct(product)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A product with the same name or product_id already exists in this category"})
+		return
+	}
+	if err != nil {
+		log.Printf("新增商品失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save shop product"})
+		return
+	}
+	invalidateShopCatalog()
+	log.Printf("管理员新增商品 %d: %s/%s", product.ID, product.Category, product.Name)
+	c.JSON(http.StatusCreated, product)
+}
+
+// updateShopProductHandler 以请求体替换商品的全部可编辑字段
+func updateShopProductHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product id"})
+		return
+	}
+	var in shopProductInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	product, err := in.product()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	product.ID = id
+
+	product, err = updateShopProduct(product)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop product not found"})
+		return
+	case isUniqueViolation(err):
+		c.JSON(http.StatusConflict, gin.H{"error": "A product with the same name or product_id already exists in this category"})
+		return
+	case err != nil:
+		log.Printf("更新商品 %d 失败: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save shop product"})
+		return
+	}
+	invalidateShopCatalog()
+	log.Printf("管理员更新商品 %d: %s/%s", product.ID, product.Category, product.Name)
+	c.JSON(http.StatusOK, product)
+}
+
+// deleteShopProductHandler 删除商品
+func deleteShopProductHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product id"})
+		return
+	}
+	found, err := deleteShopProduct(id)
+	if err != nil {
+		log.Printf("删除商品 %d 失败: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete shop product"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop product not found"})
+		return
+	}
+	invalidateShopCatalog()
+	log.Printf("管理员删除商品 %d", id)
+	c.JSON(http.StatusOK, gin.H{"message": "Shop product deleted"})
+}
+
+// discoverShopProductsHandler 从 products.js 中查找名称匹配 pattern 的新商品
+// 请求体 {"pattern": "Q币", "category": "pp", "apply": false}，pattern 与 category 默认取 SHOP_DISCOVERY_*；
+// apply 为 true 时以停用状态写入，否则只返回候选
+func discoverShopProductsHandler(c *gin.Context) {
+	cfg := currentConfig().Shop
+	req := struct {
+		Pattern  string `json:"pattern"`
+		Category string `json:"category"`
+		Apply    bool   `json:"apply"`
+	}{Pattern: cfg.DiscoveryPattern, Category: cfg.DiscoveryCategory}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+	if req.Pattern == "" || req.Category == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pattern and category are required"})
+		return
+	}
+	pattern, err := regexp.Compile(req.Pattern)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pattern: " + err.Error()})
+		return
+	}
+
+	found, err := discoverShopProducts(pattern, req.Category)
+	if err != nil {
+		log.Printf("发现商品失败: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to discover shop products"})
+		return
+	}
+	var inserted int64
+	if req.Apply {
+		if inserted, err = insertShopProductsIfAbsent(found); err != nil {
+			log.Printf("写入发现的商品失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save shop products"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"candidates": found, "inserted": inserted})
+}
+
+// refreshShopFeedsHandler 立即比较一次商店数据，返回各数据记录的变更数
+func refreshShopFeedsHandler(c *gin.Context) {
+	recorded, err := runShopFeedRefresh()
+	if err != nil {
+		log.Printf("刷新商店数据失败: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to refresh shop feeds: " + err.Error(), "recorded": recorded})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recorded": recorded})
+}
+
+// getTemplatesHandler 列出当前生效的报告模板及其版本
+func getTemplatesHandler(c *gin.Context) {
+	store := currentTemplateStore()
+	c.JSON(http.StatusOK, gin.H{
+		"dir":       store.dir,
+		"loaded_at": store.loadedAt.Format(time.RFC3339),
+		"templates": store.summary(),
+	})
+}
+
+// reloadTemplatesHandler 立即重新加载 TEMPLATES_DIR 中的模板
+func reloadTemplatesHandler(c *gin.Context) {
+	if err := reloadTemplates(); err != nil {
+		log.Printf("管理员重新加载报告模板失败: %v", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to reload templates: " + err.Error()})
+		return
+	}
+	store := currentTemplateStore()
+	c.JSON(http.StatusOK, gin.H{"loaded_at": store.loadedAt.Format(time.RFC3339), "templates": store.summary()})
+}
+
+// updateKeyLocaleHandler 设置 Key 的语言偏好，请求体为 {"locale": "en"}，locale 为空时清除偏好
+// 偏好优先于请求的 Accept-Language，用于菜单文案与错误消息
+func updateKeyLocaleHandler(c *gin.Context) {
+	var req struct {
+		Locale string `json:"locale"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	locale := strings.TrimSpace(req.Locale)
+	if _, ok := matchLocale(messageLocales(), []string{locale}); locale != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale: " + locale})
+		return
+	}
+
+	key, ok := existingAdminKey(c)
+	if !ok {
+		return
+	}
+	var err error
+	if locale == "" {
+		err = deleteKeyFields(key, keyLocaleField)
+	} else {
+		err = updateKeyFields(key, keyLocaleField, locale)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update key"})
+		return
+	}
+	log.Printf("管理员将 Key '%s' 的语言偏好设置为 '%s'", key, locale)
+	c.JSON(http.StatusOK, gin.H{"key": key, "locale": locale})
+}
+
+// getMenusHandler 返回当前生效的菜单定义与功能开关
+func getMenusHandler(c *gin.Context) {
+	store := currentMenuStore()
+	c.JSON(http.StatusOK, gin.H{
+		"source":    store.source,
+		"loaded_at": store.loadedAt.Format(time.RFC3339),
+		"flags":     store.flags,
+		"menus":     store.menus,
+	})
+}
+
+// reloadMenusHandler 立即重新加载 MENUS_FILE，校验失败时继续使用当前菜单
+func reloadMenusHandler(c *gin.Context) {
+	if err := reloadMenus(); err != nil {
+		log.Printf("管理员重新加载客户端菜单失败: %v", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to reload menus: " + err.Error()})
+		return
+	}
+	store := currentMenuStore()
+	c.JSON(http.StatusOK, gin.H{"source": store.source, "loaded_at": store.loadedAt.Format(time.RFC3339)})
+}
+
+// previewMenusHandler 预览某个 Key 看到的菜单树，参数 key 必填，lang 可选（默认使用请求的 Accept-Language），
+// menu 可选（默认 main）
+func previewMenusHandler(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing key"})
+		return
+	}
+	store := currentMenuStore()
+	menu := c.DefaultQuery("menu", "main")
+	if _, ok := store.menus[menu]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
+		return
+	}
+	record, err := loadKeyRecord(key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load key"})
+		return
+	}
+	if !record.Exists() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Key not found"})
+		return
+	}
+	prefs := preferredLocales(c)
+	if lang := c.Query("lang"); lang != "" {
+		prefs = []string{lang}
+	}
+	c.JSON(http.StatusOK, gin.H{"key": key, "menu": menu, "items": store.tree(menu, record, prefs)})
+}
+
+// updateKeyFlagsHandler 为单个 Key 开启或关闭功能开关，请求体为 {"enable": [...], "disable": [...], "reset": [...]}
+// reset 中的开关恢复为菜单文件中的默认状态
+func updateKeyFlagsHandler(c *gin.Context) {
+	var req struct {
+		Enable  []string `json:"enable"`
+		Disable []string `json:"disable"`
+		Reset   []string `json:"reset"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if len(req.Enable)+len(req.Disable)+len(req.Reset) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	store := currentMenuStore()
+	fields := map[string]string{}
+	reset := []string{}
+	for _, group := range []struct {
+		names []string
+		value string
+	}{{req.Enable, "true"}, {req.Disable, "false"}, {req.Reset, ""}} {
+		for _, name := range group.names {
+			if _, ok := store.flags[name]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown flag: " + name})
+				return
+			}
+			field := keyFlagFieldPrefix + name
+			if _, ok := fields[field]; ok || slices.Contains(reset, field) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Flag listed more than once: " + name})
+				return
+			}
+			if group.value == "" {
+				reset = append(reset, field)
+			} else {
+				fields[field] = group.value
+			}
+		}
+	}
+
+	key, ok := existingAdminKey(c)
+	if !ok {
+		return
+	}
+	if len(fields) > 0 {
+		if err := updateKeyFields(key, fields); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update key"})
+			return
+		}
+	}
+	if len(reset) > 0 {
+		if err := deleteKeyFields(key, reset...); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update key"})
+			return
+		}
+	}
+	log.Printf("管理员修改了 Key '%s' 的功能开关: 设置 %v，恢复默认 %v", key, fields, reset)
+	c.JSON(http.StatusOK, gin.H{"updated": fields, "reset": reset})
+}
+
+// existingAdminKey 读取路径中的 Key 并确认其存在，避免 HSet 意外创建新 Key
+func existingAdminKey(c *gin.Context) (string, bool) {
+	key := c.Param("key")
+	n, err := swordRdb.Exists(ctx, key).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load key"})
+		return "", false
+	}
+	if n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Key not found"})
+		return "", false
+	}
+	return key, true
+}
+
+// getUsageReportHandler 查询用量报表，参数 from/to 为 YYYY-MM-DD（默认最近 7 天），key 可选
+// 数据来自 usage_daily，今天的用量以最近一次汇总为准
+func getUsageReportHandler(c *gin.Context) {
+	now := time.Now()
+	from := c.DefaultQuery("from", now.AddDate(0, 0, -7).Format(usageDateLayout))
+	to := c.DefaultQuery("to", now.Format(usageDateLayout))
+	for _, day := range []string{from, to} {
+		if _, err := time.Parse(usageDateLayout, day); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+
+	records, err := getUsageDaily(c.Query("key"), from, to)
+	if err != nil {
+		log.Printf("查询用量报表失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load usage"})
+		return
+	}
+
+	totals := make(map[string]int64)
+	for _, r := range records {
+		totals[r.Feature] += r.Count
+	}
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "totals": totals, "records": records})
+}
+
+// getSecurityEventsHandler 返回最近的安全事件，参数 limit 默认 100，最多 securityEventsMax
+func getSecurityEventsHandler(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit <= 0 || limit > securityEventsMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	events, err := recentSecurityEvents(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load security events"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"strict_mode": authStrictMode(), "events": events})
+}
+
+// unlockAuthHandler 解除某个 IP 及其所在网段的认证锁定，并清零锁定等级
+func unlockAuthHandler(c *gin.Context) {
+	ip := c.Param("ip")
+	var keys []string
+	for _, s := range authScopes(ip, false) {
+		keys = append(keys, s.failKey(), s.lockKey(), s.levelKey())
+	}
+	if err := swordRdb.Del(ctx, keys...).Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock"})
+		return
+	}
+	logSecurityEvent("auth_unlock", ip, "", "unlocked by admin")
+	c.JSON(http.StatusOK, gin.H{"unlocked": ip, "subnet": clientSubnet(ip)})
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"path"
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- 按客户端版本轮换的别名 ---
+//
+// 网关目标、子目标参数与 aliasedRoutes 中的路由对每个客户端版本都有不同的别名：
+//
+//	别名 = hex(HMAC(TARGET_ALIAS_SECRET, 版本 + ":" + 规范名称)) 的前若干位
+//
+// 规范名称形如 target:lucy/point、param:lucy/point/of、route:/api/v1/5a39...。
+// 客户端构建时从 GET /admin/aliases?version=<版本> 导出别名表内置到客户端，并通过 X-Client-Version 上报版本。
+// 只有 SUPPORTED_CLIENT_VERSIONS 中的版本的别名有效，下线一个版本后它的别名随即失效。
+// CLIENT_ALIAS_MODE：
+//   - off: 只接受规范名称；
+//   - optional（默认）: 受支持的版本可以使用别名，规范名称仍然有效，便于旧客户端过渡；
+//   - required: 必须携带受支持的版本并使用别名，否则返回 426 或 404。
+
+const (
+	clientAliasModeOff      = "off"
+	clientAliasModeOptional = "optional"
+	clientAliasModeRequired = "required"
+
+	clientVersionHeader = "X-Client-Version"
+
+	targetAliasLength = 12
+	paramAliasLength  = 8
+	routeAliasLength  = 32
+)
+
+// aliasedRoutes 有别名的路由，别名与原路由位于同一目录下
+var aliasedRoutes = []string{
+	"/api/v1/5a3919568264927d643a934a51a439e6",
+	"/api/v1/b474528334283249d218771959415853",
+}
+
+// originalPathKey 请求 context 中保存别名路由原始路径的键
+type originalPathKey struct{}
+
+// aliasSecret 别名密钥，未配置 TARGET_ALIAS_SECRET 时由 JWT 密钥派生
+func aliasSecret() []byte {
+	if targetAliasSecret != "" {
+		return []byte(targetAliasSecret)
+	}
+	mac := hmac.New(sha256.New, []byte(jwtSecretKey))
+	mac.Write([]byte("corn-alias-v1"))
+	return mac.Sum(nil)
+}
+
+// deriveAlias 计算规范名称在某个客户端版本下的别名
+func deriveAlias(version, name string, length int) string {
+	mac := hmac.New(sha256.New, aliasSecret())
+	mac.Write([]byte(version + ":" + name))
+	return hex.EncodeToString(mac.Sum(nil))[:length]
+}
+
+func targetAliasName(endpoint, target string) string {
+	return "target:" + endpoint + "/" + target
+}
+
+func paramAliasName(endpoint, target, param string) string {
+	return "param:" + endpoint + "/" + target + "/" + param
+}
+
+func routeAliasName(route string) string {
+	return "route:" + route
+}
+
+// clientVersionSupported 版本是否在 SUPPORTED_CLIENT_VERSIONS 中
+func clientVersionSupported(version string) bool {
+	return version != "" && slices.Contains(currentConfig().Aliases.SupportedVersions, version)
+}
+
+// aliasTable 一个客户端版本的别名表
+type aliasTable struct {
+	registry *gatewayRegistry
+	// aliases 规范名称 -> 别名，管理接口导出给客户端构建使用
+	aliases map[string]string
+	// targets 端点 -> 别名 -> 规范目标名
+	targets map[string]map[string]string
+	// params 端点/目标 -> 别名 -> 规范参数名
+	params map[string]map[string]string
+	// routes 别名路径 -> 规范路径
+	routes map[string]string
+}
+
+// aliasTables 按版本缓存的别名表，网关注册表重新加载后重建
+var aliasTables = struct {
+	mu     sync.Mutex
+	tables map[string]*aliasTable
+}{tables: make(map[string]*aliasTable)}
+
+// currentAliasTable 返回版本的别名表
+func currentAliasTable(version string) *aliasTable {
+	registry := currentGatewayRegistry()
+
+	aliasTables.mu.Lock()
+	defer aliasTables.mu.Unlock()
+	if table, ok := aliasTables.tables[version]; ok && table.registry == registry {
+		return table
+	}
+	table := buildAliasTable(registry, version)
+	aliasTables.tables[version] = table
+	return table
+}
+
+func buildAliasTable(registry *gatewayRegistry, version string) *aliasTable {
+	table := &aliasTable{
+		registry: registry,
+		aliases:  make(map[string]string),
+		targets:  make(map[string]map[string]string),
+		params:   make(map[string]map[string]string),
+		routes:   make(map[string]string),
+	}
+	for endpoint, targets := range registry.targets {
+		table.targets[endpoint] = make(map[string]string, len(targets))
+		for name, target := range targets {
+			alias := deriveAlias(version, targetAliasName(endpoint, name), targetAliasLength)
+			table.aliases[targetAliasName(endpoint, name)] = alias
+			table.targets[endpoint][alias] = name
+
+			if len(target.Params) == 0 {
+				continue
+			}
+			params := make(map[string]string, len(target.Params))
+			for param := range target.Params {
+				alias := deriveAlias(version, paramAliasName(endpoint, name, param), paramAliasLength)
+				table.aliases[paramAliasName(endpoint, name, param)] = alias
+				params[alias] = param
+			}
+			table.params[endpoint+"/"+name] = params
+		}
+	}
+	for _, route := range aliasedRoutes {
+		alias := path.Join(path.Dir(route), deriveAlias(version, routeAliasName(route), routeAliasLength))
+		table.aliases[routeAliasName(route)] = alias
+		table.routes[alias] = route
+	}
+	return table
+}
+
+// lookup 把别名还原为规范名称
+func (t *aliasTable) lookup(alias string) (string, bool) {
+	for name, a := range t.aliases {
+		if a == alias {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// aliasRouteHandler 在路由匹配之前把别名路由改写为规范路由，原始路径保存在请求 context 中
+func aliasRouteHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentConfig().Aliases.Mode != clientAliasModeOff {
+			if version := r.Header.Get(clientVersionHeader); clientVersionSupported(version) {
+				if route, ok := currentAliasTable(version).routes[r.URL.Path]; ok {
+					original := r.URL.Path
+					r = r.WithContext(context.WithValue(r.Context(), originalPathKey{}, original))
+					u := *r.URL
+					u.Path, u.RawPath = route, ""
+					r.URL = &u
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// originalRequestPath 客户端实际请求的路径；完整性签名与响应签名都基于它计算
+func originalRequestPath(r *http.Request) string {
+	if original, ok := r.Context().Value(originalPathKey{}).(string); ok {
+		return original
+	}
+	return r.URL.Path
+}
+
+// clientVersionMiddleware required 模式下拒绝不受支持的客户端版本，以及直接访问规范路由的请求
+func clientVersionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if currentConfig().Aliases.Mode != clientAliasModeRequired {
+			c.Next()
+			return
+		}
+		if !clientVersionSupported(c.GetHeader(clientVersionHeader)) {
+			respondErrorCode(c, http.StatusUpgradeRequired, msgUpgradeRequired)
+			c.Abort()
+			return
+		}
+		if slices.Contains(aliasedRoutes, c.FullPath()) && originalRequestPath(c.Request) == c.Request.URL.Path {
+			respondErrorCode(c, http.StatusNotFound, msgNotFound)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// resolveGatewayAlias 把请求中的 target 与 p 别名还原为规范名称
+// required 模式下未使用别名时返回 false，调用方按未知目标处理
+func resolveGatewayAlias(c *gin.Context, endpoint string, req *gatewayRequest) bool {
+	mode := currentConfig().Aliases.Mode
+	if mode == clientAliasModeOff {
+		return true
+	}
+	version := c.GetHeader(clientVersionHeader)
+	if !clientVersionSupported(version) {
+		return mode != clientAliasModeRequired
+	}
+
+	table := currentAliasTable(version)
+	target, ok := table.targets[endpoint][req.Target]
+	if ok {
+		req.Target = target
+	} else if mode == clientAliasModeRequired {
+		return false
+	}
+
+	if params, hasParams := table.params[endpoint+"/"+req.Target]; hasParams && req.Param != "" {
+		if param, ok := params[req.Param]; ok {
+			req.Param = param
+		} else if mode == clientAliasModeRequired {
+			return false
+		}
+	}
+	return true
+}
+
+// getAliasesHandler 管理接口：导出某个版本的别名表，或把别名还原为规范名称
+//
+//	GET /admin/aliases?version=1.4.2          该版本全部别名（规范名称 -> 别名）
+//	GET /admin/aliases?alias=3f9c0a1b2c4d     在所有受支持的版本中查找别名
+func getAliasesHandler(c *gin.Context) {
+	aliases := currentConfig().Aliases
+	if alias := c.Query("alias"); alias != "" {
+		versions := aliases.SupportedVersions
+		if v := c.Query("version"); v != "" {
+			versions = []string{v}
+		}
+		var matches []gin.H
+		for _, version := range versions {
+			if name, ok := currentAliasTable(version).lookup(alias); ok {
+				matches = append(matches, gin.H{"version": version, "canonical": name, "supported": clientVersionSupported(version)})
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"alias": alias, "matches": matches})
+		return
+	}
+
+	version := c.Query("version")
+	if version == "" {
+		c.JSON(http.StatusOK, gin.H{"mode": aliases.Mode, "supported_versions": aliases.SupportedVersions})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"version":   version,
+		"supported": clientVersionSupported(version),
+		"mode":      aliases.Mode,
+		"aliases":   currentAliasTable(version).aliases,
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func withAliasConfig(t *testing.T, mode string, versions ...string) {
+	t.Helper()
+	withConfig(t, func(c *appConfig) {
+		c.Aliases.Mode, c.Aliases.SupportedVersions = mode, versions
+	})
+}
+
+func TestAliasesRotatePerVersion(t *testing.T) {
+	a := currentAliasTable("1.0.0").aliases[targetAliasName(gatewayEndpointLucy, "point")]
+	b := currentAliasTable("1.1.0").aliases[targetAliasName(gatewayEndpointLucy, "point")]
+	if a == "" || a == b || len(a) != targetAliasLength {
+		t.Fatalf("aliases for two versions: %q, %q", a, b)
+	}
+	if name, ok := currentAliasTable("1.0.0").lookup(a); !ok || name != "target:lucy/point" {
+		t.Errorf("lookup(%q) = %q, %v", a, name, ok)
+	}
+}
+
+func TestResolveGatewayAlias(t *testing.T) {
+	withAliasConfig(t, clientAliasModeRequired, "1.0.0")
+	table := currentAliasTable("1.0.0")
+
+	newContext := func(version string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/lucy", nil)
+		c.Request.Header.Set(clientVersionHeader, version)
+		return c
+	}
+
+	req := gatewayRequest{
+		Target: table.aliases[targetAliasName(gatewayEndpointLucy, "point")],
+		Param:  table.aliases[paramAliasName(gatewayEndpointLucy, "point", "of")],
+	}
+	if !resolveGatewayAlias(newContext("1.0.0"), gatewayEndpointLucy, &req) || req.Target != "point" || req.Param != "of" {
+		t.Errorf("resolved to %+v", req)
+	}
+
+	// required 模式下规范名称与已下线版本的别名都无效
+	if resolveGatewayAlias(newContext("1.0.0"), gatewayEndpointLucy, &gatewayRequest{Target: "point", Param: "of"}) {
+		t.Error("canonical target accepted in required mode")
+	}
+	stale := gatewayRequest{Target: currentAliasTable("0.9.0").aliases[targetAliasName(gatewayEndpointLucy, "point")]}
+	if resolveGatewayAlias(newContext("0.9.0"), gatewayEndpointLucy, &stale) {
+		t.Error("alias of an unsupported version accepted")
+	}
+
+	withAliasConfig(t, clientAliasModeOptional, "1.0.0")
+	canonical := gatewayRequest{Target: "point", Param: "of"}
+	if !resolveGatewayAlias(newContext("1.0.0"), gatewayEndpointLucy, &canonical) || canonical.Target != "point" {
+		t.Errorf("canonical target rejected in optional mode: %+v", canonical)
+	}
+}
+
+func TestAliasRouteHandlerKeepsOriginalPath(t *testing.T) {
+	withAliasConfig(t, clientAliasModeOptional, "1.0.0")
+	route := aliasedRoutes[0]
+	alias := currentAliasTable("1.0.0").aliases[routeAliasName(route)]
+
+	var fullPath, original string
+	router := gin.New()
+	router.GET(route, func(c *gin.Context) {
+		fullPath, original = c.FullPath(), originalRequestPath(c.Request)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, alias, nil)
+	req.Header.Set(clientVersionHeader, "1.0.0")
+	aliasRouteHandler(router).ServeHTTP(httptest.NewRecorder(), req)
+	if fullPath != route || original != alias {
+		t.Errorf("FullPath = %q, original = %q; want %q, %q", fullPath, original, route, alias)
+	}
+
+	// 未受支持的版本不改写
+	fullPath = ""
+	req = httptest.NewRequest(http.MethodGet, alias, nil)
+	req.Header.Set(clientVersionHeader, "0.9.0")
+	aliasRouteHandler(router).ServeHTTP(httptest.NewRecorder(), req)
+	if fullPath != "" {
+		t.Errorf("alias of an unsupported version was routed to %q", fullPath)
+	}
+}
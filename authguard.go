@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// --- /authenticate 暴力破解防护 ---
+//
+// 按 IP 与网段（IPv4 /24、IPv6 /64）统计窗口期内的认证失败次数，超过阈值即锁定，
+// 同一来源反复被锁定时锁定时长指数增长。全站每分钟失败次数超过 authGlobalThreshold 时
+// 进入严格模式，在 authStrictDuration 内使用更低的阈值。计数存储在 Redis 中，多实例共享。
+
+const (
+	authFailWindow   = 15 * time.Minute
+	authLockBase     = time.Minute
+	authLockMax      = 24 * time.Hour
+	authLockLevelTTL = 24 * time.Hour
+
+	authIPThreshold           = 5
+	authSubnetThreshold       = 20
+	authStrictIPThreshold     = 2
+	authStrictSubnetThreshold = 8
+
+	authGlobalThreshold = 100 // 每分钟
+	authStrictDuration  = 10 * time.Minute
+	authStrictKey       = "authguard:strict"
+)
+
+// authScope 一个失败计数的维度（单个 IP 或网段）
+type authScope struct {
+	kind      string
+	id        string
+	threshold int64
+}
+
+func (s authScope) failKey() string  { return fmt.Sprintf("authfail:%s:%s", s.kind, s.id) }
+func (s authScope) lockKey() string  { return fmt.Sprintf("authlock:%s:%s", s.kind, s.id) }
+func (s authScope) levelKey() string { return fmt.Sprintf("authlocklevel:%s:%s", s.kind, s.id) }
+
+// clientSubnet 返回 IP 所在的 /24（IPv4）或 /64（IPv6）网段
+func clientSubnet(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
+
+func authScopes(ip string, strict bool) []authScope {
+	ipThreshold, subnetThreshold := int64(authIPThreshold), int64(authSubnetThreshold)
+	if strict {
+		ipThreshold, subnetThreshold = authStrictIPThreshold, authStrictSubnetThreshold
+	}
+	return []authScope{
+		{kind: "ip", id: ip, threshold: ipThreshold},
+		{kind: "net", id: clientSubnet(ip), threshold: subnetThreshold},
+	}
+}
+
+// authLockDuration 第 level 次锁定的时长：authLockBase * 2^(level-1)，不超过 authLockMax
+func authLockDuration(level int64) time.Duration {
+	d := authLockBase
+	for i := int64(1); i < level && d < authLockMax; i++ {
+		d *= 2
+	}
+	return min(d, authLockMax)
+}
+
+// authStrictMode 是否处于严格模式
+func authStrictMode() bool {
+	n, err := swordRdb.Exists(ctx, authStrictKey).Result()
+	return err == nil && n > 0
+}
+
+// authLockedOut 返回来源的剩余锁定时长；Redis 出错时不锁定
+func authLockedOut(ip string) (time.Duration, bool) {
+	scopes := authScopes(ip, false)
+	pipe := swordRdb.Pipeline()
+	cmds := make([]*redis.DurationCmd, len(scopes))
+	for i, s := range scopes {
+		cmds[i] = pipe.PTTL(ctx, s.lockKey())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("检查认证锁定状态失败: %v", err)
+		return 0, false
+	}
+
+	var wait time.Duration
+	for _, cmd := range cmds {
+		wait = max(wait, cmd.Val())
+	}
+	return wait, wait > 0
+}
+
+// recordAuthFailure 记录一次认证失败，达到阈值时锁定对应的 IP 或网段
+func recordAuthFailure(ip, reason string) {
+	strict := authStrictMode()
+	scopes := authScopes(ip, strict)
+	globalKey := fmt.Sprintf("authfail:global:%d", time.Now().Unix()/60)
+
+	pipe := swordRdb.Pipeline()
+	counts := make([]*redis.IntCmd, len(scopes))
+	for i, s := range scopes {
+		counts[i] = pipe.Incr(ctx, s.failKey())
+		pipe.Expire(ctx, s.failKey(), authFailWindow)
+	}
+	global := pipe.Incr(ctx, globalKey)
+	pipe.Expire(ctx, globalKey, 2*time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("记录认证失败次数出错: %v", err)
+		return
+	}
+
+	for i, s := range scopes {
+		if counts[i].Val() >= s.threshold {
+			lockAuthScope(s, reason)
+		}
+	}
+
+	maybeEnableChallengeMode(ip, global.Val())
+	if global.Val() >= authGlobalThreshold && !strict {
+		if ok, _ := swordRdb.SetNX(ctx, authStrictKey, "1", authStrictDuration).Result(); ok {
+			logSecurityEvent("auth_strict_mode", ip, "", fmt.Sprintf("%d failures in the last minute, strict mode for %s", global.Val(), authStrictDuration))
+		}
+	}
+}
+
+func lockAuthScope(s authScope, reason string) {
+	pipe := swordRdb.Pipeline()
+	level := pipe.Incr(ctx, s.levelKey())
+	pipe.Expire(ctx, s.levelKey(), authLockLevelTTL)
+	pipe.Del(ctx, s.failKey())
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("锁定 %s %s 失败: %v", s.kind, s.id, err)
+		return
+	}
+
+	duration := authLockDuration(level.Val())
+	if err := swordRdb.Set(ctx, s.lockKey(), reason, duration).Err(); err != nil {
+		log.Printf("锁定 %s %s 失败: %v", s.kind, s.id, err)
+		return
+	}
+	logSecurityEvent("auth_lockout", s.id, "", fmt.Sprintf("%s locked for %s after %d failures (level %d, last reason: %s)", s.kind, duration, s.threshold, level.Val(), reason))
+}
+
+// resetAuthFailures 认证成功后清除该 IP 的失败计数；网段计数保留，避免单个成功请求掩护整个网段
+func resetAuthFailures(ip string) {
+	if err := swordRdb.Del(ctx, authScope{kind: "ip", id: ip}.failKey()).Err(); err != nil {
+		log.Printf("清除认证失败计数出错: %v", err)
+	}
+}
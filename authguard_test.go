@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuthLockDurationAndSubnet(t *testing.T) {
+	cases := map[int64]time.Duration{1: time.Minute, 2: 2 * time.Minute, 5: 16 * time.Minute, 20: authLockMax}
+	for level, want := range cases {
+		if got := authLockDuration(level); got != want {
+			t.Errorf("authLockDuration(%d) = %s, want %s", level, got, want)
+		}
+	}
+
+	subnets := map[string]string{
+		"203.0.113.77":      "203.0.113.0/24",
+		"2001:db8:1:2:3::4": "2001:db8:1:2::/64",
+		"not-an-ip":         "not-an-ip",
+	}
+	for ip, want := range subnets {
+		if got := clientSubnet(ip); got != want {
+			t.Errorf("clientSubnet(%q) = %q, want %q", ip, got, want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/bits"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- 工作量证明挑战 ---
+//
+// 挑战模式开启时，/authenticate 必须携带有效的工作量证明才会查询 Key 与 IP 归属地：
+//  1. 客户端从 GET /challenge 获取签名的挑战（或直接使用 428 响应中附带的挑战）；
+//  2. 寻找 solution 使 sha256(challenge + ":" + solution) 至少有 difficulty 个前导零比特；
+//  3. 通过 X-Challenge / X-Challenge-Solution 请求头提交。
+//
+// 挑战由 HMAC 签名并绑定客户端 IP，无需服务端存储；每个挑战只能使用一次（Redis SETNX）。
+// CHALLENGE_MODE=auto 时，全站每分钟认证失败次数达到 challengeAutoThreshold 后自动开启 challengeAutoDuration。
+
+const (
+	challengeModeOff    = "off"
+	challengeModeAuto   = "auto"
+	challengeModeAlways = "always"
+
+	challengeTTL           = 2 * time.Minute
+	challengeAutoThreshold = 50 // 每分钟
+	challengeAutoDuration  = 10 * time.Minute
+	challengeActiveKey     = "authguard:challenge"
+	// challengeStrictBonus 严格模式下额外增加的难度（比特）
+	challengeStrictBonus = 4
+	// maxChallengeDifficulty 下发的最大难度，与 SDK 的 client.MaxChallengeDifficulty 一致，超过时客户端会放弃求解
+	maxChallengeDifficulty = 28
+	// challengeFlagCacheTTL 本地缓存挑战开关的时间，避免每个请求都查询 Redis
+	challengeFlagCacheTTL = 5 * time.Second
+)
+
+var errChallengeInvalid = errors.New("invalid challenge")
+
+// challengePayload 挑战的签名内容
+type challengePayload struct {
+	Nonce      string `json:"n"`
+	Difficulty int    `json:"d"`
+	ExpiresAt  int64  `json:"e"`
+	IPHash     string `json:"i"`
+}
+
+// challengeFlag 本地缓存的挑战开关状态
+var challengeFlag struct {
+	active    atomic.Bool
+	checkedAt atomic.Int64
+}
+
+// challengeKey 挑战签名密钥，由 JWT 密钥派生，多个实例之间一致
+func challengeKey() []byte {
+	mac := hmac.New(sha256.New, []byte(jwtSecretKey))
+	mac.Write([]byte("corn-challenge-v1"))
+	return mac.Sum(nil)
+}
+
+func hashClientIP(ip string) string {
+	sum := sha256.Sum256([]byte(ip))
+	return hex.EncodeToString(sum[:8])
+}
+
+// challengeModeActive 当前是否要求 /authenticate 提交工作量证明
+func challengeModeActive() bool {
+	switch currentConfig().Challenge.Mode {
+	case challengeModeAlways:
+		return true
+	case challengeModeOff:
+		return false
+	}
+
+	now := time.Now().UnixNano()
+	if now-challengeFlag.checkedAt.Load() < int64(challengeFlagCacheTTL) {
+		return challengeFlag.active.Load()
+	}
+	n, err := swordRdb.Exists(ctx, challengeActiveKey).Result()
+	active := err == nil && n > 0
+	challengeFlag.active.Store(active)
+	challengeFlag.checkedAt.Store(now)
+	return active
+}
+
+// maybeEnableChallengeMode 由 recordAuthFailure 调用，失败率超过阈值时自动开启挑战模式
+func maybeEnableChallengeMode(ip string, failuresLastMinute int64) {
+	if currentConfig().Challenge.Mode != challengeModeAuto || failuresLastMinute < challengeAutoThreshold {
+		return
+	}
+	if ok, _ := swordRdb.SetNX(ctx, challengeActiveKey, "1", challengeAutoDuration).Result(); ok {
+		challengeFlag.checkedAt.Store(0)
+		logSecurityEvent("auth_challenge_mode", ip, "", fmt.Sprintf("%d failures in the last minute, proof-of-work required for %s", failuresLastMinute, challengeAutoDuration))
+	}
+}
+
+// currentChallengeDifficulty 当前难度，严格模式下更高，但不超过 maxChallengeDifficulty
+func currentChallengeDifficulty() int {
+	difficulty := currentConfig().Challenge.Difficulty
+	if authStrictMode() {
+		difficulty += challengeStrictBonus
+	}
+	return min(difficulty, maxChallengeDifficulty)
+}
+
+// issueChallenge 生成绑定客户端 IP 的签名挑战：base64url(payload).base64url(hmac)
+func issueChallenge(ip string, difficulty int) (string, int64, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", 0, err
+	}
+	payload := challengePayload{
+		Nonce:      hex.EncodeToString(nonce),
+		Difficulty: difficulty,
+		ExpiresAt:  time.Now().Add(challengeTTL).Unix(),
+		IPHash:     hashClientIP(ip),
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", 0, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	mac := hmac.New(sha256.New, challengeKey())
+	mac.Write([]byte(encoded))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), payload.ExpiresAt, nil
+}
+
+// leadingZeroBits sha256(challenge + ":" + solution) 的前导零比特数
+func leadingZeroBits(challenge, solution string) int {
+	sum := sha256.Sum256([]byte(challenge + ":" + solution))
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// verifyChallenge 校验签名、有效期、IP 与工作量；最后才访问 Redis 做一次性校验
+func verifyChallenge(challenge, solution, ip string) error {
+	encoded, sig, ok := strings.Cut(challenge, ".")
+	if !ok || solution == "" || len(solution) > 64 {
+		return errChallengeInvalid
+	}
+
+	mac := hmac.New(sha256.New, challengeKey())
+	mac.Write([]byte(encoded))
+	expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return errChallengeInvalid
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return errChallengeInvalid
+	}
+	var payload challengePayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return errChallengeInvalid
+	}
+	if time.Now().Unix() > payload.ExpiresAt {
+		return fmt.Errorf("%w: expired", errChallengeInvalid)
+	}
+	if payload.IPHash != hashClientIP(ip) {
+		return fmt.Errorf("%w: issued to a different client", errChallengeInvalid)
+	}
+	if leadingZeroBits(challenge, solution) < payload.Difficulty {
+		return fmt.Errorf("%w: insufficient work", errChallengeInvalid)
+	}
+
+	ttl := time.Until(time.Unix(payload.ExpiresAt, 0)) + time.Second
+	fresh, err := swordRdb.SetNX(ctx, "challenge:used:"+payload.Nonce, "1", ttl).Result()
+	if err != nil {
+		log.Printf("挑战一次性校验失败，已放行: %v", err)
+		return nil
+	}
+	if !fresh {
+		return fmt.Errorf("%w: already used", errChallengeInvalid)
+	}
+	return nil
+}
+
+// challengeResponse 返回给客户端的挑战
+func challengeResponse(ip string) (gin.H, error) {
+	difficulty := currentChallengeDifficulty()
+	challenge, expiresAt, err := issueChallenge(ip, difficulty)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{"challenge": challenge, "difficulty": difficulty, "expires_at": expiresAt}, nil
+}
+
+// handleChallenge 签发一个新的工作量证明挑战，无需认证
+func handleChallenge(c *gin.Context) {
+	resp, err := challengeResponse(c.ClientIP())
+	if err != nil {
+		respondErrorCode(c, http.StatusInternalServerError, msgChallengeIssueFailed)
+		return
+	}
+	resp["required"] = challengeModeActive()
+	c.JSON(http.StatusOK, resp)
+}
+
+// requireChallenge 挑战模式下校验 /authenticate 的工作量证明；未通过时写出 428 并附带新挑战
+func requireChallenge(c *gin.Context, ip string) bool {
+	if !challengeModeActive() {
+		return true
+	}
+
+	err := verifyChallenge(c.GetHeader("X-Challenge"), c.GetHeader("X-Challenge-Solution"), ip)
+	if err == nil {
+		return true
+	}
+
+	resp, issueErr := challengeResponse(ip)
+	if issueErr != nil {
+		respondErrorCode(c, http.StatusInternalServerError, msgChallengeIssueFailed)
+		return false
+	}
+	prefs := preferredLocales(c)
+	resp["code"], resp["error"] = msgChallengeRequired, msgChallengeRequired.text(prefs)
+	if c.GetHeader("X-Challenge") != "" {
+		reason := strings.TrimPrefix(err.Error(), errChallengeInvalid.Error()+": ")
+		resp["code"], resp["error"] = msgChallengeFailed, msgChallengeFailed.text(prefs, reason)
+	}
+	c.JSON(http.StatusPreconditionRequired, resp)
+	return false
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ElieenAndBella/corn_server/client"
+)
+
+// 以下用例都在访问 Redis 做一次性校验之前就被拒绝
+func TestVerifyChallengeRejectsBeforeRedis(t *testing.T) {
+	challenge, _, err := issueChallenge("198.51.100.7", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solution := ""
+	for i := 0; ; i++ {
+		if leadingZeroBits(challenge, strconv.Itoa(i)) >= 8 {
+			solution = strconv.Itoa(i)
+			break
+		}
+	}
+	weak := ""
+	for i := 0; ; i++ {
+		if leadingZeroBits(challenge, strconv.Itoa(i)) < 8 {
+			weak = strconv.Itoa(i)
+			break
+		}
+	}
+
+	cases := map[string]struct{ challenge, solution, ip string }{
+		"tampered":        {challenge + "x", solution, "198.51.100.7"},
+		"other client":    {challenge, solution, "198.51.100.8"},
+		"not enough work": {challenge, weak, "198.51.100.7"},
+		"missing":         {"", "", "198.51.100.7"},
+	}
+	for name, tc := range cases {
+		if err := verifyChallenge(tc.challenge, tc.solution, tc.ip); !errors.Is(err, errChallengeInvalid) {
+			t.Errorf("%s: verifyChallenge error = %v, want errChallengeInvalid", name, err)
+		}
+	}
+}
+
+// 严格模式下的难度也必须在 SDK 愿意求解的范围内
+func TestChallengeDifficultyWithinClientLimit(t *testing.T) {
+	if maxChallengeDifficulty != client.MaxChallengeDifficulty {
+		t.Errorf("maxChallengeDifficulty = %d, SDK accepts up to %d", maxChallengeDifficulty, client.MaxChallengeDifficulty)
+	}
+	if _, err := loadConfig(writeConfigFile(t, "challenge:\n  difficulty: 24\n")); err != nil {
+		t.Errorf("difficulty 24 rejected: %v", err)
+	}
+	_, err := loadConfig(writeConfigFile(t, "challenge:\n  difficulty: 25\n"))
+	if err == nil || !strings.Contains(err.Error(), "challenge.difficulty") {
+		t.Errorf("difficulty 25 accepted, err = %v", err)
+	}
+}
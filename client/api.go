@@ -0,0 +1,792 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 服务器路由
+const (
+	pathGateway          = "/api/v1/gateway"
+	pathLucy             = "/api/v1/lucy"
+	pathDavid            = "/api/v1/david"
+	pathNextTask         = "/api/v1/5a3919568264927d643a934a51a439e6"
+	pathSubmitTask       = "/api/v1/b474528334283249d218771959415853"
+	pathActivities       = "/api/activities"
+	pathActivitiesAdd    = "/api/activities/add"
+	pathActivitiesGetAll = "/api/activities/getall"
+	pathActivitiesSearch = "/api/activities/search"
+	pathActivitiesSelf   = "/api/activities/getself"
+	pathActivitiesExport = "/api/activities/export"
+	pathApkSubmit        = "/apk/submit"
+	pathApkDownload      = "/apk/download"
+	pathUsage            = "/api/usage"
+	pathReports          = "/api/reports"
+	pathRuns             = "/api/runs"
+	pathRunTrends        = "/api/runs/trends"
+	pathShopChanges      = "/api/shop/changes"
+)
+
+// 转盘类型，对应 Rounds 的 kind 参数
+const (
+	RoundUniversal = "of"
+	RoundWanneng   = "view"
+)
+
+// TargetRequest 网关类接口的请求体
+type TargetRequest struct {
+	Target string   `json:"target"`
+	Param  string   `json:"p,omitempty"`
+	Params []string `json:"params,omitempty"`
+}
+
+// MenuNode 菜单项，Children 为子菜单
+type MenuNode struct {
+	ID       string     `json:"id"`
+	Label    string     `json:"label"`
+	Children []MenuNode `json:"children,omitempty"`
+}
+
+// Round 转盘信息
+type Round struct {
+	Name       string `json:"name"`
+	Url        string `json:"url"`
+	Created    string `json:"created"`
+	IsFinished bool   `json:"is_finished"`
+}
+
+// ShopProduct 商店商品
+type ShopProduct struct {
+	ID string `json:"id"`
+	LK string `json:"lk"`
+}
+
+// LotteryProduct 商店抽奖商品（只包含客户端常用字段）
+type LotteryProduct struct {
+	ID             int    `json:"id"`
+	ProductName    string `json:"product_name"`
+	ProductImg     string `json:"product_img"`
+	JumpURL        string `json:"jump_url"`
+	Headcount      int    `json:"headcount"`
+	PrizeText      string `json:"prize_text"`
+	StartTimestamp int    `json:"start_timestamp"`
+	EndTimestamp   int    `json:"end_timestamp"`
+	PrizeTimestamp int    `json:"prize_timestamp"`
+}
+
+// Item 活动奖品
+type Item struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Amount int    `json:"amount"`
+}
+
+// Activity 服务器返回的活动
+type Activity struct {
+	ActivityID  int       `json:"activity_id"`
+	ArticleID   string    `json:"article_id"`
+	Awards      []Item    `json:"awards"`
+	Conditions  []string  `json:"conditions"`
+	Title       string    `json:"title"`
+	LinkTitle   string    `json:"link_title"`
+	GameName    string    `json:"game_name"`
+	AuthorName  string    `json:"author_name"`
+	Cover       string    `json:"cover"`
+	DrawTime    time.Time `json:"draw_time"`
+	PublishTime time.Time `json:"publish_time"`
+}
+
+// ActivityResult 提交任务时上报的爬取结果，时间格式为 "2006年01月02日 15:04:05"
+type ActivityResult struct {
+	ActivityID  int      `json:"activity_id"`
+	ArticleID   int64    `json:"article_id"`
+	Awards      []Item   `json:"awards"`
+	Conditions  []string `json:"conditions"`
+	Title       string   `json:"title"`
+	LinkTitle   string   `json:"link_title"`
+	GameName    string   `json:"game_name"`
+	AuthorName  string   `json:"author_name"`
+	Cover       string   `json:"cover"`
+	DrawTime    string   `json:"draw_time"`
+	PublishTime string   `json:"publish_time"`
+}
+
+// ApkInfo APK 构建参数
+type ApkInfo struct {
+	AppName       string
+	ApplicationId string
+	VersionCode   string
+	VersionName   string
+}
+
+func (a ApkInfo) query() url.Values {
+	return url.Values{
+		"appName":       {a.AppName},
+		"applicationId": {a.ApplicationId},
+		"versionCode":   {a.VersionCode},
+		"versionName":   {a.VersionName},
+	}
+}
+
+// UsageRecord 某天某个功能的用量
+type UsageRecord struct {
+	Day     string `json:"day"`
+	Feature string `json:"feature"`
+	Count   int64  `json:"count"`
+}
+
+// Usage 当前 Key 的套餐、今日用量、每日配额（未列出的功能不限量）与近 30 天历史
+type Usage struct {
+	Plan    string           `json:"plan"`
+	Today   map[string]int64 `json:"today"`
+	Quotas  map[string]int64 `json:"quotas"`
+	History []UsageRecord    `json:"history"`
+}
+
+// --- 通用网关 ---
+
+// Gateway 调用 /api/v1/gateway 并解密结果到 out
+func (c *Client) Gateway(ctx context.Context, req TargetRequest, out any) error {
+	return c.doEncrypted(ctx, http.MethodPost, pathGateway, nil, c.aliasTarget("gateway", req), out)
+}
+
+// Lucy 调用 /api/v1/lucy 并解密结果到 out
+func (c *Client) Lucy(ctx context.Context, req TargetRequest, out any) error {
+	return c.doEncrypted(ctx, http.MethodPost, pathLucy, nil, c.aliasTarget("lucy", req), out)
+}
+
+// David 调用 /api/v1/david 并解密结果到 out
+func (c *Client) David(ctx context.Context, req TargetRequest, out any) error {
+	return c.doEncrypted(ctx, http.MethodPost, pathDavid, nil, c.aliasTarget("david", req), out)
+}
+
+// aliasTarget 按 Config.Aliases 把规范的目标与参数名替换为当前版本的别名；没有别名时保持原样
+func (c *Client) aliasTarget(endpoint string, req TargetRequest) TargetRequest {
+	if alias, ok := c.cfg.Aliases["param:"+endpoint+"/"+req.Target+"/"+req.Param]; ok && req.Param != "" {
+		req.Param = alias
+	}
+	if alias, ok := c.cfg.Aliases["target:"+endpoint+"/"+req.Target]; ok {
+		req.Target = alias
+	}
+	return req
+}
+
+// aliasPath 按 Config.Aliases 把规范路由替换为当前版本的别名
+func (c *Client) aliasPath(path string) string {
+	if alias, ok := c.cfg.Aliases["route:"+path]; ok {
+		return alias
+	}
+	return path
+}
+
+// --- 网关目标 ---
+
+// MainMenu 主菜单
+func (c *Client) MainMenu(ctx context.Context) ([]string, error) {
+	var menu []string
+	err := c.Gateway(ctx, TargetRequest{Target: "cupboards"}, &menu)
+	return menu, err
+}
+
+// SubMenu 子菜单，module 为子菜单标识，例如 "sign"
+func (c *Client) SubMenu(ctx context.Context, module string) ([]string, error) {
+	var menu []string
+	err := c.Gateway(ctx, TargetRequest{Target: "leave", Param: module}, &menu)
+	return menu, err
+}
+
+// MenuTree 完整菜单树，只包含当前 Key 可用的菜单项；菜单项的 ID 不随文案或语言变化
+func (c *Client) MenuTree(ctx context.Context) ([]MenuNode, error) {
+	var menu []MenuNode
+	err := c.Gateway(ctx, TargetRequest{Target: "drawers"}, &menu)
+	return menu, err
+}
+
+// Rounds 转盘列表，kind 为 RoundUniversal 或 RoundWanneng
+func (c *Client) Rounds(ctx context.Context, kind string) ([]Round, error) {
+	var rounds []Round
+	err := c.Lucy(ctx, TargetRequest{Target: "point", Param: kind}, &rounds)
+	return rounds, err
+}
+
+// RemoteURLs 转盘接口地址，键为 universal / wanneng
+func (c *Client) RemoteURLs(ctx context.Context) (map[string]string, error) {
+	var urls map[string]string
+	err := c.Lucy(ctx, TargetRequest{Target: "evening"}, &urls)
+	return urls, err
+}
+
+// FarmURLs 玉米农场接口地址
+func (c *Client) FarmURLs(ctx context.Context) ([]string, error) {
+	return c.lucyStrings(ctx, "time")
+}
+
+// GameURLs 小游戏接口地址
+func (c *Client) GameURLs(ctx context.Context) ([]string, error) {
+	return c.lucyStrings(ctx, "reason")
+}
+
+// GameParams 小游戏接口参数
+func (c *Client) GameParams(ctx context.Context) ([]string, error) {
+	return c.lucyStrings(ctx, "really")
+}
+
+// ShopURLs 商店下单接口，kind 为 "sd"（实物）或 "gb"（虚拟）
+func (c *Client) ShopURLs(ctx context.Context, kind string) ([]string, error) {
+	return c.lucyStrings(ctx, kind)
+}
+
+// CornFarmKeys 农场页面中需要提取的变量名
+func (c *Client) CornFarmKeys(ctx context.Context) ([]string, error) {
+	return c.lucyStrings(ctx, "compromise")
+}
+
+// VarRegexps 提取页面变量的正则（带引号、不带引号）
+func (c *Client) VarRegexps(ctx context.Context) ([]string, error) {
+	return c.lucyStrings(ctx, "control")
+}
+
+// VarJSONRegexps 提取页面 JSON 变量的正则
+func (c *Client) VarJSONRegexps(ctx context.Context) ([]string, error) {
+	return c.lucyStrings(ctx, "know")
+}
+
+// ExtractRegexp 农场提取正则，module 为 "reading"（comm_id）或 "lines"（s）
+func (c *Client) ExtractRegexp(ctx context.Context, module string) (string, error) {
+	var re string
+	err := c.Lucy(ctx, TargetRequest{Target: "sitting", Param: module}, &re)
+	return re, err
+}
+
+// SecretPair 客户端使用的密钥键值对
+func (c *Client) SecretPair(ctx context.Context) (key, value string, err error) {
+	var pair map[string]string
+	if err := c.Lucy(ctx, TargetRequest{Target: "oh"}, &pair); err != nil {
+		return "", "", err
+	}
+	return pair["key"], pair["value"], nil
+}
+
+// AnotherSecret 另一个客户端密钥字符串
+func (c *Client) AnotherSecret(ctx context.Context) (string, error) {
+	return c.lucyString(ctx, "going")
+}
+
+// ActOnClick 活动点击选择器
+func (c *Client) ActOnClick(ctx context.Context) (string, error) {
+	return c.lucyString(ctx, "stay")
+}
+
+// SortedKeys 服务器将 params 加上 "secret" 后排序返回
+func (c *Client) SortedKeys(ctx context.Context, params []string) ([]string, error) {
+	var keys []string
+	err := c.Lucy(ctx, TargetRequest{Target: "tell", Params: params}, &keys)
+	return keys, err
+}
+
+// ShopProducts 商店商品列表，键为商品名
+func (c *Client) ShopProducts(ctx context.Context) (map[string]ShopProduct, error) {
+	var products map[string]ShopProduct
+	err := c.Lucy(ctx, TargetRequest{Target: "pp"}, &products)
+	return products, err
+}
+
+// cachedTemplate 本地缓存的报告模板及其版本
+type cachedTemplate struct {
+	version string
+	source  string
+}
+
+// ReportTemplate 统计报告的 HTML 模板，name 为 love / face / fade（lucy）或 feature（david）
+// 模板按服务器返回的版本缓存，之后的请求携带 If-None-Match，版本未变化时服务器返回 304 并直接使用缓存
+func (c *Client) ReportTemplate(ctx context.Context, name string) (string, error) {
+	endpoint, path := "lucy", pathLucy
+	if name == "feature" {
+		endpoint, path = "david", pathDavid
+	}
+
+	c.mu.Lock()
+	cached, hasCached := c.templates[name]
+	c.mu.Unlock()
+	var header http.Header
+	if hasCached {
+		header = http.Header{"If-None-Match": {`"` + cached.version + `"`}}
+	}
+
+	resp, err := c.send(ctx, http.MethodPost, path, nil, c.aliasTarget(endpoint, TargetRequest{Target: name}), header)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusNotModified && hasCached {
+		return cached.source, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", parseError(resp.StatusCode, raw, []byte(c.cfg.Key))
+	}
+	plaintext, err := decodeEncryptedResponse(raw, []byte(c.cfg.Key))
+	if err != nil {
+		return "", err
+	}
+	var source string
+	if err := json.Unmarshal(plaintext, &source); err != nil {
+		return "", err
+	}
+
+	if version := resp.Header.Get("X-Template-Version"); version != "" {
+		c.mu.Lock()
+		if c.templates == nil {
+			c.templates = make(map[string]cachedTemplate)
+		}
+		c.templates[name] = cachedTemplate{version: version, source: source}
+		c.mu.Unlock()
+	}
+	return source, nil
+}
+
+// 服务器端报告模板名，对应 RenderReport 的 template 参数
+const (
+	ReportCornFarm = "farm"
+	ReportRound    = "round"
+	ReportMiniGame = "game"
+	ReportLottery  = "lottery"
+)
+
+// ReportUser 报告头部的用户信息，结构与快爆用户信息接口的返回一致
+type ReportUser struct {
+	Result struct {
+		Data struct {
+			BaseInfo struct {
+				Avatar          string
+				NickName        string
+				UserAchievement struct {
+					ShowCollect struct {
+						Icon string
+					}
+				}
+			}
+		}
+	}
+}
+
+// CornFarmReport 玉米农场统计
+type CornFarmReport struct {
+	PopcornTotal  int64
+	TaskNum       int
+	CsdGained     int
+	CornGained    int
+	PopcornGained int
+	GeneratedAt   string
+}
+
+// RoundReward 转盘额外获得的奖品
+type RoundReward struct {
+	Name   string
+	Source string
+}
+
+// RoundReport 转盘统计
+type RoundReport struct {
+	PopcornTotal    int64
+	RoundCount      int
+	RealRoundCount  int
+	PopcornGained   int
+	DrawCount       int
+	AveragePerDraw  float64
+	DurationMinutes int
+	Rewards         []RoundReward
+	GeneratedAt     string
+}
+
+// MiniGameReport 小游戏统计
+type MiniGameReport struct {
+	PopcornTotal  int64
+	GameNum       int
+	JklGained     int
+	CsdGained     int
+	PopcornGained int
+	GeneratedAt   string
+}
+
+// LotteryPrize 获得的奖券
+type LotteryPrize struct {
+	Pic  string
+	Name string
+	Num  int
+}
+
+// LotteryReport 奖券统计
+type LotteryReport struct {
+	LD          []LotteryPrize
+	GeneratedAt string
+}
+
+// ReportData 报告数据，只需填写 User 与模板对应的那一节
+type ReportData struct {
+	User ReportUser
+	Ecd  *CornFarmReport `json:",omitempty"`
+	Ed   *RoundReport    `json:",omitempty"`
+	Egd  *MiniGameReport `json:",omitempty"`
+	Eld  *LotteryReport  `json:",omitempty"`
+}
+
+// Report 服务器渲染并保存的报告
+type Report struct {
+	ID              string    `json:"id"`
+	Template        string    `json:"template"`
+	TemplateVersion string    `json:"template_version"`
+	HTML            string    `json:"html"`
+	URL             string    `json:"url"` // 分享路径，相对于 BaseURL
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// RenderReport 由服务器用最新模板渲染报告并保存，返回的 URL 可以分享给他人查看
+func (c *Client) RenderReport(ctx context.Context, template string, data ReportData) (*Report, error) {
+	var report Report
+	body := map[string]any{"template": template, "data": data}
+	if err := c.doEncrypted(ctx, http.MethodPost, pathReports, nil, body, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ReportURL 报告的完整分享地址
+func (c *Client) ReportURL(report *Report) string {
+	return c.cfg.BaseURL + report.URL
+}
+
+// LotteryProducts 商店进行中的抽奖商品
+func (c *Client) LotteryProducts(ctx context.Context) ([]LotteryProduct, error) {
+	var products []LotteryProduct
+	err := c.David(ctx, TargetRequest{Target: "handshake"}, &products)
+	return products, err
+}
+
+// LotteryTaskNames 商店抽奖任务接口名
+func (c *Client) LotteryTaskNames(ctx context.Context) ([]string, error) {
+	var names []string
+	err := c.David(ctx, TargetRequest{Target: "house"}, &names)
+	return names, err
+}
+
+func (c *Client) lucyStrings(ctx context.Context, target string) ([]string, error) {
+	var values []string
+	err := c.Lucy(ctx, TargetRequest{Target: target}, &values)
+	return values, err
+}
+
+func (c *Client) lucyString(ctx context.Context, target string) (string, error) {
+	var value string
+	err := c.Lucy(ctx, TargetRequest{Target: target}, &value)
+	return value, err
+}
+
+// --- 活动 ---
+
+// Activities 全部活动，status 为 "opened" 或 "closed"
+func (c *Client) Activities(ctx context.Context, status string, limit, offset int) ([]Activity, error) {
+	var activities []Activity
+	err := c.doEncrypted(ctx, http.MethodGet, pathActivities, pageQuery(status, limit, offset), nil, &activities)
+	return activities, err
+}
+
+// SearchActivities 按关键词搜索活动，status 为 "opened" 或 "ended"
+func (c *Client) SearchActivities(ctx context.Context, keyword, status string, limit, offset int) ([]Activity, error) {
+	query := pageQuery(status, limit, offset)
+	query.Set("kw", keyword)
+
+	var activities []Activity
+	err := c.doEncrypted(ctx, http.MethodGet, pathActivitiesSearch, query, nil, &activities)
+	return activities, err
+}
+
+// UserActivities 当前 Key 参与的活动，status 为 "opened" 或 "ended"
+func (c *Client) UserActivities(ctx context.Context, status string, limit, offset int) ([]Activity, error) {
+	var activities []Activity
+	err := c.doEncrypted(ctx, http.MethodGet, pathActivitiesSelf, pageQuery(status, limit, offset), nil, &activities)
+	return activities, err
+}
+
+// AddUserActivity 记录当前 Key 参与了某个活动
+func (c *Client) AddUserActivity(ctx context.Context, activityID int) error {
+	return c.doPlain(ctx, http.MethodGet, pathActivitiesAdd, url.Values{"aid": {strconv.Itoa(activityID)}}, nil, nil)
+}
+
+// UserActivityIDs 当前 Key 参与过的全部活动 ID
+func (c *Client) UserActivityIDs(ctx context.Context) ([]int, error) {
+	var ids []int
+	err := c.doPlain(ctx, http.MethodGet, pathActivitiesGetAll, nil, nil, &ids)
+	return ids, err
+}
+
+// ExportActivities 流式导出全部活动，每解密出一条活动调用一次 fn
+// 流被截断时返回 ErrTruncatedStream，此前已回调的活动可能不完整
+func (c *Client) ExportActivities(ctx context.Context, fn func(Activity) error) error {
+	resp, err := c.send(ctx, http.MethodGet, pathActivitiesExport, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		return parseError(resp.StatusCode, raw, []byte(c.cfg.Key))
+	}
+
+	stream, err := newStreamReader(resp.Body, []byte(c.cfg.Key))
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(stream)
+	for {
+		var act Activity
+		if err := decoder.Decode(&act); err == io.EOF {
+			// 读完剩余数据，使响应签名（位于 Trailer）得到校验
+			_, err = io.Copy(io.Discard, resp.Body)
+			return err
+		} else if err != nil {
+			return err
+		}
+		if err := fn(act); err != nil {
+			return err
+		}
+	}
+}
+
+func pageQuery(status string, limit, offset int) url.Values {
+	return url.Values{
+		"status": {status},
+		"limit":  {strconv.Itoa(limit)},
+		"offset": {strconv.Itoa(offset)},
+	}
+}
+
+// --- 任务 ---
+
+// NextTask 领取一个爬取任务，没有任务时返回空字符串
+func (c *Client) NextTask(ctx context.Context) (string, error) {
+	var resp struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := c.doPlain(ctx, http.MethodGet, pathNextTask, nil, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.TaskID, nil
+}
+
+// SubmitTask 提交任务结果
+func (c *Client) SubmitTask(ctx context.Context, taskID string, result ActivityResult) error {
+	body := struct {
+		TaskID string         `json:"task_id"`
+		Data   ActivityResult `json:"data"`
+	}{TaskID: taskID, Data: result}
+	return c.doPlain(ctx, http.MethodPost, pathSubmitTask, nil, body, nil)
+}
+
+// --- APK ---
+
+// SubmitApkJob 提交构建任务；已有任务时返回其状态（WAITING / BUILDING / SUCCESS / FAILED），新提交时返回空字符串
+func (c *Client) SubmitApkJob(ctx context.Context, info ApkInfo) (string, error) {
+	var resp struct {
+		Status string `json:"status"`
+	}
+	if err := c.doPlain(ctx, http.MethodGet, pathApkSubmit, info.query(), nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+// DownloadApk 下载构建完成的 APK 并写入 w
+func (c *Client) DownloadApk(ctx context.Context, info ApkInfo, w io.Writer) error {
+	resp, err := c.send(ctx, http.MethodGet, pathApkDownload, info.query(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		return parseError(resp.StatusCode, raw, nil)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("client: download apk: %w", err)
+	}
+	return nil
+}
+
+// --- 用量 ---
+
+// Usage 查询当前 Key 的用量与配额
+func (c *Client) Usage(ctx context.Context) (*Usage, error) {
+	var usage Usage
+	if err := c.doEncrypted(ctx, http.MethodGet, pathUsage, nil, nil, &usage); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// --- 运行历史 ---
+
+// 趋势的汇总周期
+const (
+	PeriodDay  = "day"
+	PeriodWeek = "week"
+)
+
+// RunReward 一次运行获得的奖品
+type RunReward struct {
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"`
+	Num    int    `json:"num"`
+}
+
+// Run 服务器记录的一次运行
+type Run struct {
+	ID              int64       `json:"id"`
+	RunID           string      `json:"run_id,omitempty"`
+	Kind            string      `json:"kind"`
+	PopcornTotal    int64       `json:"popcorn_total"`
+	PopcornGained   int         `json:"popcorn_gained"`
+	Tasks           int         `json:"tasks"`
+	Rounds          int         `json:"rounds"`
+	Draws           int         `json:"draws"`
+	Games           int         `json:"games"`
+	DurationMinutes int         `json:"duration_minutes"`
+	Rewards         []RunReward `json:"rewards"`
+	RanAt           time.Time   `json:"ran_at"`
+}
+
+// RunTrendBucket 趋势中的一天或一周，Start 为区间起始日期
+type RunTrendBucket struct {
+	Start          string  `json:"start"`
+	Runs           int64   `json:"runs"`
+	PopcornGained  int64   `json:"popcorn_gained"`
+	PopcornPerRun  float64 `json:"popcorn_per_run"`
+	Draws          int64   `json:"draws"`
+	AveragePerDraw float64 `json:"average_per_draw"`
+	Tasks          int64   `json:"tasks"`
+	Games          int64   `json:"games"`
+	Rewards        int64   `json:"rewards"`
+}
+
+// RewardFrequency 奖品的获得数量，以及获得过它的运行占比
+type RewardFrequency struct {
+	Name      string  `json:"name"`
+	Source    string  `json:"source,omitempty"`
+	Count     int64   `json:"count"`
+	Runs      int64   `json:"runs"`
+	Frequency float64 `json:"frequency"`
+}
+
+// RunTrends 某类运行的趋势
+type RunTrends struct {
+	Kind    string            `json:"kind"`
+	Period  string            `json:"period"`
+	From    string            `json:"from"`
+	To      string            `json:"to"`
+	Buckets []RunTrendBucket  `json:"buckets"`
+	Rewards []RewardFrequency `json:"rewards"`
+}
+
+// SubmitRun 记录一次运行，kind 与报告模板名相同（ReportCornFarm 等），data 只需填写对应的一节
+// runID 由客户端生成，重试时使用同一个值可以避免重复记录；为空时不去重。ranAt 为零值时使用服务器时间
+func (c *Client) SubmitRun(ctx context.Context, kind, runID string, ranAt time.Time, data ReportData) (*Run, error) {
+	body := map[string]any{"run_id": runID, "kind": kind, "data": data}
+	if !ranAt.IsZero() {
+		body["ran_at"] = ranAt
+	}
+	var run Run
+	if err := c.doEncrypted(ctx, http.MethodPost, pathRuns, nil, body, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// Runs 按运行时间倒序分页查询历史，kind 为空时返回所有类型
+func (c *Client) Runs(ctx context.Context, kind string, limit, offset int) ([]Run, error) {
+	query := url.Values{}
+	if kind != "" {
+		query.Set("kind", kind)
+	}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+
+	var runs []Run
+	err := c.doEncrypted(ctx, http.MethodGet, pathRuns, query, nil, &runs)
+	return runs, err
+}
+
+// RunTrends 按天或周汇总某类运行，from/to 为 YYYY-MM-DD，为空时使用服务器默认区间
+func (c *Client) RunTrends(ctx context.Context, kind, period, from, to string) (*RunTrends, error) {
+	query := url.Values{"kind": {kind}}
+	for name, v := range map[string]string{"period": period, "from": from, "to": to} {
+		if v != "" {
+			query.Set(name, v)
+		}
+	}
+
+	var trends RunTrends
+	if err := c.doEncrypted(ctx, http.MethodGet, pathRunTrends, query, nil, &trends); err != nil {
+		return nil, err
+	}
+	return &trends, nil
+}
+
+// 商店数据，对应 ShopChanges 的 feed 参数
+const (
+	ShopFeedProducts = "products"
+	ShopFeedRounds   = "rounds"
+)
+
+// ShopChange 商店中一个商品或转盘的变化；Before / After 为变化前后上游的原始数据
+type ShopChange struct {
+	ID         int64           `json:"id"`
+	Feed       string          `json:"feed"`
+	ItemID     string          `json:"item_id"`
+	Change     string          `json:"change"` // added / removed / changed
+	Name       string          `json:"name"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	DetectedAt time.Time       `json:"detected_at"`
+}
+
+// ShopChanges 一页商店数据变更
+type ShopChanges struct {
+	Changes []ShopChange `json:"changes"`
+	HasMore bool         `json:"has_more"`
+}
+
+// ShopChanges 查询 since 之后的商店数据变更，按 ID 升序；since 为零值时返回最近 7 天，feed 为空时不限数据
+// HasMore 为 true 时以最后一条的 ID 作为 afterID 继续查询；limit 不大于 0 时使用服务器默认值
+func (c *Client) ShopChanges(ctx context.Context, since time.Time, feed string, afterID int64, limit int) (*ShopChanges, error) {
+	query := url.Values{}
+	if !since.IsZero() {
+		query.Set("since", since.Format(time.RFC3339))
+	}
+	if feed != "" {
+		query.Set("feed", feed)
+	}
+	if afterID > 0 {
+		query.Set("after_id", strconv.FormatInt(afterID, 10))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var changes ShopChanges
+	if err := c.doEncrypted(ctx, http.MethodGet, pathShopChanges, query, nil, &changes); err != nil {
+		return nil, err
+	}
+	return &changes, nil
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math/bits"
+	"strconv"
+)
+
+// MaxChallengeDifficulty 客户端愿意求解的最大难度（前导零比特数），超过时放弃而不是长时间占用 CPU
+const MaxChallengeDifficulty = 28
+
+// challenge 服务器在 428 响应中附带的工作量证明挑战
+type challenge struct {
+	Challenge  string `json:"challenge"`
+	Difficulty int    `json:"difficulty"`
+}
+
+// solve 寻找使 sha256(challenge + ":" + solution) 至少有 Difficulty 个前导零比特的 solution
+func (ch challenge) solve(ctx context.Context) (string, error) {
+	if ch.Challenge == "" {
+		return "", fmt.Errorf("client: server requires a challenge but did not provide one")
+	}
+	if ch.Difficulty > MaxChallengeDifficulty {
+		return "", fmt.Errorf("client: challenge difficulty %d exceeds MaxChallengeDifficulty", ch.Difficulty)
+	}
+
+	buf := []byte(ch.Challenge + ":")
+	prefixLen := len(buf)
+	for counter := uint64(0); ; counter++ {
+		if counter%(1<<16) == 0 {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+		}
+		buf = strconv.AppendUint(buf[:prefixLen], counter, 10)
+		if leadingZeroBits(sha256.Sum256(buf)) >= ch.Difficulty {
+			return string(buf[prefixLen:]), nil
+		}
+	}
+}
+
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
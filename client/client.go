@@ -0,0 +1,356 @@
+// Package client 是 corn_server 的 Go 客户端 SDK。
+//
+// 它封装了长期 Key 换取 JWT、JWT 过期自动刷新、请求完整性签名、
+// 本地时钟偏差校正以及响应解密（含 gzip 压缩与分块流式加密），
+// 并为网关目标、活动、任务与 APK 构建提供了类型化的方法。
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// refreshMargin JWT 在过期前多久主动刷新
+	refreshMargin = time.Minute
+	// defaultTimeout 未指定 HTTPClient 时使用的超时时间
+	defaultTimeout = 30 * time.Second
+)
+
+// Config 客户端配置
+type Config struct {
+	BaseURL         string       // 服务器地址，例如 http://127.0.0.1:3839
+	Key             string       // 长期 Key (X-Token)，同时用于解密响应
+	Def             string       // X-Def，客户端使用的功能，例如 useTaie
+	IntegritySecret string       // 应用完整性密钥，用于计算 X-Signature
+	Compression     bool         // 是否协商 gzip 压缩加密载荷
+	HTTPClient      *http.Client // 可选，默认使用带超时的 http.Client
+
+	// ServerKeys 内置（pin）的服务器签名公钥，设置后每个响应都必须带有其中一把公钥的有效签名。
+	// 服务器轮换密钥前会通过 /keys 公布下一把公钥，新版本客户端应同时内置当前与下一把公钥。
+	ServerKeys []ed25519.PublicKey
+
+	// ClientVersion 客户端构建版本，通过 X-Client-Version 上报。
+	// Aliases 该版本的别名表（规范名称 -> 别名），构建时从服务器 GET /admin/aliases?version=<版本> 导出；
+	// 设置后网关目标、参数与任务路由都使用别名访问。
+	ClientVersion string
+	Aliases       map[string]string
+
+	// Locale 菜单与错误消息的语言（例如 en、zh-CN），通过 Accept-Language 上报；
+	// 为空时菜单使用简体中文、错误消息使用英文，Key 在服务器上设置了语言偏好时以偏好为准
+	Locale string
+}
+
+// Client 线程安全的 corn_server 客户端
+type Client struct {
+	cfg        Config
+	http       *http.Client
+	serverKeys map[string]ed25519.PublicKey
+
+	mu          sync.Mutex
+	jwt         string
+	jwtExpiry   time.Time
+	clockOffset time.Duration // 服务器时间 - 本地时间
+	templates   map[string]cachedTemplate
+}
+
+// APIError 服务器返回的非 2xx 响应；Code 为稳定的错误码（例如 invalid_key），Message 随 Config.Locale 的语言变化
+type APIError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("corn_server: %d %s: %s", e.Status, e.Code, e.Message)
+	}
+	return fmt.Sprintf("corn_server: %d %s", e.Status, e.Message)
+}
+
+// New 创建客户端，Key、BaseURL 与 IntegritySecret 为必填项
+func New(cfg Config) (*Client, error) {
+	if cfg.BaseURL == "" || cfg.Key == "" || cfg.IntegritySecret == "" {
+		return nil, errors.New("client: BaseURL, Key and IntegritySecret are required")
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+	serverKeys := make(map[string]ed25519.PublicKey, len(cfg.ServerKeys))
+	for _, pub := range cfg.ServerKeys {
+		if len(pub) != ed25519.PublicKeySize {
+			return nil, errors.New("client: invalid server public key")
+		}
+		serverKeys[KeyID(pub)] = pub
+	}
+	return &Client{cfg: cfg, http: httpClient, serverKeys: serverKeys}, nil
+}
+
+// Authenticate 使用长期 Key 换取新的 JWT，并根据服务器时间校正本地时钟偏差
+// 服务器处于挑战模式（428）时会自动求解工作量证明并重试一次
+func (c *Client) Authenticate(ctx context.Context) error {
+	resp, body, rtt, err := c.postAuthenticate(ctx, nil, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusPreconditionRequired {
+		var ch challenge
+		if err := json.Unmarshal(body, &ch); err != nil {
+			return fmt.Errorf("client: decode challenge: %w", err)
+		}
+		solution, err := ch.solve(ctx)
+		if err != nil {
+			return err
+		}
+		if resp, body, rtt, err = c.postAuthenticate(ctx, &ch, solution); err != nil {
+			return err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseError(resp.StatusCode, body, nil)
+	}
+
+	var tokenResp struct {
+		JWT string `json:"jwt"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return fmt.Errorf("client: decode authenticate response: %w", err)
+	}
+
+	// 只读取过期时间，签名由服务器在后续请求中校验
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenResp.JWT, claims); err != nil {
+		return fmt.Errorf("client: parse jwt: %w", err)
+	}
+	expiry, err := claims.GetExpirationTime()
+	if err != nil || expiry == nil {
+		return errors.New("client: jwt has no expiration")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.jwt = tokenResp.JWT
+	c.jwtExpiry = expiry.Time
+	if serverTs, err := strconv.ParseInt(resp.Header.Get("server-timestamp"), 10, 64); err == nil {
+		// 以请求往返的中点近似服务器生成时间戳的时刻
+		midpoint := rtt.sentAt.Add(rtt.receivedAt.Sub(rtt.sentAt) / 2)
+		c.clockOffset = time.Unix(serverTs, 0).Sub(midpoint)
+	}
+	return nil
+}
+
+// roundTrip 一次请求的发送与接收时间
+type roundTrip struct {
+	sentAt, receivedAt time.Time
+}
+
+// postAuthenticate 发送一次 /authenticate 请求并读完响应体，ch 不为 nil 时附带工作量证明
+func (c *Client) postAuthenticate(ctx context.Context, ch *challenge, solution string) (*http.Response, []byte, roundTrip, error) {
+	var rtt roundTrip
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.BaseURL+"/authenticate", nil)
+	if err != nil {
+		return nil, nil, rtt, err
+	}
+	req.Header.Set("X-Token", c.cfg.Key)
+	req.Header.Set("X-Def", c.cfg.Def)
+	if ch != nil {
+		req.Header.Set("X-Challenge", ch.Challenge)
+		req.Header.Set("X-Challenge-Solution", solution)
+	}
+
+	rtt.sentAt = time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, rtt, err
+	}
+	defer resp.Body.Close()
+	rtt.receivedAt = time.Now()
+	// 认证前尚未校正时钟偏差，这里只校验签名不校验时间戳；JWT 自身带有过期时间
+	c.verifyResponse(resp, nil)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, rtt, err
+	}
+	return resp, body, rtt, nil
+}
+
+// token 返回有效的 JWT，即将过期时自动重新认证
+func (c *Client) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	token, expiry := c.jwt, c.jwtExpiry
+	c.mu.Unlock()
+
+	if token != "" && time.Until(expiry) > refreshMargin {
+		return token, nil
+	}
+	if err := c.Authenticate(ctx); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.jwt, nil
+}
+
+// invalidateToken 丢弃当前 JWT，下一次请求会重新认证
+func (c *Client) invalidateToken() {
+	c.mu.Lock()
+	c.jwt = ""
+	c.mu.Unlock()
+}
+
+// serverNow 返回校正后的服务器当前时间
+func (c *Client) serverNow() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Add(c.clockOffset)
+}
+
+// sign 计算完整性签名，算法与服务器 appIntegrityMiddleware 一致
+func (c *Client) sign(path, timestamp string) string {
+	payload := fmt.Sprintf("%s,%s,%s", path, timestamp, c.cfg.IntegritySecret)
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// send 发送一个带 JWT 与签名头的请求；收到 401 时重新认证并重试一次
+// header 中的额外请求头会原样附加
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any, header http.Header) (*http.Response, error) {
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		if bodyBytes, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	path = c.aliasPath(path)
+	for attempt := 0; ; attempt++ {
+		token, err := c.token(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		target := c.cfg.BaseURL + path
+		if len(query) > 0 {
+			target += "?" + query.Encode()
+		}
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(bodyBytes))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.cfg.Compression {
+			req.Header.Set("X-Payload-Encoding", "gzip")
+		}
+		if c.cfg.ClientVersion != "" {
+			req.Header.Set("X-Client-Version", c.cfg.ClientVersion)
+		}
+		if c.cfg.Locale != "" {
+			req.Header.Set("Accept-Language", c.cfg.Locale)
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+
+		timestamp := strconv.FormatInt(c.serverNow().Unix(), 10)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", c.sign(path, timestamp))
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			resp.Body.Close()
+			c.invalidateToken()
+			continue
+		}
+		c.verifyResponse(resp, c.serverNow)
+		return resp, nil
+	}
+}
+
+// doEncrypted 调用加密接口并将解密后的数据解析到 out
+func (c *Client) doEncrypted(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := c.send(ctx, method, path, query, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseError(resp.StatusCode, raw, []byte(c.cfg.Key))
+	}
+
+	plaintext, err := decodeEncryptedResponse(raw, []byte(c.cfg.Key))
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(plaintext, out)
+}
+
+// doPlain 调用未加密的接口并将 JSON 响应解析到 out
+func (c *Client) doPlain(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := c.send(ctx, method, path, query, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseError(resp.StatusCode, raw, nil)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// parseError 将错误响应转换为 *APIError；加密的错误体在提供 key 时会先解密
+func parseError(status int, raw []byte, key []byte) error {
+	apiErr := &APIError{Status: status}
+
+	body := raw
+	if key != nil {
+		if plaintext, err := decodeEncryptedResponse(raw, key); err == nil {
+			body = plaintext
+		}
+	}
+	if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Message == "" {
+		apiErr.Message = string(raw)
+	}
+	apiErr.Status = status
+	return apiErr
+}
@@ -0,0 +1,269 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testKey = "CF67355A3333E6E143439161ADC2D82E"
+
+// testEncrypt 按服务器的格式加密：base64(salt | nonce | ciphertext)
+func testEncrypt(t *testing.T, plaintext []byte) string {
+	t.Helper()
+	salt := make([]byte, saltSize)
+	rand.Read(salt)
+	aead, err := deriveAEAD([]byte(testKey), salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(append(salt, sealed...))
+}
+
+// testStream 按 corn-aead-v1 格式加密 chunks，final 为 false 时模拟被截断的流
+func testStream(t *testing.T, chunks [][]byte, final bool) []byte {
+	t.Helper()
+	salt := make([]byte, saltSize)
+	prefix := make([]byte, streamPrefixSize)
+	rand.Read(salt)
+	rand.Read(prefix)
+	aead, err := deriveAEAD([]byte(testKey), salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	out.WriteByte(streamFormatVersion)
+	out.Write(salt)
+	out.Write(prefix)
+	for i, chunk := range chunks {
+		nonce := make([]byte, aead.NonceSize())
+		copy(nonce, prefix)
+		binary.BigEndian.PutUint32(nonce[streamPrefixSize:], uint32(i))
+		if final && i == len(chunks)-1 {
+			nonce[len(nonce)-1] = 1
+		}
+		sealed := aead.Seal(nil, nonce, chunk, nil)
+		binary.Write(&out, binary.BigEndian, uint32(len(sealed)))
+		out.Write(sealed)
+	}
+	return out.Bytes()
+}
+
+func newTestServer(t *testing.T, mux *http.ServeMux) *Client {
+	t.Helper()
+	mux.HandleFunc("/authenticate", func(w http.ResponseWriter, r *http.Request) {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": r.Header.Get("X-Token"),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte("test"))
+		w.Header().Set("server-timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+		json.NewEncoder(w).Encode(map[string]string{"jwt": token})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	c, err := New(Config{BaseURL: srv.URL, Key: testKey, Def: "useTaie", IntegritySecret: "secret", Compression: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestDecryptsGzipPayloadAndEncryptedErrors(t *testing.T) {
+	want := []string{"https://a.example/1", "https://a.example/2"}
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathLucy, func(w http.ResponseWriter, r *http.Request) {
+		var req TargetRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.Header.Get("X-Signature") == "" || r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if req.Target != "time" {
+			body, _ := json.Marshal(map[string]any{"status": 404, "error": "Unknown target"})
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(encryptedResponse{Payload: testEncrypt(t, body)})
+			return
+		}
+
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		json.NewEncoder(zw).Encode(want)
+		zw.Close()
+		json.NewEncoder(w).Encode(encryptedResponse{Payload: testEncrypt(t, buf.Bytes()), Encoding: "gzip"})
+	})
+	c := newTestServer(t, mux)
+
+	got, err := c.FarmURLs(context.Background())
+	if err != nil {
+		t.Fatalf("FarmURLs: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FarmURLs = %v, want %v", got, want)
+	}
+
+	_, err = c.GameURLs(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || apiErr.Message != "Unknown target" {
+		t.Errorf("GameURLs error = %v, want decrypted 404 Unknown target", err)
+	}
+}
+
+func TestExportActivitiesDetectsTruncation(t *testing.T) {
+	line, _ := json.Marshal(Activity{ActivityID: 1, Title: "a"})
+	line = append(line, '\n')
+	final := true
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathActivitiesExport, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testStream(t, [][]byte{line, line}, final))
+	})
+	c := newTestServer(t, mux)
+
+	count := 0
+	if err := c.ExportActivities(context.Background(), func(Activity) error { count++; return nil }); err != nil {
+		t.Fatalf("ExportActivities: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("exported %d activities, want 2", count)
+	}
+
+	final = false
+	err := c.ExportActivities(context.Background(), func(Activity) error { return nil })
+	if !errors.Is(err, ErrTruncatedStream) {
+		t.Errorf("truncated stream error = %v, want ErrTruncatedStream", err)
+	}
+}
+
+func TestAuthenticateSolvesChallenge(t *testing.T) {
+	const difficulty = 8
+	attempts := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authenticate", func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		ch, solution := r.Header.Get("X-Challenge"), r.Header.Get("X-Challenge-Solution")
+		if ch == "" || leadingZeroBits(sha256.Sum256([]byte(ch+":"+solution))) < difficulty {
+			w.WriteHeader(http.StatusPreconditionRequired)
+			json.NewEncoder(w).Encode(map[string]any{"challenge": "abc.def", "difficulty": difficulty})
+			return
+		}
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte("test"))
+		json.NewEncoder(w).Encode(map[string]string{"jwt": token})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c, err := New(Config{BaseURL: srv.URL, Key: testKey, IntegritySecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Authenticate(context.Background()); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestAliasesRewriteTargetsAndRoutes(t *testing.T) {
+	const taskAlias = "/api/v1/0123456789abcdef0123456789abcdef"
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathLucy, func(w http.ResponseWriter, r *http.Request) {
+		var req TargetRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.Header.Get("X-Client-Version") != "1.4.2" || req.Target != "a1b2c3d4e5f6" || req.Param != "9f8e7d6c" {
+			t.Errorf("version %q, request %+v", r.Header.Get("X-Client-Version"), req)
+		}
+		body, _ := json.Marshal([]Round{{Name: "r"}})
+		json.NewEncoder(w).Encode(encryptedResponse{Payload: testEncrypt(t, body)})
+	})
+	mux.HandleFunc(taskAlias, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"task_id": "42"})
+	})
+	c := newTestServer(t, mux)
+	c.cfg.ClientVersion = "1.4.2"
+	c.cfg.Aliases = map[string]string{
+		"target:lucy/point":     "a1b2c3d4e5f6",
+		"param:lucy/point/of":   "9f8e7d6c",
+		"route:" + pathNextTask: taskAlias,
+	}
+
+	if _, err := c.Rounds(context.Background(), RoundUniversal); err != nil {
+		t.Fatalf("Rounds: %v", err)
+	}
+	if id, err := c.NextTask(context.Background()); err != nil || id != "42" {
+		t.Errorf("NextTask = %q, %v", id, err)
+	}
+}
+
+func TestMenuTreeSendsLocale(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathGateway, func(w http.ResponseWriter, r *http.Request) {
+		var req TargetRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.Header.Get("Accept-Language") != "en" || req.Target != "drawers" {
+			t.Errorf("Accept-Language %q, request %+v", r.Header.Get("Accept-Language"), req)
+		}
+		body, _ := json.Marshal([]MenuNode{{ID: "round", Label: "Wheel", Children: []MenuNode{{ID: "back", Label: "Back"}}}})
+		json.NewEncoder(w).Encode(encryptedResponse{Payload: testEncrypt(t, body)})
+	})
+	c := newTestServer(t, mux)
+	c.cfg.Locale = "en"
+
+	menu, err := c.MenuTree(context.Background())
+	if err != nil {
+		t.Fatalf("MenuTree: %v", err)
+	}
+	if len(menu) != 1 || menu[0].ID != "round" || len(menu[0].Children) != 1 || menu[0].Children[0].Label != "Back" {
+		t.Errorf("menu = %+v", menu)
+	}
+}
+
+func TestReportTemplateCachesByVersion(t *testing.T) {
+	served := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathLucy, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Template-Version", "v1")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		served++
+		body, _ := json.Marshal("<div>{{.Ecd.TaskNum}}</div>")
+		json.NewEncoder(w).Encode(encryptedResponse{Payload: testEncrypt(t, body)})
+	})
+	c := newTestServer(t, mux)
+
+	for i := 0; i < 2; i++ {
+		tpl, err := c.ReportTemplate(context.Background(), "love")
+		if err != nil || tpl != "<div>{{.Ecd.TaskNum}}</div>" {
+			t.Fatalf("ReportTemplate = %q, %v", tpl, err)
+		}
+	}
+	if served != 1 {
+		t.Errorf("template body served %d times, want 1", served)
+	}
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// 以下参数必须与服务器保持一致
+const (
+	saltSize         = 8
+	pbkdf2Iterations = 4096
+
+	streamFormatVersion = 1
+	streamPrefixSize    = 7
+	maxStreamFrameSize  = 1 << 20 // 单帧密文上限，防止异常长度导致大量内存分配
+)
+
+// ErrTruncatedStream 流在收到 final 块之前结束
+var ErrTruncatedStream = errors.New("client: encrypted stream truncated")
+
+// encryptedResponse 服务器加密响应的外层结构
+type encryptedResponse struct {
+	Payload  string `json:"payload"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// deriveAEAD 使用与服务器相同的 PBKDF2 参数派生 AES-GCM
+func deriveAEAD(password, salt []byte) (cipher.AEAD, error) {
+	derivedKey := pbkdf2.Key(password, salt, pbkdf2Iterations, 32, sha256.New)
+	block, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decrypt 解密 base64(salt | nonce | ciphertext) 格式的载荷
+func decrypt(payloadB64 string, password []byte) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(payloadB64)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < saltSize {
+		return nil, errors.New("client: payload is too short to contain salt")
+	}
+
+	aead, err := deriveAEAD(password, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := data[saltSize:]
+	if len(encrypted) < aead.NonceSize() {
+		return nil, errors.New("client: ciphertext is too short to contain nonce")
+	}
+	nonce, ciphertext := encrypted[:aead.NonceSize()], encrypted[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// decodeEncryptedResponse 解析 {"payload", "encoding"} 响应体，解密并按需解压
+func decodeEncryptedResponse(raw []byte, password []byte) ([]byte, error) {
+	var resp encryptedResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("client: decode encrypted response: %w", err)
+	}
+	if resp.Payload == "" {
+		return nil, errors.New("client: response has no payload")
+	}
+
+	plaintext, err := decrypt(resp.Payload, password)
+	if err != nil {
+		return nil, fmt.Errorf("client: decrypt payload: %w", err)
+	}
+
+	switch resp.Encoding {
+	case "":
+		return plaintext, nil
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(plaintext))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	default:
+		return nil, fmt.Errorf("client: unsupported payload encoding %q", resp.Encoding)
+	}
+}
+
+// streamReader 解密 corn-aead-v1 分块流，只有读到 final 块才会返回 io.EOF
+type streamReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	prefix  [streamPrefixSize]byte
+	counter uint32
+	buf     []byte
+	done    bool
+}
+
+// newStreamReader 读取流头部并派生密钥
+func newStreamReader(r io.Reader, password []byte) (*streamReader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, 1+saltSize+streamPrefixSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("client: read stream header: %w", err)
+	}
+	if header[0] != streamFormatVersion {
+		return nil, fmt.Errorf("client: unsupported stream version %d", header[0])
+	}
+
+	aead, err := deriveAEAD(password, header[1:1+saltSize])
+	if err != nil {
+		return nil, err
+	}
+	sr := &streamReader{r: br, aead: aead}
+	copy(sr.prefix[:], header[1+saltSize:])
+	return sr, nil
+}
+
+func (sr *streamReader) Read(p []byte) (int, error) {
+	for len(sr.buf) == 0 {
+		if sr.done {
+			return 0, io.EOF
+		}
+		if err := sr.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
+
+func (sr *streamReader) readFrame() error {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(sr.r, lenBuf[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrTruncatedStream
+		}
+		return err
+	}
+	size := binary.BigEndian.Uint32(lenBuf[:])
+	if size > maxStreamFrameSize {
+		return fmt.Errorf("client: stream frame too large (%d bytes)", size)
+	}
+
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(sr.r, frame); err != nil {
+		return ErrTruncatedStream
+	}
+
+	nonce := make([]byte, sr.aead.NonceSize())
+	copy(nonce, sr.prefix[:])
+	binary.BigEndian.PutUint32(nonce[streamPrefixSize:], sr.counter)
+
+	// 先按普通块尝试解密，失败再按 final 块尝试
+	plaintext, err := sr.aead.Open(nil, nonce, frame, nil)
+	if err != nil {
+		nonce[len(nonce)-1] = 1
+		if plaintext, err = sr.aead.Open(nil, nonce, frame, nil); err != nil {
+			return fmt.Errorf("client: stream frame %d authentication failed", sr.counter)
+		}
+		sr.done = true
+	}
+	sr.counter++
+	sr.buf = plaintext
+	return nil
+}
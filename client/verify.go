@@ -0,0 +1,102 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxSignatureAge 响应签名时间戳与（校正后的）本地时间允许的最大偏差
+const maxSignatureAge = 5 * time.Minute
+
+// ErrBadServerSignature 响应缺少有效的服务器签名
+var ErrBadServerSignature = errors.New("client: server signature verification failed")
+
+// KeyID 计算公钥标识，与服务器 X-Server-Key-Id 响应头一致
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// verifyingBody 在读取响应体的同时计算哈希，读到末尾时校验服务器签名
+// 签名校验失败时以 ErrBadServerSignature 代替 io.EOF 返回，因此调用方必须把响应体读完
+type verifyingBody struct {
+	io.ReadCloser
+	resp    *http.Response
+	keys    map[string]ed25519.PublicKey
+	now     func() time.Time // 为 nil 时不检查时间戳
+	hash    hash.Hash
+	checked bool
+	err     error
+}
+
+func (v *verifyingBody) Read(p []byte) (int, error) {
+	if v.checked {
+		if v.err != nil {
+			return 0, v.err
+		}
+		return 0, io.EOF
+	}
+
+	n, err := v.ReadCloser.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF {
+		v.checked = true
+		if v.err = v.verify(); v.err != nil {
+			return n, v.err
+		}
+	}
+	return n, err
+}
+
+// verify 签名在普通响应中位于响应头，流式响应中位于 Trailer（读到 EOF 后才可用）
+func (v *verifyingBody) verify() error {
+	header := v.resp.Header
+	signature := header.Get("X-Server-Signature")
+	if signature == "" {
+		signature = v.resp.Trailer.Get("X-Server-Signature")
+	}
+	timestamp := header.Get("X-Server-Timestamp")
+
+	pub, ok := v.keys[header.Get("X-Server-Key-Id")]
+	if !ok || signature == "" || timestamp == "" {
+		return ErrBadServerSignature
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrBadServerSignature
+	}
+
+	message := fmt.Appendf(nil, "%d\n%s\n%s\n%s\n%s",
+		v.resp.StatusCode, v.resp.Request.Method, v.resp.Request.URL.Path, timestamp, hex.EncodeToString(v.hash.Sum(nil)))
+	if !ed25519.Verify(pub, message, sig) {
+		return ErrBadServerSignature
+	}
+
+	if v.now != nil {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return ErrBadServerSignature
+		}
+		if age := v.now().Sub(time.Unix(ts, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+			return fmt.Errorf("%w: timestamp out of range", ErrBadServerSignature)
+		}
+	}
+	return nil
+}
+
+// verifyResponse 在配置了 ServerKeys 时替换 resp.Body 以校验服务器签名
+func (c *Client) verifyResponse(resp *http.Response, now func() time.Time) {
+	if len(c.serverKeys) == 0 {
+		return
+	}
+	resp.Body = &verifyingBody{ReadCloser: resp.Body, resp: resp, keys: c.serverKeys, now: now, hash: sha256.New()}
+}
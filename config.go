@@ -1,123 +1,771 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"os/signal"
+	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
 )
 
 // --- Configuration ---
+//
+// 配置按以下顺序合并：内置默认值（defaultConfig）→ CONFIG_FILE 指定的 YAML 文件 → 环境变量（configEnvOverrides）。
+// 合并后由 validate 一次检查全部字段，有错误时拒绝启动并列出所有问题；YAML 中写错的字段名同样视为错误。
+// 收到 SIGHUP 或调用 POST /admin/config/reload 时重新加载：challenge、aliases、watermark、upstream、client、shop、limits
+// 各节立即生效（网关目标随之重建）；其余各节（监听地址、数据库、密钥、文件路径、定时任务）只在启动时读取，
+// 修改后需要重启，重新加载时在日志与 GET /admin/config 的 restart_pending 中提示。
+// 启动时读取的值同时保存在下面的全局变量中；运行中可以修改的值通过 currentConfig() 读取。
 
 var (
-	jwtSecretKey            string
-	redisAddress            string
-	redisPassword           string
-	swordRedisDB            int
-	apkRedisDB              int
-	tokenLifetime           = time.Hour * 12
-	appIntegritySecret      string
-	productsUrl             string
-	roundUrl                string
-	universalUrl            string
-	wannengUrl              string
-	clientSecretKey         string
-	clientSecretValue       string
-	anotherSecretString     string
-	actOnClickString        string
-	pageTokenString         string
-	pageRandomStrString     string
-	xiaoyouxiInfoString     string
-	getVarValueQuoted       string
-	getVarValueUnQuoted     string
-	getVarJsonValueUnQuoted string
-	farmUrls                []string
-	extractRe               string
-	extractS                string
-	gameUrls                []string
-	gameParams              []string
+	jwtSecretKey       secret
+	redisAddress       string
+	redisPassword      secret
+	swordRedisDB       int
+	apkRedisDB         int
+	tokenLifetime      time.Duration
+	appIntegritySecret secret
+
+	// 网关目标定义文件，为空时只使用内置定义
+	gatewayTargetsFile string
+
+	// 覆盖内置报告模板的目录，为空时只使用内置模板
+	templatesDir string
+
+	// 客户端菜单定义文件，为空时使用内置定义
+	menusFile string
+
+	// 计算目标与路由别名的密钥
+	targetAliasSecret secret
+
+	// 响应签名 (Ed25519)
+	serverSigningKey           secret
+	serverSigningNextPublicKey string
+
+	// 管理接口令牌，为空时关闭 /admin
+	adminToken secret
 
 	// PostgreSQL config
 	postgresHost     string
 	postgresPort     string
 	postgresUser     string
-	postgresPassword string
+	postgresPassword secret
 	postgresDbname   string
 	taskStartID      int
+
+	// configFile 配置文件路径，来自 CONFIG_FILE
+	configFile string
 )
 
+// appConfig 完整配置，对应 config.example.yaml 的结构
+type appConfig struct {
+	// Environment development 或 production，production 下拒绝使用默认或过短的密钥，见 secrets.go
+	Environment string `yaml:"environment" json:"environment"`
+
+	Server    serverConfig    `yaml:"server" json:"server"`
+	Redis     redisConfig     `yaml:"redis" json:"redis"`
+	Postgres  postgresConfig  `yaml:"postgres" json:"postgres"`
+	Auth      authConfig      `yaml:"auth" json:"auth"`
+	Files     filesConfig     `yaml:"files" json:"files"`
+	Cron      cronConfig      `yaml:"cron" json:"cron"`
+	Tasks     tasksConfig     `yaml:"tasks" json:"tasks"`
+	Challenge challengeConfig `yaml:"challenge" json:"challenge"`
+	Aliases   aliasesConfig   `yaml:"aliases" json:"aliases"`
+	Watermark watermarkConfig `yaml:"watermark" json:"watermark"`
+	Upstream  upstreamConfig  `yaml:"upstream" json:"upstream"`
+	Cache     cacheConfig     `yaml:"cache" json:"cache"`
+	Client    clientConfig    `yaml:"client" json:"client"`
+	Shop      shopConfig      `yaml:"shop" json:"shop"`
+	Limits    limitsConfig    `yaml:"limits" json:"limits"`
+}
+
+type serverConfig struct {
+	Addr           string   `yaml:"addr" json:"addr"`
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"`
+	// 反向代理的后端
+	CyberProxy string `yaml:"cyber_proxy" json:"cyber_proxy"`
+	WooProxy   string `yaml:"woo_proxy" json:"woo_proxy"`
+}
+
+type redisConfig struct {
+	Address  string `yaml:"address" json:"address"`
+	Password secret `yaml:"password" json:"password"`
+	DB       int    `yaml:"db" json:"db"`
+	ApkDB    int    `yaml:"apk_db" json:"apk_db"`
+}
+
+type postgresConfig struct {
+	Host     string `yaml:"host" json:"host"`
+	Port     string `yaml:"port" json:"port"`
+	User     string `yaml:"user" json:"user"`
+	Password secret `yaml:"password" json:"password"`
+	DBName   string `yaml:"dbname" json:"dbname"`
+}
+
+type authConfig struct {
+	JWTSecretKey         secret   `yaml:"jwt_secret_key" json:"jwt_secret_key"`
+	TokenLifetime        duration `yaml:"token_lifetime" json:"token_lifetime"`
+	AppIntegritySecret   secret   `yaml:"app_integrity_secret" json:"app_integrity_secret"`
+	AdminToken           secret   `yaml:"admin_token" json:"admin_token"`
+	TargetAliasSecret    secret   `yaml:"target_alias_secret" json:"target_alias_secret"`
+	SigningKey           secret   `yaml:"signing_key" json:"signing_key"`
+	SigningNextPublicKey string   `yaml:"signing_next_public_key" json:"signing_next_public_key"`
+}
+
+type filesConfig struct {
+	GatewayTargets string `yaml:"gateway_targets" json:"gateway_targets"`
+	TemplatesDir   string `yaml:"templates_dir" json:"templates_dir"`
+	Menus          string `yaml:"menus" json:"menus"`
+}
+
+// cronConfig 定时任务的 cron 表达式（北京时间）
+type cronConfig struct {
+	BoxActivities string `yaml:"box_activities" json:"box_activities"`
+	UsageRollup   string `yaml:"usage_rollup" json:"usage_rollup"`
+	ShopDiscovery string `yaml:"shop_discovery" json:"shop_discovery"`
+	ReportPurge   string `yaml:"report_purge" json:"report_purge"`
+	// ShopFeedRefresh 比较商店数据并记录变更
+	ShopFeedRefresh string `yaml:"shop_feed_refresh" json:"shop_feed_refresh"`
+}
+
+type tasksConfig struct {
+	StartID int `yaml:"start_id" json:"start_id"`
+}
+
+// challengeConfig 认证工作量证明挑战，见 challenge.go
+type challengeConfig struct {
+	Mode       string `yaml:"mode" json:"mode"`
+	Difficulty int    `yaml:"difficulty" json:"difficulty"`
+}
+
+// aliasesConfig 按客户端版本轮换的目标与路由别名，见 aliases.go
+type aliasesConfig struct {
+	Mode              string   `yaml:"mode" json:"mode"`
+	SupportedVersions []string `yaml:"supported_versions" json:"supported_versions"`
+}
+
+// watermarkConfig 下发配置的水印通道，为空时关闭，见 watermark.go
+type watermarkConfig struct {
+	Channels []string `yaml:"channels" json:"channels"`
+}
+
+// upstreamConfig 上游接口地址，大部分经网关下发给客户端
+type upstreamConfig struct {
+	ProductsURL     string   `yaml:"products_url" json:"products_url"`
+	RoundURL        string   `yaml:"round_url" json:"round_url"`
+	UniversalURL    string   `yaml:"universal_url" json:"universal_url"`
+	WannengURL      string   `yaml:"wanneng_url" json:"wanneng_url"`
+	FarmURLs        []string `yaml:"farm_urls" json:"farm_urls"`
+	GameURLs        []string `yaml:"game_urls" json:"game_urls"`
+	ShopInkindURLs  []string `yaml:"shop_inkind_urls" json:"shop_inkind_urls"`
+	ShopVirtualURLs []string `yaml:"shop_virtual_urls" json:"shop_virtual_urls"`
+}
+
+// clientConfig 经网关下发给客户端的常量与正则
+type clientConfig struct {
+	SecretKey     string         `yaml:"secret_key" json:"secret_key"`
+	SecretValue   string         `yaml:"secret_value" json:"secret_value"`
+	AnotherSecret string         `yaml:"another_secret" json:"another_secret"`
+	ActOnClick    string         `yaml:"act_on_click" json:"act_on_click"`
+	PageToken     string         `yaml:"page_token" json:"page_token"`
+	PageRandomStr string         `yaml:"page_random_str" json:"page_random_str"`
+	XiaoyouxiInfo string         `yaml:"xiaoyouxi_info" json:"xiaoyouxi_info"`
+	GameParams    []string       `yaml:"game_params" json:"game_params"`
+	Patterns      clientPatterns `yaml:"patterns" json:"patterns"`
+}
+
+// clientPatterns 客户端使用的正则；var_* 中的 %s 由客户端替换为变量名
+type clientPatterns struct {
+	Extract     string `yaml:"extract" json:"extract"`
+	ExtractS    string `yaml:"extract_s" json:"extract_s"`
+	VarQuoted   string `yaml:"var_quoted" json:"var_quoted"`
+	VarUnquoted string `yaml:"var_unquoted" json:"var_unquoted"`
+	VarJSON     string `yaml:"var_json" json:"var_json"`
+}
+
+// cacheConfig 上游商店数据（products.js、classify_24.js）的缓存，见 feedcache.go
+type cacheConfig struct {
+	// FeedTTL 缓存的有效期；过期后 FeedStale 内仍可返回旧数据，同时在后台刷新
+	FeedTTL   duration `yaml:"feed_ttl" json:"feed_ttl"`
+	FeedStale duration `yaml:"feed_stale" json:"feed_stale"`
+}
+
+// shopConfig 商品自动发现，见 shop.go
+type shopConfig struct {
+	// DiscoveryPattern 匹配商品名的正则，为空时关闭自动发现
+	DiscoveryPattern  string `yaml:"discovery_pattern" json:"discovery_pattern"`
+	DiscoveryCategory string `yaml:"discovery_category" json:"discovery_category"`
+}
+
+// limitsConfig 按套餐的限流与每日配额，见 ratelimit.go 与 usage.go
+type limitsConfig struct {
+	// RateLimits 限流器 -> 套餐 -> 令牌桶参数，每个限流器都需要 default 套餐，其他套餐未配置时使用它
+	RateLimits map[string]map[string]rateLimit `yaml:"rate_limits" json:"rate_limits"`
+	// Routes 路由（gin 的 FullPath）-> 限流器，值为空字符串时不限流
+	Routes map[string]string `yaml:"routes" json:"routes"`
+	// Quotas 套餐 -> 功能 -> 每日上限，-1 或未配置的功能不限量；套餐未配置时使用 default
+	Quotas map[string]map[string]int64 `yaml:"quotas" json:"quotas"`
+}
+
+// duration 在 YAML 与 JSON 中写作 "12h"、"30m" 这样的字符串
+type duration time.Duration
+
+func (d *duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*d = duration(v)
+	return nil
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// defaultConfig 内置默认值，与 config.example.yaml 保持一致
+func defaultConfig() *appConfig {
+	return &appConfig{
+		Environment: envDevelopment,
+		Server: serverConfig{
+			Addr:           ":3839",
+			TrustedProxies: []string{"127.0.0.1"},
+			CyberProxy:     "http://127.0.0.1:8000",
+			WooProxy:       "http://127.0.0.1:13456",
+		},
+		Redis: redisConfig{Address: "localhost:6379", DB: 0, ApkDB: 6},
+		Postgres: postgresConfig{
+			Host:     "localhost",
+			Port:     "5432",
+			User:     "user",
+			Password: "password",
+			DBName:   "forum",
+		},
+		Auth: authConfig{
+			JWTSecretKey:       "your-super-secret-jwt-key",
+			TokenLifetime:      duration(12 * time.Hour),
+			AppIntegritySecret: "a-very-secret-string-for-app-integrity",
+		},
+		Cron: cronConfig{
+			BoxActivities:   "3 10,12,14,16,18,20,22 * * *",
+			UsageRollup:     "7 * * * *",
+			ShopDiscovery:   "17 * * * *",
+			ReportPurge:     "30 4 * * *",
+			ShopFeedRefresh: "*/10 * * * *",
+		},
+		Tasks:     tasksConfig{StartID: 44000},
+		Challenge: challengeConfig{Mode: challengeModeAuto, Difficulty: 18},
+		Aliases:   aliasesConfig{Mode: clientAliasModeOptional, SupportedVersions: []string{}},
+		Watermark: watermarkConfig{Channels: []string{}},
+		Upstream: upstreamConfig{
+			ProductsURL:  "https://shop.3839.com/html/js/products.js",
+			RoundURL:     "https://shop.3839.com/html/js/classify_24.js",
+			UniversalURL: "https://act.3839.com/n/hykb/universal/ajax.php",
+			WannengURL:   "https://act.3839.com/n/hykb/wanneng/ajax.php",
+			FarmURLs: []string{
+				"https://huodong3.3839.com/n/hykb/cornfarm/index.php?imm=0",
+				"https://huodong3.3839.com/n/hykb/cornfarm/ajax_daily.php",
+				"https://huodong3.3839.com/n/hykb/cornfarm/ajax.php",
+				"https://huodong3.3839.com/n/hykb/cornfarm/ajax_plant.php",
+				"https://api.3839app.com/kuaibao/android/api.cloudgame.php",
+				"https://huodong3.3839.com/n/hykb/cornfarm/ajax_sign.php",
+			},
+			GameURLs: []string{
+				"https://huodong3.3839.com/n/hykb/cfxyx/ajax.php",
+				"https://api.3839app.com/kuaibao/android/api.php",
+				"https://api.3839app.com/kuaibao/android/api.cloudgame.php",
+				"https://api.3839app.com/cdn/android/ranktop-home-1577-type-mini-page-1-level-2.htm",
+			},
+			ShopInkindURLs: []string{
+				"https://shop.3839.com/index.php?c=DetailInkind&a=choose",
+				"https://shop.3839.com/index.php?c=OrderInkind&a=checkOrder",
+				"https://shop.3839.com/index.php?c=OrderInkind&a=createOrder",
+			},
+			ShopVirtualURLs: []string{
+				"https://shop.3839.com/index.php?c=DetailVirtual&a=choose",
+				"https://shop.3839.com/index.php?c=OrderVirtual&a=checkOrder",
+				"https://shop.3839.com/index.php?c=OrderVirtual&a=createOrder",
+			},
+		},
+		Client: clientConfig{
+			SecretKey:     "secret",
+			SecretValue:   "c1714e41e5a907874c59a4d81a8486ea",
+			AnotherSecret: "hbktahqbyihfiidc",
+			ActOnClick:    ".task-prize a.daily_before1_btn_",
+			PageToken:     "pageToken",
+			PageRandomStr: "pageRandomStr",
+			XiaoyouxiInfo: "xiaoyouxiInfo",
+			GameParams:    []string{"login", "1", "CheckData", "2", "checkRealName", "RecordPlaytime", "OpenXyx", "LingPrize"},
+			Patterns: clientPatterns{
+				Extract:     `[&?]comm_id=([^&]+)`,
+				ExtractS:    `"s":\s*"?([^"]+)"?`,
+				VarQuoted:   `var\s+%s\s*=\s*(['"])([^'"]*)(['"])`,
+				VarUnquoted: `var\s+%s\s*=\s*([^;\r\n]+)`,
+				VarJSON:     `var\s+%s\s*=\s*({[^\r\n]+});`,
+			},
+		},
+		Cache: cacheConfig{FeedTTL: duration(time.Minute), FeedStale: duration(30 * time.Minute)},
+		Shop:  shopConfig{DiscoveryCategory: "pp"},
+		Limits: limitsConfig{
+			RateLimits: map[string]map[string]rateLimit{
+				rateLimiterPoint: {
+					defaultPlan: {Interval: duration(30 * time.Second), Burst: 5},
+					"pro":       {Interval: duration(10 * time.Second), Burst: 10},
+				},
+				rateLimiterHandshake: {
+					defaultPlan: {Interval: duration(30 * time.Second), Burst: 5},
+					"pro":       {Interval: duration(10 * time.Second), Burst: 10},
+				},
+				"woo:box_search": {
+					defaultPlan: {Interval: duration(5 * time.Second), Burst: 10},
+					"pro":       {Interval: duration(time.Second), Burst: 30},
+				},
+				"api:reports": {
+					defaultPlan: {Interval: duration(10 * time.Second), Burst: 10},
+					"pro":       {Interval: duration(2 * time.Second), Burst: 30},
+				},
+				"apk:submit": {
+					defaultPlan: {Interval: duration(5 * time.Minute), Burst: 2},
+					"pro":       {Interval: duration(time.Minute), Burst: 5},
+				},
+			},
+			Routes: map[string]string{
+				"/woo/box_search": "woo:box_search",
+				"/apk/submit":     "apk:submit",
+				"/api/reports":    "api:reports",
+			},
+			Quotas: map[string]map[string]int64{
+				defaultPlan: {
+					featureApkBuild:  3,
+					featureBoxSearch: 200,
+					featureRound:     300,
+					featureLottery:   300,
+					featureExport:    10,
+					featureReport:    100,
+				},
+				"pro": {
+					featureApkBuild:  20,
+					featureBoxSearch: 2000,
+					featureRound:     3000,
+					featureLottery:   3000,
+					featureReport:    1000,
+				},
+			},
+		},
+	}
+}
+
+// configEnvOverrides 可以用环境变量覆盖的配置项，优先级高于配置文件
+// secret 为 true 的项也可以通过 <env>_FILE 从文件读取，见 secrets.go
+var configEnvOverrides = []struct {
+	env    string
+	apply  func(c *appConfig, v string) error
+	secret bool
+}{
+	{"APP_ENV", func(c *appConfig, v string) error { c.Environment = v; return nil }, false},
+	{"SERVER_ADDR", func(c *appConfig, v string) error { c.Server.Addr = v; return nil }, false},
+	{"CYBER_PROXY_URL", func(c *appConfig, v string) error { c.Server.CyberProxy = v; return nil }, false},
+	{"WOO_PROXY_URL", func(c *appConfig, v string) error { c.Server.WooProxy = v; return nil }, false},
+	{"REDIS_ADDRESS", func(c *appConfig, v string) error { c.Redis.Address = v; return nil }, false},
+	{"REDIS_PASSWORD", func(c *appConfig, v string) error { c.Redis.Password = secret(v); return nil }, true},
+	{"REDIS_DB", func(c *appConfig, v string) error { return parseEnvInt(v, &c.Redis.DB) }, false},
+	{"APK_REDIS_DB", func(c *appConfig, v string) error { return parseEnvInt(v, &c.Redis.ApkDB) }, false},
+	{"POSTGRES_HOST", func(c *appConfig, v string) error { c.Postgres.Host = v; return nil }, false},
+	{"POSTGRES_PORT", func(c *appConfig, v string) error { c.Postgres.Port = v; return nil }, false},
+	{"POSTGRES_USER", func(c *appConfig, v string) error { c.Postgres.User = v; return nil }, false},
+	{"POSTGRES_PASSWORD", func(c *appConfig, v string) error { c.Postgres.Password = secret(v); return nil }, true},
+	{"POSTGRES_DBNAME", func(c *appConfig, v string) error { c.Postgres.DBName = v; return nil }, false},
+	{"JWT_SECRET_KEY", func(c *appConfig, v string) error { c.Auth.JWTSecretKey = secret(v); return nil }, true},
+	{"TOKEN_LIFETIME", func(c *appConfig, v string) error { return parseEnvDuration(v, &c.Auth.TokenLifetime) }, false},
+	{"APP_INTEGRITY_SECRET", func(c *appConfig, v string) error { c.Auth.AppIntegritySecret = secret(v); return nil }, true},
+	{"ADMIN_TOKEN", func(c *appConfig, v string) error { c.Auth.AdminToken = secret(v); return nil }, true},
+	{"TARGET_ALIAS_SECRET", func(c *appConfig, v string) error { c.Auth.TargetAliasSecret = secret(v); return nil }, true},
+	{"SERVER_SIGNING_KEY", func(c *appConfig, v string) error { c.Auth.SigningKey = secret(v); return nil }, true},
+	{"SERVER_SIGNING_NEXT_PUBLIC_KEY", func(c *appConfig, v string) error { c.Auth.SigningNextPublicKey = v; return nil }, false},
+	{"GATEWAY_TARGETS_FILE", func(c *appConfig, v string) error { c.Files.GatewayTargets = v; return nil }, false},
+	{"TEMPLATES_DIR", func(c *appConfig, v string) error { c.Files.TemplatesDir = v; return nil }, false},
+	{"MENUS_FILE", func(c *appConfig, v string) error { c.Files.Menus = v; return nil }, false},
+	{"TASK_START_ID", func(c *appConfig, v string) error { return parseEnvInt(v, &c.Tasks.StartID) }, false},
+	{"CHALLENGE_MODE", func(c *appConfig, v string) error { c.Challenge.Mode = v; return nil }, false},
+	{"CHALLENGE_DIFFICULTY", func(c *appConfig, v string) error { return parseEnvInt(v, &c.Challenge.Difficulty) }, false},
+	{"CLIENT_ALIAS_MODE", func(c *appConfig, v string) error { c.Aliases.Mode = v; return nil }, false},
+	{"SUPPORTED_CLIENT_VERSIONS", func(c *appConfig, v string) error { c.Aliases.SupportedVersions = splitList(v); return nil }, false},
+	{"WATERMARK_CHANNELS", func(c *appConfig, v string) error { c.Watermark.Channels = splitList(v); return nil }, false},
+	{"FEED_CACHE_TTL", func(c *appConfig, v string) error { return parseEnvDuration(v, &c.Cache.FeedTTL) }, false},
+	{"FEED_CACHE_STALE", func(c *appConfig, v string) error { return parseEnvDuration(v, &c.Cache.FeedStale) }, false},
+	{"SHOP_DISCOVERY_PATTERN", func(c *appConfig, v string) error { c.Shop.DiscoveryPattern = v; return nil }, false},
+	{"SHOP_DISCOVERY_CATEGORY", func(c *appConfig, v string) error { c.Shop.DiscoveryCategory = v; return nil }, false},
+}
+
+func parseEnvInt(v string, dst *int) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", v)
+	}
+	*dst = n
+	return nil
+}
+
+func parseEnvDuration(v string, dst *duration) error {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration", v)
+	}
+	*dst = duration(d)
+	return nil
+}
+
+// splitList 解析逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var items []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			items = append(items, v)
+		}
+	}
+	return items
+}
+
+// loadedConfig 一次加载的结果，加载后只读
+type loadedConfig struct {
+	cfg      *appConfig
+	source   string
+	loadedAt time.Time
+	// envOverrides 实际生效的环境变量名
+	envOverrides []string
+	// restartPending 已修改但需要重启才能生效的节
+	restartPending []string
+}
+
+var activeConfig atomic.Pointer[loadedConfig]
+
+// currentConfig 当前生效的配置，调用方不得修改
+func currentConfig() *appConfig {
+	return activeConfig.Load().cfg
+}
+
 // init 函数在包初始化时自动执行，非常适合用来加载配置
 func init() {
-	jwtSecretKey = getEnv("JWT_SECRET_KEY", "your-super-secret-jwt-key")
-	redisAddress = getEnv("REDIS_ADDRESS", "localhost:6379")
-	redisPassword = getEnv("REDIS_PASSWORD", "")
-
-	dbStr := getEnv("REDIS_DB", "0")
-	db, err := strconv.Atoi(dbStr)
+	configFile = getEnv("CONFIG_FILE", "")
+	loaded, err := loadConfig(configFile)
 	if err != nil {
-		log.Printf("无效的 REDIS_DB 值 '%s'，将使用默认值 0。错误: %v", dbStr, err)
-		swordRedisDB = 0
-	} else {
-		swordRedisDB = db
-	}
-
-	// Temporary Method
-	apkRedisDB = 6
-
-	appIntegritySecret = getEnv("APP_INTEGRITY_SECRET", "a-very-secret-string-for-app-integrity")
-	productsUrl = "https://shop.3839.com/html/js/products.js"
-	roundUrl = "https://shop.3839.com/html/js/classify_24.js"
-	universalUrl = "https://act.3839.com/n/hykb/universal/ajax.php"
-	wannengUrl = "https://act.3839.com/n/hykb/wanneng/ajax.php"
-	clientSecretKey = "secret"
-	clientSecretValue = "c1714e41e5a907874c59a4d81a8486ea"
-	anotherSecretString = "hbktahqbyihfiidc"
-	actOnClickString = ".task-prize a.daily_before1_btn_"
-	pageTokenString = "pageToken"
-	pageRandomStrString = "pageRandomStr"
-	xiaoyouxiInfoString = "xiaoyouxiInfo"
-	getVarValueQuoted = `var\s+%s\s*=\s*(['"])([^'"]*)(['"])`
-	getVarValueUnQuoted = `var\s+%s\s*=\s*([^;\r\n]+)`
-	getVarJsonValueUnQuoted = `var\s+%s\s*=\s*({[^\r\n]+});`
-
-	farmUrls = []string{
-		"https://huodong3.3839.com/n/hykb/cornfarm/index.php?imm=0",
-		"https://huodong3.3839.com/n/hykb/cornfarm/ajax_daily.php",
-		"https://huodong3.3839.com/n/hykb/cornfarm/ajax.php",
-		"https://huodong3.3839.com/n/hykb/cornfarm/ajax_plant.php",
-		"https://api.3839app.com/kuaibao/android/api.cloudgame.php",
-		"https://huodong3.3839.com/n/hykb/cornfarm/ajax_sign.php",
-	}
-
-	gameUrls = []string{
-		"https://huodong3.3839.com/n/hykb/cfxyx/ajax.php",
-		"https://api.3839app.com/kuaibao/android/api.php",
-		"https://api.3839app.com/kuaibao/android/api.cloudgame.php",
-		"https://api.3839app.com/cdn/android/ranktop-home-1577-type-mini-page-1-level-2.htm",
-	}
-
-	gameParams = []string{
-		"login", "1", "CheckData", "2", "checkRealName", "RecordPlaytime", "OpenXyx", "LingPrize",
-	}
-
-	extractRe = `[&?]comm_id=([^&]+)`
-	extractS = `"s":\s*"?([^"]+)"?`
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	activeConfig.Store(loaded)
+	applyStartupConfig(loaded.cfg)
+	log.Printf("配置已从 %s 加载（%s）", loaded.source, loaded.cfg.Environment)
+	if loaded.cfg.Environment != envProduction {
+		for _, problem := range loaded.cfg.insecureSecrets() {
+			log.Printf("警告: %s，生产环境 (APP_ENV=production) 下将拒绝启动", problem)
+		}
+	}
+}
 
-	// PostgreSQL config
-	postgresHost = getEnv("POSTGRES_HOST", "localhost")
-	postgresPort = getEnv("POSTGRES_PORT", "5432")
-	postgresUser = getEnv("POSTGRES_USER", "user")
-	postgresPassword = getEnv("POSTGRES_PASSWORD", "password")
-	postgresDbname = getEnv("POSTGRES_DBNAME", "forum")
-
-	taskStartIDStr := getEnv("TASK_START_ID", "44000")
-	taskStartID, err = strconv.Atoi(taskStartIDStr)
+// loadConfig 合并默认值、path 指定的 YAML 文件（为空时跳过）与环境变量，并校验结果
+func loadConfig(path string) (*loadedConfig, error) {
+	loaded := &loadedConfig{cfg: defaultConfig(), source: "builtin", loadedAt: time.Now()}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(loaded.cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		loaded.source = path
+	}
+
+	var problems []string
+	for _, o := range configEnvOverrides {
+		name, v, ok, err := lookupEnvOverride(o.env, o.secret)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := o.apply(loaded.cfg, v); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+		loaded.envOverrides = append(loaded.envOverrides, name)
+	}
+	if len(loaded.envOverrides) > 0 {
+		loaded.source += " + env"
+	}
+
+	problems = append(problems, loaded.cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return loaded, nil
+}
+
+// validate 返回全部问题，每项以字段路径开头
+func (c *appConfig) validate() []string {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	checkURL := func(field, raw string) {
+		u, err := url.Parse(raw)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "%s: %q is not an http(s) URL", field, raw)
+	}
+	checkURLs := func(field string, list []string) {
+		check(len(list) > 0, "%s: must not be empty", field)
+		for i, raw := range list {
+			checkURL(fmt.Sprintf("%s[%d]", field, i), raw)
+		}
+	}
+	checkPattern := func(field, pattern string) {
+		_, err := regexp.Compile(pattern)
+		check(err == nil, "%s: %v", field, err)
+	}
+	checkVarPattern := func(field, pattern string) {
+		check(strings.Count(pattern, "%s") == 1, "%s: must contain exactly one %%s", field)
+		checkPattern(field, strings.ReplaceAll(pattern, "%s", "name"))
+	}
+	checkCron := func(field, spec string) {
+		_, err := cron.ParseStandard(spec)
+		check(err == nil, "%s: %v", field, err)
+	}
+
+	check(c.Environment == envDevelopment || c.Environment == envProduction,
+		"environment: %q must be development or production", c.Environment)
+	if c.Environment == envProduction {
+		problems = append(problems, c.insecureSecrets()...)
+	}
+
+	_, port, err := net.SplitHostPort(c.Server.Addr)
+	_, portErr := strconv.Atoi(port)
+	check(err == nil && portErr == nil, "server.addr: %q is not a host:port address", c.Server.Addr)
+	checkURL("server.cyber_proxy", c.Server.CyberProxy)
+	checkURL("server.woo_proxy", c.Server.WooProxy)
+
+	check(c.Redis.Address != "", "redis.address: must not be empty")
+	check(c.Redis.DB >= 0 && c.Redis.DB <= 15, "redis.db: %d is out of range 0-15", c.Redis.DB)
+	check(c.Redis.ApkDB >= 0 && c.Redis.ApkDB <= 15, "redis.apk_db: %d is out of range 0-15", c.Redis.ApkDB)
+
+	check(c.Postgres.Host != "", "postgres.host: must not be empty")
+	_, err = strconv.ParseUint(c.Postgres.Port, 10, 16)
+	check(err == nil, "postgres.port: %q is not a port number", c.Postgres.Port)
+	check(c.Postgres.User != "", "postgres.user: must not be empty")
+	check(c.Postgres.DBName != "", "postgres.dbname: must not be empty")
+
+	check(c.Auth.JWTSecretKey != "", "auth.jwt_secret_key: must not be empty")
+	check(c.Auth.AppIntegritySecret != "", "auth.app_integrity_secret: must not be empty")
+	check(c.Auth.TokenLifetime > 0, "auth.token_lifetime: must be positive")
+
+	checkCron("cron.box_activities", c.Cron.BoxActivities)
+	checkCron("cron.usage_rollup", c.Cron.UsageRollup)
+	checkCron("cron.shop_discovery", c.Cron.ShopDiscovery)
+	checkCron("cron.report_purge", c.Cron.ReportPurge)
+	checkCron("cron.shop_feed_refresh", c.Cron.ShopFeedRefresh)
+
+	check(c.Tasks.StartID > 0, "tasks.start_id: must be positive")
+
+	check(slices.Contains([]string{challengeModeOff, challengeModeAuto, challengeModeAlways}, c.Challenge.Mode),
+		"challenge.mode: %q must be off, auto or always", c.Challenge.Mode)
+	// 严格模式下难度再增加 challengeStrictBonus，仍需在客户端愿意求解的范围内
+	check(c.Challenge.Difficulty >= 1 && c.Challenge.Difficulty+challengeStrictBonus <= maxChallengeDifficulty,
+		"challenge.difficulty: %d is out of range 1-%d", c.Challenge.Difficulty, maxChallengeDifficulty-challengeStrictBonus)
+
+	check(slices.Contains([]string{clientAliasModeOff, clientAliasModeOptional, clientAliasModeRequired}, c.Aliases.Mode),
+		"aliases.mode: %q must be off, optional or required", c.Aliases.Mode)
+
+	for _, ch := range c.Watermark.Channels {
+		check(slices.Contains([]string{watermarkChannelQuery, watermarkChannelRegex, watermarkChannelOrder}, ch),
+			"watermark.channels: unknown channel %q", ch)
+	}
+
+	checkURL("upstream.products_url", c.Upstream.ProductsURL)
+	checkURL("upstream.round_url", c.Upstream.RoundURL)
+	checkURL("upstream.universal_url", c.Upstream.UniversalURL)
+	checkURL("upstream.wanneng_url", c.Upstream.WannengURL)
+	checkURLs("upstream.farm_urls", c.Upstream.FarmURLs)
+	checkURLs("upstream.game_urls", c.Upstream.GameURLs)
+	checkURLs("upstream.shop_inkind_urls", c.Upstream.ShopInkindURLs)
+	checkURLs("upstream.shop_virtual_urls", c.Upstream.ShopVirtualURLs)
+
+	check(c.Cache.FeedTTL > 0, "cache.feed_ttl: must be positive")
+	check(c.Cache.FeedStale >= 0, "cache.feed_stale: must not be negative")
+
+	for field, v := range map[string]string{
+		"client.secret_key":      c.Client.SecretKey,
+		"client.secret_value":    c.Client.SecretValue,
+		"client.another_secret":  c.Client.AnotherSecret,
+		"client.act_on_click":    c.Client.ActOnClick,
+		"client.page_token":      c.Client.PageToken,
+		"client.page_random_str": c.Client.PageRandomStr,
+		"client.xiaoyouxi_info":  c.Client.XiaoyouxiInfo,
+	} {
+		check(v != "", "%s: must not be empty", field)
+	}
+	check(len(c.Client.GameParams) > 0, "client.game_params: must not be empty")
+	checkPattern("client.patterns.extract", c.Client.Patterns.Extract)
+	checkPattern("client.patterns.extract_s", c.Client.Patterns.ExtractS)
+	checkVarPattern("client.patterns.var_quoted", c.Client.Patterns.VarQuoted)
+	checkVarPattern("client.patterns.var_unquoted", c.Client.Patterns.VarUnquoted)
+	checkVarPattern("client.patterns.var_json", c.Client.Patterns.VarJSON)
+
+	checkPattern("shop.discovery_pattern", c.Shop.DiscoveryPattern)
+	check(c.Shop.DiscoveryCategory != "", "shop.discovery_category: must not be empty")
+
+	for name, plans := range c.Limits.RateLimits {
+		_, ok := plans[defaultPlan]
+		check(ok, "limits.rate_limits.%s: missing the %s plan", name, defaultPlan)
+		for plan, limit := range plans {
+			check(limit.Interval > 0, "limits.rate_limits.%s.%s.interval: must be positive", name, plan)
+			check(limit.Burst >= 1, "limits.rate_limits.%s.%s.burst: must be at least 1", name, plan)
+		}
+	}
+	for _, name := range []string{rateLimiterPoint, rateLimiterHandshake} {
+		_, ok := c.Limits.RateLimits[name]
+		check(ok, "limits.rate_limits: missing limiter %q used by the gateway", name)
+	}
+	for route, name := range c.Limits.Routes {
+		check(strings.HasPrefix(route, "/"), "limits.routes: %q is not a route path", route)
+		_, ok := c.Limits.RateLimits[name]
+		check(name == "" || ok, "limits.routes.%s: unknown limiter %q", route, name)
+	}
+	_, ok := c.Limits.Quotas[defaultPlan]
+	check(ok, "limits.quotas: missing the %s plan", defaultPlan)
+	for plan, quotas := range c.Limits.Quotas {
+		for feature, limit := range quotas {
+			check(slices.Contains(meteredFeatures, feature), "limits.quotas.%s: unknown feature %q", plan, feature)
+			check(limit >= -1, "limits.quotas.%s.%s: %d must be -1 (unlimited) or at least 0", plan, feature, limit)
+		}
+	}
+
+	slices.Sort(problems)
+	return problems
+}
+
+// applyStartupConfig 设置启动时读取的全局变量，只在 init 中调用
+func applyStartupConfig(c *appConfig) {
+	jwtSecretKey = c.Auth.JWTSecretKey
+	tokenLifetime = time.Duration(c.Auth.TokenLifetime)
+	appIntegritySecret = c.Auth.AppIntegritySecret
+	adminToken = c.Auth.AdminToken
+	targetAliasSecret = c.Auth.TargetAliasSecret
+	serverSigningKey = c.Auth.SigningKey
+	serverSigningNextPublicKey = c.Auth.SigningNextPublicKey
+
+	redisAddress = c.Redis.Address
+	redisPassword = c.Redis.Password
+	swordRedisDB = c.Redis.DB
+	apkRedisDB = c.Redis.ApkDB
+
+	postgresHost = c.Postgres.Host
+	postgresPort = c.Postgres.Port
+	postgresUser = c.Postgres.User
+	postgresPassword = c.Postgres.Password
+	postgresDbname = c.Postgres.DBName
+
+	gatewayTargetsFile = c.Files.GatewayTargets
+	templatesDir = c.Files.TemplatesDir
+	menusFile = c.Files.Menus
+	taskStartID = c.Tasks.StartID
+}
+
+// keepStartupSections 用 running 中只在启动时读取的节替换 c 中的对应节，返回内容不同的节名
+func (c *appConfig) keepStartupSections(running *appConfig) []string {
+	var changed []string
+	if c.Environment != running.Environment {
+		changed = append(changed, "environment")
+		c.Environment = running.Environment
+	}
+	keep := func(name string, next, current any) {
+		if !reflect.DeepEqual(reflect.ValueOf(next).Elem().Interface(), reflect.ValueOf(current).Elem().Interface()) {
+			changed = append(changed, name)
+		}
+		reflect.ValueOf(next).Elem().Set(reflect.ValueOf(current).Elem())
+	}
+	keep("server", &c.Server, &running.Server)
+	keep("redis", &c.Redis, &running.Redis)
+	keep("postgres", &c.Postgres, &running.Postgres)
+	keep("auth", &c.Auth, &running.Auth)
+	keep("files", &c.Files, &running.Files)
+	keep("cron", &c.Cron, &running.Cron)
+	keep("tasks", &c.Tasks, &running.Tasks)
+	return changed
+}
+
+// reloadConfig 重新加载配置，失败时保留当前配置；只在启动时读取的节保持不变
+func reloadConfig() (*loadedConfig, error) {
+	next, err := loadConfig(configFile)
 	if err != nil {
-		log.Printf("无效的 TASK_START_ID 值 '%s'，将使用默认值 44000。错误: %v", taskStartIDStr, err)
-		taskStartID = 44000
+		return nil, err
+	}
+	next.restartPending = next.cfg.keepStartupSections(currentConfig())
+	activeConfig.Store(next)
+	log.Printf("配置已从 %s 重新加载", next.source)
+	for _, section := range next.restartPending {
+		log.Printf("配置节 %s 已修改，重启后生效", section)
 	}
 
-	log.Println("配置已从环境变量加载")
+	// 网关目标由 upstream、client 中的值构建
+	if err := reloadGatewayTargets(); err != nil {
+		log.Printf("配置重新加载后重建网关目标失败: %v", err)
+	}
+	if err := reloadMenus(); err != nil {
+		log.Printf("重新加载客户端菜单失败，继续使用当前菜单: %v", err)
+	}
+	return next, nil
+}
+
+// startConfigReloader 收到 SIGHUP 时重新加载配置，直到 bgCtx 被取消
+func startConfigReloader(bgCtx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-bgCtx.Done():
+			return
+		case <-hup:
+			if _, err := reloadConfig(); err != nil {
+				log.Printf("重新加载配置失败，继续使用当前配置: %v", err)
+			}
+		}
+	}
+}
+
+// redacted 管理接口展示用的副本；secret 类型的字段序列化时已经脱敏，这里另外隐藏下发给客户端的密钥
+func (c *appConfig) redacted() appConfig {
+	out := *c
+	for _, v := range []*string{&out.Client.SecretKey, &out.Client.SecretValue, &out.Client.AnotherSecret} {
+		if *v != "" {
+			*v = redactedValue
+		}
+	}
+	return out
 }
 
 // getEnv 读取一个环境变量，如果不存在则返回一个备用值
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withConfig 在测试期间使用修改后的配置副本
+func withConfig(t *testing.T, modify func(c *appConfig)) {
+	t.Helper()
+	old := activeConfig.Load()
+	cfg := *old.cfg
+	modify(&cfg)
+	next := *old
+	next.cfg = &cfg
+	activeConfig.Store(&next)
+	t.Cleanup(func() { activeConfig.Store(old) })
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	if problems := defaultConfig().validate(); len(problems) > 0 {
+		t.Fatalf("default config is invalid: %v", problems)
+	}
+}
+
+func TestExampleConfigMatchesDefaults(t *testing.T) {
+	loaded, err := loadConfig("config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.cfg, defaultConfig()) {
+		t.Errorf("config.example.yaml differs from defaultConfig():\n got %+v\nwant %+v", loaded.cfg, defaultConfig())
+	}
+}
+
+func TestLoadConfigMergesFileAndEnv(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  addr: ":8080"
+auth:
+  token_lifetime: 30m
+upstream:
+  game_urls:
+    - https://game.example/a
+challenge:
+  mode: always
+`)
+	t.Setenv("CHALLENGE_MODE", "off")
+	t.Setenv("WATERMARK_CHANNELS", "query, regex")
+
+	loaded, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := loaded.cfg
+	if cfg.Server.Addr != ":8080" || time.Duration(cfg.Auth.TokenLifetime) != 30*time.Minute {
+		t.Errorf("file values not applied: addr=%q lifetime=%v", cfg.Server.Addr, time.Duration(cfg.Auth.TokenLifetime))
+	}
+	if len(cfg.Upstream.GameURLs) != 1 || len(cfg.Upstream.FarmURLs) != len(defaultConfig().Upstream.FarmURLs) {
+		t.Errorf("lists should be replaced only when set: game=%v farm=%d", cfg.Upstream.GameURLs, len(cfg.Upstream.FarmURLs))
+	}
+	if cfg.Challenge.Mode != challengeModeOff {
+		t.Errorf("env should override the file, challenge.mode = %q", cfg.Challenge.Mode)
+	}
+	if strings.Join(cfg.Watermark.Channels, ",") != "query,regex" {
+		t.Errorf("watermark.channels = %v", cfg.Watermark.Channels)
+	}
+	if loaded.source != path+" + env" || strings.Join(loaded.envOverrides, ",") != "CHALLENGE_MODE,WATERMARK_CHANNELS" {
+		t.Errorf("source = %q, env overrides = %v", loaded.source, loaded.envOverrides)
+	}
+}
+
+func TestLoadConfigReportsAllProblems(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  addr: "3839"
+cron:
+  usage_rollup: "every hour"
+client:
+  patterns:
+    var_json: "var\\s+(["
+limits:
+  rate_limits:
+    "woo:box_search":
+      pro: {interval: 0s, burst: 30}
+  routes:
+    /apk/submit: "apk:sumbit"
+  quotas:
+    pro:
+      export: -2
+`)
+	t.Setenv("REDIS_DB", "six")
+
+	_, err := loadConfig(path)
+	if err == nil {
+		t.Fatal("invalid config was accepted")
+	}
+	for _, field := range []string{"server.addr", "cron.usage_rollup", "client.patterns.var_json", "REDIS_DB",
+		"limits.rate_limits.woo:box_search.pro.interval", "limits.routes./apk/submit", "limits.quotas.pro.export"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error does not mention %s:\n%v", field, err)
+		}
+	}
+
+	if _, err := loadConfig(writeConfigFile(t, "server:\n  adr: \":80\"\n")); err == nil || !strings.Contains(err.Error(), "adr") {
+		t.Errorf("unknown field should be rejected, err = %v", err)
+	}
+}
+
+func TestConfigRedacted(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Auth.AdminToken = "admin-secret"
+	cfg.Postgres.Password = "pg-secret"
+	out, err := json.Marshal(cfg.redacted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"admin-secret", cfg.Auth.JWTSecretKey.Reveal(), cfg.Postgres.Password.Reveal(), cfg.Client.SecretValue, cfg.Client.AnotherSecret, `"secret_key":"secret"`} {
+		if strings.Contains(string(out), secret) {
+			t.Errorf("redacted config contains %q", secret)
+		}
+	}
+	if !strings.Contains(string(out), `"token_lifetime":"12h0m0s"`) || cfg.Auth.AdminToken != "admin-secret" {
+		t.Errorf("redacted() should copy the config: %s", out)
+	}
+	if cfg.redacted().Auth.SigningKey != "" {
+		t.Error("empty secrets should stay empty")
+	}
+}
+
+func TestKeepStartupSections(t *testing.T) {
+	running := defaultConfig()
+	next := defaultConfig()
+	next.Server.Addr = ":9000"
+	next.Redis.DB = 3
+	next.Challenge.Mode = challengeModeAlways
+
+	changed := next.keepStartupSections(running)
+	if strings.Join(changed, ",") != "server,redis" {
+		t.Errorf("changed = %v", changed)
+	}
+	if next.Server.Addr != ":3839" || next.Redis.DB != 0 || next.Challenge.Mode != challengeModeAlways {
+		t.Errorf("startup sections should be kept and runtime sections applied: %+v %+v %+v", next.Server, next.Redis, next.Challenge)
+	}
+}
@@ -2,22 +2,28 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var dbPool *pgxpool.Pool
 
 func initDB() {
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s",
-		postgresUser,
-		postgresPassword,
-		postgresHost,
-		postgresPort,
-		postgresDbname)
+	// 密码来自密钥文件时可能包含任意字符，由 url.URL 负责转义
+	connURL := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(postgresUser, postgresPassword.Reveal()),
+		Host:   net.JoinHostPort(postgresHost, postgresPort),
+		Path:   "/" + postgresDbname,
+	}
+	connStr := connURL.String()
 
 	pool, err := pgxpool.New(context.Background(), connStr)
 	if err != nil {
@@ -78,6 +84,80 @@ func setupSchema() error {
 				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
 				UNIQUE(tap_uid, article_id)
 			);`,
+		"usage_daily": `
+			CREATE TABLE IF NOT EXISTS usage_daily (
+				day DATE NOT NULL,
+				user_key VARCHAR(32) NOT NULL,
+				feature TEXT NOT NULL,
+				count BIGINT NOT NULL DEFAULT 0,
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				PRIMARY KEY (day, user_key, feature)
+			);`,
+		"reports": `
+			CREATE TABLE IF NOT EXISTS reports (
+				id VARCHAR(32) PRIMARY KEY,            -- 分享用的随机 ID
+				user_key VARCHAR(32) NOT NULL,
+				template TEXT NOT NULL,
+				template_version TEXT NOT NULL,
+				data JSONB NOT NULL,                   -- 客户端提交的统计数据，模板更新后可重新渲染
+				html TEXT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);`,
+		"runs": `
+			CREATE TABLE IF NOT EXISTS runs (
+				id BIGSERIAL PRIMARY KEY,
+				user_key VARCHAR(32) NOT NULL,
+				run_id TEXT,                           -- 客户端生成的 ID，用于重试去重
+				kind TEXT NOT NULL,                    -- farm / round / game / lottery
+				popcorn_total BIGINT NOT NULL DEFAULT 0,
+				popcorn_gained INT NOT NULL DEFAULT 0,
+				tasks INT NOT NULL DEFAULT 0,
+				rounds INT NOT NULL DEFAULT 0,
+				draws INT NOT NULL DEFAULT 0,
+				games INT NOT NULL DEFAULT 0,
+				duration_minutes INT NOT NULL DEFAULT 0,
+				rewards JSONB NOT NULL DEFAULT '[]',   -- [{name, source, num}]
+				ran_at TIMESTAMPTZ NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				UNIQUE(user_key, run_id)
+			);`,
+		"shop_products": `
+			CREATE TABLE IF NOT EXISTS shop_products (
+				id SERIAL PRIMARY KEY,
+				product_id TEXT NOT NULL,              -- 商店商品 ID
+				name TEXT NOT NULL,                    -- 下发给客户端的商品名
+				link TEXT NOT NULL,
+				category TEXT NOT NULL,                -- 对应网关目标，如 pp、dg
+				enabled BOOLEAN NOT NULL DEFAULT TRUE,
+				valid_from TIMESTAMPTZ,
+				valid_until TIMESTAMPTZ,
+				source TEXT NOT NULL DEFAULT 'admin',  -- seed / admin / discovered
+				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				UNIQUE(category, name),
+				UNIQUE(category, product_id)
+			);`,
+		"shop_feed_snapshots": `
+			CREATE TABLE IF NOT EXISTS shop_feed_snapshots (
+				feed TEXT NOT NULL,                    -- products / rounds
+				item_id TEXT NOT NULL,                 -- 商品 ID
+				name TEXT NOT NULL DEFAULT '',
+				data JSONB NOT NULL,
+				hash TEXT NOT NULL,                    -- data 的 SHA-256
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				PRIMARY KEY(feed, item_id)
+			);`,
+		"shop_feed_changes": `
+			CREATE TABLE IF NOT EXISTS shop_feed_changes (
+				id BIGSERIAL PRIMARY KEY,
+				feed TEXT NOT NULL,
+				item_id TEXT NOT NULL,
+				change TEXT NOT NULL,                  -- added / removed / changed
+				name TEXT NOT NULL DEFAULT '',
+				before_data JSONB,
+				after_data JSONB,
+				detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			);`,
 	}
 
 	// 创建所有表
@@ -109,6 +189,20 @@ func setupSchema() error {
 		`CREATE INDEX IF NOT EXISTS idx_user_records_tap_uid ON user_records (tap_uid);`,
 		`CREATE INDEX IF NOT EXISTS idx_tap_user_records_tap_uid ON tap_user_records (tap_uid);`,
 		`CREATE INDEX IF NOT EXISTS idx_tap_user_records_article_id ON tap_user_records (article_id);`,
+
+		// 用量报表按 Key 查询
+		`CREATE INDEX IF NOT EXISTS idx_usage_daily_user_key ON usage_daily (user_key, day);`,
+
+		// 报告按 Key 列出、按创建时间清理
+		`CREATE INDEX IF NOT EXISTS idx_reports_user_key ON reports (user_key, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_reports_created_at ON reports (created_at);`,
+
+		// 运行历史与趋势按 Key、类型与时间查询
+		`CREATE INDEX IF NOT EXISTS idx_runs_user_kind_ran_at ON runs (user_key, kind, ran_at);`,
+
+		// 商店数据变更按时间查询与清理
+		`CREATE INDEX IF NOT EXISTS idx_shop_feed_changes_detected_at ON shop_feed_changes (detected_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_shop_feed_changes_feed_id ON shop_feed_changes (feed, id);`,
 	}
 
 	for _, sql := range indexes {
@@ -356,3 +450,480 @@ func getUserActivitiesClosed(userKey string, limit, offset int) ([]Activity, err
 
 	return activities, nil
 }
+
+// streamAllActivities 按 activity_id 顺序逐行读取全部活动并交给 fn 处理，不在内存中累积结果
+func streamAllActivities(ctx context.Context, fn func(Activity) error) error {
+	query := `
+		SELECT activity_id, article_id::text as article_id, awards, conditions, title, link_title, game_name, author_name, cover, draw_time, publish_time
+		FROM activity_results
+		ORDER BY activity_id ASC
+	`
+
+	rows, err := dbPool.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("查询活动失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var act Activity
+		if err := rows.Scan(&act.ActivityID, &act.ArticleID, &act.Awards, &act.Conditions, &act.Title, &act.LinkTitle, &act.GameName, &act.AuthorName, &act.Cover, &act.DrawTime, &act.PublishTime); err != nil {
+			return fmt.Errorf("扫描活动数据失败: %w", err)
+		}
+		if err := fn(act); err != nil {
+			return err
+		}
+	}
+
+	if rows.Err() != nil {
+		return fmt.Errorf("处理查询结果时出错: %w", rows.Err())
+	}
+
+	return nil
+}
+
+// upsertUsageDaily 写入每日用量，Redis 中的计数是权威值，直接覆盖已有记录
+func upsertUsageDaily(records []UsageRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, r := range records {
+		batch.Queue(`
+			INSERT INTO usage_daily (day, user_key, feature, count, updated_at)
+			VALUES ($1::date, $2, $3, $4, NOW())
+			ON CONFLICT (day, user_key, feature) DO UPDATE SET count = EXCLUDED.count, updated_at = NOW()
+		`, r.Day, r.UserKey, r.Feature, r.Count)
+	}
+
+	if err := dbPool.SendBatch(context.Background(), batch).Close(); err != nil {
+		return fmt.Errorf("写入用量失败: %w", err)
+	}
+	return nil
+}
+
+// getUsageDaily 查询 [from, to] 日期范围内的用量，userKey 为空时返回所有 Key
+func getUsageDaily(userKey, from, to string) ([]UsageRecord, error) {
+	query := `
+		SELECT day::text, user_key, feature, count
+		FROM usage_daily
+		WHERE day BETWEEN $1::date AND $2::date AND ($3 = '' OR user_key = $3)
+		ORDER BY day ASC, user_key ASC, feature ASC
+	`
+
+	rows, err := dbPool.Query(context.Background(), query, from, to, userKey)
+	if err != nil {
+		return nil, fmt.Errorf("查询用量失败: %w", err)
+	}
+	defer rows.Close()
+
+	records := []UsageRecord{}
+	for rows.Next() {
+		var r UsageRecord
+		if err := rows.Scan(&r.Day, &r.UserKey, &r.Feature, &r.Count); err != nil {
+			return nil, fmt.Errorf("扫描用量数据失败: %w", err)
+		}
+		records = append(records, r)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("处理查询结果时出错: %w", rows.Err())
+	}
+
+	return records, nil
+}
+
+// insertReport 保存一份渲染好的报告
+func insertReport(r StoredReport, data []byte) error {
+	_, err := dbPool.Exec(context.Background(), `
+		INSERT INTO reports (id, user_key, template, template_version, data, html, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, r.ID, r.UserKey, r.Template, r.TemplateVersion, data, r.HTML, r.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("保存报告失败: %w", err)
+	}
+	return nil
+}
+
+// getReport 按 ID 读取报告，不存在时返回 pgx.ErrNoRows
+func getReport(id string) (StoredReport, error) {
+	var r StoredReport
+	err := dbPool.QueryRow(context.Background(), `
+		SELECT id, user_key, template, template_version, html, created_at
+		FROM reports
+		WHERE id = $1
+	`, id).Scan(&r.ID, &r.UserKey, &r.Template, &r.TemplateVersion, &r.HTML, &r.CreatedAt)
+	return r, err
+}
+
+// deleteReportsBefore 删除 before 之前创建的报告，返回删除的数量
+func deleteReportsBefore(before time.Time) (int64, error) {
+	tag, err := dbPool.Exec(context.Background(), `DELETE FROM reports WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("清理报告失败: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// insertRun 写入一次运行，返回记录 ID；相同 (user_key, run_id) 已存在时返回已有记录的 ID
+func insertRun(userKey string, r RunRecord) (int64, error) {
+	rewards, err := json.Marshal(r.Rewards)
+	if err != nil {
+		return 0, err
+	}
+	var runID *string
+	if r.RunID != "" {
+		runID = &r.RunID
+	}
+
+	var id int64
+	err = dbPool.QueryRow(context.Background(), `
+		INSERT INTO runs (user_key, run_id, kind, popcorn_total, popcorn_gained, tasks, rounds, draws, games, duration_minutes, rewards, ran_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (user_key, run_id) DO UPDATE SET run_id = EXCLUDED.run_id
+		RETURNING id
+	`, userKey, runID, r.Kind, r.PopcornTotal, r.PopcornGained, r.Tasks, r.Rounds, r.Draws, r.Games, r.DurationMinutes, rewards, r.RanAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("保存运行记录失败: %w", err)
+	}
+	return id, nil
+}
+
+// getRuns 按运行时间倒序分页查询运行记录，kind 为空时返回所有类型
+func getRuns(userKey, kind string, limit, offset int) ([]RunRecord, error) {
+	query := `
+		SELECT id, COALESCE(run_id, ''), kind, popcorn_total, popcorn_gained, tasks, rounds, draws, games, duration_minutes, rewards, ran_at
+		FROM runs
+		WHERE user_key = $1 AND ($2 = '' OR kind = $2)
+		ORDER BY ran_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := dbPool.Query(context.Background(), query, userKey, kind, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("查询运行记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []RunRecord{}
+	for rows.Next() {
+		var r RunRecord
+		if err := rows.Scan(&r.ID, &r.RunID, &r.Kind, &r.PopcornTotal, &r.PopcornGained, &r.Tasks, &r.Rounds, &r.Draws, &r.Games, &r.DurationMinutes, &r.Rewards, &r.RanAt); err != nil {
+			return nil, fmt.Errorf("扫描运行记录失败: %w", err)
+		}
+		runs = append(runs, r)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("处理查询结果时出错: %w", rows.Err())
+	}
+
+	return runs, nil
+}
+
+// getRunTrendBuckets 按北京时间的天或周汇总 [from, to) 内的运行，平均值由调用方计算
+func getRunTrendBuckets(userKey, kind, period string, from, to time.Time) ([]RunTrendBucket, error) {
+	query := `
+		SELECT date_trunc($3, ran_at AT TIME ZONE 'Asia/Shanghai')::date::text AS bucket,
+		       COUNT(*), COALESCE(SUM(popcorn_gained), 0), COALESCE(SUM(draws), 0),
+		       COALESCE(SUM(tasks), 0), COALESCE(SUM(games), 0), COALESCE(SUM(jsonb_array_length(rewards)), 0)
+		FROM runs
+		WHERE user_key = $1 AND kind = $2 AND ran_at >= $4 AND ran_at < $5
+		GROUP BY bucket
+		ORDER BY bucket ASC
+	`
+
+	rows, err := dbPool.Query(context.Background(), query, userKey, kind, period, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("查询运行趋势失败: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []RunTrendBucket{}
+	for rows.Next() {
+		var b RunTrendBucket
+		if err := rows.Scan(&b.Start, &b.Runs, &b.PopcornGained, &b.Draws, &b.Tasks, &b.Games, &b.Rewards); err != nil {
+			return nil, fmt.Errorf("扫描运行趋势失败: %w", err)
+		}
+		buckets = append(buckets, b)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("处理查询结果时出错: %w", rows.Err())
+	}
+
+	return buckets, nil
+}
+
+// getRewardFrequencies 统计 [from, to) 内各奖品的获得数量与获得过该奖品的运行数，按运行数取前 limit 个
+func getRewardFrequencies(userKey, kind string, from, to time.Time, limit int) ([]RewardFrequency, error) {
+	query := `
+		SELECT r->>'name', COALESCE(r->>'source', ''), COALESCE(SUM((r->>'num')::int), 0), COUNT(DISTINCT runs.id)
+		FROM runs, jsonb_array_elements(runs.rewards) AS r
+		WHERE user_key = $1 AND kind = $2 AND ran_at >= $3 AND ran_at < $4
+		GROUP BY 1, 2
+		ORDER BY 4 DESC, 3 DESC, 1 ASC
+		LIMIT $5
+	`
+
+	rows, err := dbPool.Query(context.Background(), query, userKey, kind, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询奖品频率失败: %w", err)
+	}
+	defer rows.Close()
+
+	rewards := []RewardFrequency{}
+	for rows.Next() {
+		var f RewardFrequency
+		if err := rows.Scan(&f.Name, &f.Source, &f.Count, &f.Runs); err != nil {
+			return nil, fmt.Errorf("扫描奖品频率失败: %w", err)
+		}
+		rewards = append(rewards, f)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("处理查询结果时出错: %w", rows.Err())
+	}
+
+	return rewards, nil
+}
+
+const shopProductColumns = `id, product_id, name, link, category, enabled, valid_from, valid_until, source, created_at, updated_at`
+
+func scanShopProduct(row pgx.Row) (ShopProduct, error) {
+	var p ShopProduct
+	err := row.Scan(&p.ID, &p.ProductID, &p.Name, &p.Link, &p.Category, &p.Enabled, &p.ValidFrom, &p.ValidUntil, &p.Source, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// listShopProducts 查询商品，category 为空时返回所有分类，onlyEnabled 时只返回启用的商品
+func listShopProducts(category string, onlyEnabled bool) ([]ShopProduct, error) {
+	query := `
+		SELECT ` + shopProductColumns + `
+		FROM shop_products
+		WHERE ($1 = '' OR category = $1) AND (NOT $2 OR enabled)
+		ORDER BY category ASC, id ASC
+	`
+
+	rows, err := dbPool.Query(context.Background(), query, category, onlyEnabled)
+	if err != nil {
+		return nil, fmt.Errorf("查询商品失败: %w", err)
+	}
+	defer rows.Close()
+
+	products := []ShopProduct{}
+	for rows.Next() {
+		p, err := scanShopProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描商品数据失败: %w", err)
+		}
+		products = append(products, p)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("处理查询结果时出错: %w", rows.Err())
+	}
+
+	return products, nil
+}
+
+// getShopProduct 按 ID 读取商品，不存在时返回 pgx.ErrNoRows
+func getShopProduct(id int64) (ShopProduct, error) {
+	return scanShopProduct(dbPool.QueryRow(context.Background(),
+		`SELECT `+shopProductColumns+` FROM shop_products WHERE id = $1`, id))
+}
+
+// insertShopProduct 新增商品，返回写入后的记录
+func insertShopProduct(p ShopProduct) (ShopProduct, error) {
+	return scanShopProduct(dbPool.QueryRow(context.Background(), `
+		INSERT INTO shop_products (product_id, name, link, category, enabled, valid_from, valid_until, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+shopProductColumns,
+		p.ProductID, p.Name, p.Link, p.Category, p.Enabled, p.ValidFrom, p.ValidUntil, p.Source))
+}
+
+// updateShopProduct 更新商品的全部可编辑字段，不存在时返回 pgx.ErrNoRows
+func updateShopProduct(p ShopProduct) (ShopProduct, error) {
+	return scanShopProduct(dbPool.QueryRow(context.Background(), `
+		UPDATE shop_products
+		SET product_id = $2, name = $3, link = $4, category = $5, enabled = $6, valid_from = $7, valid_until = $8, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+shopProductColumns,
+		p.ID, p.ProductID, p.Name, p.Link, p.Category, p.Enabled, p.ValidFrom, p.ValidUntil))
+}
+
+// deleteShopProduct 删除商品，返回是否存在
+func deleteShopProduct(id int64) (bool, error) {
+	tag, err := dbPool.Exec(context.Background(), `DELETE FROM shop_products WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("删除商品失败: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// insertShopProductsIfAbsent 批量写入商品，已存在（同分类下商品 ID 或名称相同）的跳过，返回新写入的数量
+func insertShopProductsIfAbsent(products []ShopProduct) (int64, error) {
+	if len(products) == 0 {
+		return 0, nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, p := range products {
+		batch.Queue(`
+			INSERT INTO shop_products (product_id, name, link, category, enabled, source)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT DO NOTHING
+		`, p.ProductID, p.Name, p.Link, p.Category, p.Enabled, p.Source)
+	}
+
+	results := dbPool.SendBatch(context.Background(), batch)
+	defer results.Close()
+	var inserted int64
+	for range products {
+		tag, err := results.Exec()
+		if err != nil {
+			return inserted, fmt.Errorf("批量写入商品失败: %w", err)
+		}
+		inserted += tag.RowsAffected()
+	}
+	return inserted, nil
+}
+
+// seedShopProducts 表为空时写入内置商品
+func seedShopProducts(products []ShopProduct) error {
+	var n int64
+	if err := dbPool.QueryRow(context.Background(), `SELECT COUNT(*) FROM shop_products`).Scan(&n); err != nil {
+		return fmt.Errorf("查询商品数量失败: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := insertShopProductsIfAbsent(products)
+	return err
+}
+
+// updateShopFeedSnapshot 在一个事务中读取快照、由 diff 计算变更并写回
+// 事务持有该数据的 advisory 锁：多个实例同时刷新时依次执行，后执行的实例看到的是前一个保存的快照，不会重复记录变更
+func updateShopFeedSnapshot(feed string, current map[string]shopFeedItem, diff func(previous map[string]shopFeedItem) (changes []ShopFeedChange, record bool)) error {
+	ctx := context.Background()
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "shop_feed_snapshots:"+feed); err != nil {
+		return fmt.Errorf("锁定商店数据快照失败: %w", err)
+	}
+	previous, err := loadShopFeedSnapshot(ctx, tx, feed)
+	if err != nil {
+		return err
+	}
+	changes, record := diff(previous)
+	if err := saveShopFeedChanges(ctx, tx, feed, current, changes, record); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("保存商店数据变更失败: %w", err)
+	}
+	return nil
+}
+
+// loadShopFeedSnapshot 读取一份数据上一次保存的快照，键为条目 ID
+func loadShopFeedSnapshot(ctx context.Context, tx pgx.Tx, feed string) (map[string]shopFeedItem, error) {
+	rows, err := tx.Query(ctx, `SELECT item_id, name, data, hash FROM shop_feed_snapshots WHERE feed = $1`, feed)
+	if err != nil {
+		return nil, fmt.Errorf("查询商店数据快照失败: %w", err)
+	}
+	defer rows.Close()
+
+	items := map[string]shopFeedItem{}
+	for rows.Next() {
+		var id string
+		var item shopFeedItem
+		if err := rows.Scan(&id, &item.Name, &item.Data, &item.Hash); err != nil {
+			return nil, fmt.Errorf("读取商店数据快照失败: %w", err)
+		}
+		items[id] = item
+	}
+	return items, rows.Err()
+}
+
+// saveShopFeedChanges 按 changes 更新快照，record 为 true 时同时写入变更记录
+func saveShopFeedChanges(ctx context.Context, tx pgx.Tx, feed string, current map[string]shopFeedItem, changes []ShopFeedChange, record bool) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	batch := &pgx.Batch{}
+	for _, ch := range changes {
+		if record {
+			batch.Queue(`
+				INSERT INTO shop_feed_changes (feed, item_id, change, name, before_data, after_data, detected_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+			`, feed, ch.ItemID, ch.Change, ch.Name, nullableJSON(ch.Before), nullableJSON(ch.After), now)
+		}
+		if ch.Change == shopFeedRemoved {
+			batch.Queue(`DELETE FROM shop_feed_snapshots WHERE feed = $1 AND item_id = $2`, feed, ch.ItemID)
+			continue
+		}
+		item := current[ch.ItemID]
+		batch.Queue(`
+			INSERT INTO shop_feed_snapshots (feed, item_id, name, data, hash, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (feed, item_id) DO UPDATE
+			SET name = EXCLUDED.name, data = EXCLUDED.data, hash = EXCLUDED.hash, updated_at = EXCLUDED.updated_at
+		`, feed, ch.ItemID, item.Name, string(item.Data), item.Hash, now)
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("保存商店数据变更失败: %w", err)
+	}
+	return nil
+}
+
+// nullableJSON 空的 JSON 写为 NULL
+func nullableJSON(data json.RawMessage) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
+// getShopFeedChanges since 之后、id 大于 afterID 的变更，按 id 升序；feed 为空时不限数据
+func getShopFeedChanges(since time.Time, feed string, afterID int64, limit int) ([]ShopFeedChange, error) {
+	rows, err := dbPool.Query(context.Background(), `
+		SELECT id, feed, item_id, change, name, before_data, after_data, detected_at
+		FROM shop_feed_changes
+		WHERE detected_at > $1 AND id > $2 AND ($3 = '' OR feed = $3)
+		ORDER BY id
+		LIMIT $4
+	`, since, afterID, feed, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询商店数据变更失败: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []ShopFeedChange{}
+	for rows.Next() {
+		var ch ShopFeedChange
+		var before, after []byte
+		if err := rows.Scan(&ch.ID, &ch.Feed, &ch.ItemID, &ch.Change, &ch.Name, &before, &after, &ch.DetectedAt); err != nil {
+			return nil, fmt.Errorf("读取商店数据变更失败: %w", err)
+		}
+		ch.Before, ch.After = before, after
+		changes = append(changes, ch)
+	}
+	return changes, rows.Err()
+}
+
+// deleteShopFeedChangesBefore 清理 before 之前的变更记录，返回删除的数量
+func deleteShopFeedChangesBefore(before time.Time) (int64, error) {
+	tag, err := dbPool.Exec(context.Background(), `DELETE FROM shop_feed_changes WHERE detected_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("清理商店数据变更失败: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
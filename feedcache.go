@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// --- 上游商店数据缓存 ---
+//
+// 转盘列表（GetRound）需要 products.js 与 classify_24.js 两份数据，每次都下载会让一批并发请求变成一批相同的上游请求。
+// 两份数据解析后分别缓存在 Redis 的 cache:feed:<名称> 中，所有实例共享：
+//   - 缓存在 cache.feed_ttl 内直接返回（hit）；
+//   - 过期但仍在 cache.feed_stale 内时立即返回旧数据（stale），同时在后台刷新；刷新失败时继续使用旧数据；
+//   - 没有可用的缓存时同步下载（miss），本实例内同一份数据的并发下载合并为一次（singleflight）。
+// 各状态的次数通过 expvar 的 feed_cache 公布，可在 GET /admin/metrics 查看。
+
+const feedCacheKeyPrefix = "cache:feed:"
+
+// 缓存状态，同时是 feed_cache 指标的名称后缀
+const (
+	feedCacheHit        = "hit"
+	feedCacheStale      = "stale"
+	feedCacheMiss       = "miss"
+	feedCacheFetchError = "fetch_error"
+	feedCacheShared     = "shared"
+)
+
+// feedCacheStats feed_cache 指标，键为 <数据名称>.<状态>
+var feedCacheStats = expvar.NewMap("feed_cache")
+
+// feedCacheStore 缓存条目的存储，默认使用 Redis；测试中替换为内存实现
+type feedCacheStore interface {
+	// get 读取条目，不存在时 ok 为 false
+	get(key string) (data []byte, ok bool, err error)
+	set(key string, data []byte, ttl time.Duration) error
+}
+
+type redisFeedCacheStore struct{}
+
+func (redisFeedCacheStore) get(key string) ([]byte, bool, error) {
+	data, err := swordRdb.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	return data, err == nil, err
+}
+
+func (redisFeedCacheStore) set(key string, data []byte, ttl time.Duration) error {
+	return swordRdb.Set(ctx, key, data, ttl).Err()
+}
+
+var feedStore feedCacheStore = redisFeedCacheStore{}
+
+// cachedFeedEntry Redis 中保存的条目
+type cachedFeedEntry[T any] struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Data      T         `json:"data"`
+}
+
+// feedCache 一份上游数据的缓存
+type feedCache[T any] struct {
+	name  string
+	fetch func() (T, error)
+	group singleflight.Group
+}
+
+// shopProductsFeed products.js 中的全部商品，键为商品 ID
+var shopProductsFeed = &feedCache[map[string]map[string]any]{
+	name: "products",
+	fetch: func() (map[string]map[string]any, error) {
+		return GetAllProducts(strconv.FormatInt(time.Now().Unix(), 10))
+	},
+}
+
+// shopRoundsFeed classify_24.js 中的转盘商品
+var shopRoundsFeed = &feedCache[[]ProductRound]{
+	name: "rounds",
+	fetch: func() ([]ProductRound, error) {
+		return GetOnlyRound(strconv.FormatInt(time.Now().Unix(), 10))
+	},
+}
+
+// get 按缓存状态返回数据，见文件头的说明
+func (f *feedCache[T]) get() (T, error) {
+	cfg := currentConfig().Cache
+	ttl, stale := time.Duration(cfg.FeedTTL), time.Duration(cfg.FeedStale)
+
+	if entry, ok := f.load(); ok {
+		age := time.Since(entry.FetchedAt)
+		if age < ttl {
+			f.count(feedCacheHit)
+			return entry.Data, nil
+		}
+		if age < ttl+stale {
+			f.count(feedCacheStale)
+			log.Printf("上游数据 %s 已过期 %s，返回旧数据并在后台刷新", f.name, (age - ttl).Round(time.Second))
+			go f.refresh()
+			return entry.Data, nil
+		}
+	}
+
+	f.count(feedCacheMiss)
+	return f.refresh()
+}
+
+// refresh 下载并写入缓存；同一时间只有一个下载，其它调用者等待并共享结果
+func (f *feedCache[T]) refresh() (T, error) {
+	v, err, shared := f.group.Do(f.name, func() (any, error) {
+		start := time.Now()
+		data, err := f.fetch()
+		if err != nil {
+			f.count(feedCacheFetchError)
+			log.Printf("下载上游数据 %s 失败: %v", f.name, err)
+			return nil, err
+		}
+		log.Printf("上游数据 %s 已刷新，用时 %s", f.name, time.Since(start).Round(time.Millisecond))
+		f.save(cachedFeedEntry[T]{FetchedAt: time.Now(), Data: data})
+		return data, nil
+	})
+	if shared {
+		f.count(feedCacheShared)
+	}
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// load 读取缓存；Redis 出错或条目损坏时视为没有缓存
+func (f *feedCache[T]) load() (cachedFeedEntry[T], bool) {
+	var entry cachedFeedEntry[T]
+	raw, ok, err := feedStore.get(feedCacheKeyPrefix + f.name)
+	if err != nil {
+		log.Printf("读取上游数据 %s 的缓存失败: %v", f.name, err)
+		return entry, false
+	}
+	if !ok {
+		return entry, false
+	}
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		log.Printf("上游数据 %s 的缓存已损坏: %v", f.name, err)
+		return entry, false
+	}
+	return entry, true
+}
+
+// save 写入缓存，保留到旧数据也不再可用为止
+func (f *feedCache[T]) save(entry cachedFeedEntry[T]) {
+	cfg := currentConfig().Cache
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("序列化上游数据 %s 失败: %v", f.name, err)
+		return
+	}
+	if err := feedStore.set(feedCacheKeyPrefix+f.name, raw, time.Duration(cfg.FeedTTL+cfg.FeedStale)); err != nil {
+		log.Printf("写入上游数据 %s 的缓存失败: %v", f.name, err)
+	}
+}
+
+func (f *feedCache[T]) count(status string) {
+	feedCacheStats.Add(f.name+"."+status, 1)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type memoryFeedCacheStore struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func (s *memoryFeedCacheStore) get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.entries[key]
+	return data, ok, nil
+}
+
+func (s *memoryFeedCacheStore) set(key string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = data
+	return nil
+}
+
+func withMemoryFeedStore(t *testing.T) *memoryFeedCacheStore {
+	t.Helper()
+	store := &memoryFeedCacheStore{entries: map[string][]byte{}}
+	old := feedStore
+	feedStore = store
+	t.Cleanup(func() { feedStore = old })
+	withConfig(t, func(c *appConfig) {
+		c.Cache = cacheConfig{FeedTTL: duration(time.Minute), FeedStale: duration(10 * time.Minute)}
+	})
+	return store
+}
+
+func TestFeedCacheCoalescesMisses(t *testing.T) {
+	withMemoryFeedStore(t)
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	feed := &feedCache[[]string]{name: "test", fetch: func() ([]string, error) {
+		fetches.Add(1)
+		<-release
+		return []string{"a"}, nil
+	}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := feed.get(); err != nil || len(got) != 1 {
+				t.Errorf("get = %v, %v", got, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched %d times, want 1", n)
+	}
+	// 之后的请求命中缓存
+	if _, err := feed.get(); err != nil || fetches.Load() != 1 {
+		t.Errorf("second get fetched again: %v", err)
+	}
+}
+
+func TestFeedCacheServesStaleOnUpstreamError(t *testing.T) {
+	store := withMemoryFeedStore(t)
+	refreshed := make(chan struct{}, 1)
+	feed := &feedCache[[]string]{name: "test", fetch: func() ([]string, error) {
+		refreshed <- struct{}{}
+		return nil, errors.New("upstream down")
+	}}
+
+	raw, _ := json.Marshal(cachedFeedEntry[[]string]{FetchedAt: time.Now().Add(-2 * time.Minute), Data: []string{"old"}})
+	store.set(feedCacheKeyPrefix+"test", raw, 0)
+
+	got, err := feed.get()
+	if err != nil || len(got) != 1 || got[0] != "old" {
+		t.Fatalf("get = %v, %v, want stale data", got, err)
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("stale entry did not trigger a background refresh")
+	}
+
+	// 超出 feed_stale 的条目不再使用
+	raw, _ = json.Marshal(cachedFeedEntry[[]string]{FetchedAt: time.Now().Add(-time.Hour), Data: []string{"old"}})
+	store.set(feedCacheKeyPrefix+"test", raw, 0)
+	if _, err := feed.get(); err == nil {
+		t.Error("expected the upstream error once the entry is too old")
+	}
+}
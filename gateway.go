@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// --- 网关目标注册表 ---
+//
+// /api/v1/gateway、/api/v1/lucy、/api/v1/david 按请求体中的 target（及可选的 p）返回配置。
+// 目标的内置定义见 gateway_defaults.go；GATEWAY_TARGETS_FILE 指定的 YAML 文件可以覆盖、新增或禁用目标，
+// 文件修改后 gatewayReloadInterval 内自动生效，也可以调用 POST /admin/gateway/reload 立即重新加载。
+// 加载失败时继续使用上一份注册表。
+//
+// 目标的取值类型（kind）：
+//   - string: 字符串；
+//   - list: 字符串列表，列表项可以单独声明 require，Key 缺少权限时该项不返回；
+//   - map: 字符串到字符串的映射；
+//   - object: 任意结构，原样编码为 JSON；
+//   - provider: 由代码中注册的 gatewayProviders 计算，如转盘列表；
+//   - template: templates.go 中的报告模板原文，支持 If-None-Match 条件请求。
+// string、list、map 目标可以用 decoy 声明影子封禁时的诱饵（url、token 或 none），见 shadow.go。
+// 带 params 的目标按请求中的 p 选择子目标，子目标继承父目标的 require、watermark 与 decoy。
+
+const (
+	gatewayEndpointGateway = "gateway"
+	gatewayEndpointLucy    = "lucy"
+	gatewayEndpointDavid   = "david"
+
+	gatewayKindString   = "string"
+	gatewayKindList     = "list"
+	gatewayKindMap      = "map"
+	gatewayKindObject   = "object"
+	gatewayKindProvider = "provider"
+	gatewayKindTemplate = "template"
+
+	gatewayReloadInterval = 10 * time.Second
+)
+
+// gatewayRequest 网关请求体
+type gatewayRequest struct {
+	Target string   `json:"target"`
+	Param  string   `json:"p,omitempty"`      // Optional parameter
+	Params []string `json:"params,omitempty"` // Variable-length string array parameters
+}
+
+// gatewayListItem list 目标中的一项；YAML 中可以写成字符串或 {value, require}
+type gatewayListItem struct {
+	Value   string       `yaml:"value"`
+	Require []permission `yaml:"require,omitempty"`
+}
+
+func (item *gatewayListItem) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&item.Value)
+	}
+	type plain gatewayListItem
+	return node.Decode((*plain)(item))
+}
+
+// gatewayTarget 一个网关目标
+type gatewayTarget struct {
+	Kind       string                    `yaml:"kind,omitempty"`
+	Require    []permission              `yaml:"require,omitempty"`
+	Watermark  string                    `yaml:"watermark,omitempty"`
+	Decoy      string                    `yaml:"decoy,omitempty"`
+	String     string                    `yaml:"-"`
+	List       []gatewayListItem         `yaml:"-"`
+	Map        map[string]string         `yaml:"-"`
+	Object     any                       `yaml:"-"`
+	Provider   string                    `yaml:"provider,omitempty"`
+	Template   string                    `yaml:"template,omitempty"`
+	Args       map[string]string         `yaml:"args,omitempty"`
+	Params     map[string]*gatewayTarget `yaml:"params,omitempty"`
+	ParamError string                    `yaml:"param_error,omitempty"`
+	// Disabled 仅用于 YAML 文件，删除同名的内置目标
+	Disabled bool `yaml:"disabled,omitempty"`
+}
+
+// UnmarshalYAML 按 kind 解码 value 字段
+func (t *gatewayTarget) UnmarshalYAML(node *yaml.Node) error {
+	type plain gatewayTarget
+	var spec struct {
+		plain `yaml:",inline"`
+		Value yaml.Node `yaml:"value"`
+	}
+	if err := node.Decode(&spec); err != nil {
+		return err
+	}
+	*t = gatewayTarget(spec.plain)
+
+	if spec.Value.Kind == 0 {
+		return nil
+	}
+	switch t.Kind {
+	case gatewayKindString:
+		return spec.Value.Decode(&t.String)
+	case gatewayKindList:
+		return spec.Value.Decode(&t.List)
+	case gatewayKindMap:
+		return spec.Value.Decode(&t.Map)
+	case gatewayKindObject:
+		return spec.Value.Decode(&t.Object)
+	}
+	return fmt.Errorf("line %d: kind %q does not take a value", spec.Value.Line, t.Kind)
+}
+
+// validate 检查取值类型、provider、权限、水印通道与诱饵
+func (t *gatewayTarget) validate() error {
+	for _, p := range t.Require {
+		if !slices.Contains(allPermissions, p) {
+			return fmt.Errorf("unknown permission %q", p)
+		}
+	}
+	switch t.Watermark {
+	case "", watermarkChannelQuery, watermarkChannelRegex, watermarkChannelOrder:
+	default:
+		return fmt.Errorf("unknown watermark channel %q", t.Watermark)
+	}
+	switch t.Decoy {
+	case "", gatewayDecoyNone:
+	case gatewayDecoyURL, gatewayDecoyToken:
+		if t.Kind != "" && t.Kind != gatewayKindString && t.Kind != gatewayKindList && t.Kind != gatewayKindMap {
+			return fmt.Errorf("decoy %q requires a string, list or map target", t.Decoy)
+		}
+	default:
+		return fmt.Errorf("unknown decoy %q", t.Decoy)
+	}
+
+	if len(t.Params) > 0 {
+		if t.Kind != "" {
+			return errors.New("a target with params must not have a kind")
+		}
+		for name, sub := range t.Params {
+			if err := sub.validate(); err != nil {
+				return fmt.Errorf("param %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+
+	switch t.Kind {
+	case gatewayKindString, gatewayKindMap, gatewayKindObject:
+	case gatewayKindList:
+		for _, item := range t.List {
+			for _, p := range item.Require {
+				if !slices.Contains(allPermissions, p) {
+					return fmt.Errorf("list item %q: unknown permission %q", item.Value, p)
+				}
+			}
+		}
+	case gatewayKindProvider:
+		if _, ok := gatewayProviders[t.Provider]; !ok {
+			return fmt.Errorf("unknown provider %q", t.Provider)
+		}
+	case gatewayKindTemplate:
+		if _, ok := lookupTemplate(t.Template); !ok {
+			return fmt.Errorf("unknown template %q", t.Template)
+		}
+	case "":
+		return errors.New("missing kind")
+	default:
+		return fmt.Errorf("unknown kind %q", t.Kind)
+	}
+	return nil
+}
+
+// inherit 把 require、watermark 与 decoy 传递给子目标
+func (t *gatewayTarget) inherit() {
+	for _, sub := range t.Params {
+		sub.Require = append(slices.Clone(t.Require), sub.Require...)
+		if sub.Watermark == "" {
+			sub.Watermark = t.Watermark
+		}
+		if sub.Decoy == "" {
+			sub.Decoy = t.Decoy
+		}
+		sub.inherit()
+	}
+}
+
+// value 计算目标的返回值；provider 出错时已写出响应并返回 false
+func (t *gatewayTarget) value(c *gin.Context, req gatewayRequest) (any, bool) {
+	switch t.Kind {
+	case gatewayKindString:
+		return t.String, true
+	case gatewayKindList:
+		record := currentKeyRecord(c)
+		values := make([]string, 0, len(t.List))
+		for _, item := range t.List {
+			if record.HasAll(item.Require) {
+				values = append(values, item.Value)
+			}
+		}
+		return values, true
+	case gatewayKindMap:
+		return t.Map, true
+	case gatewayKindObject:
+		return t.Object, true
+	case gatewayKindProvider:
+		return gatewayProviders[t.Provider](c, req, t.Args)
+	case gatewayKindTemplate:
+		return serveTemplateSource(c, t.Template)
+	}
+	respondErrorCode(c, http.StatusInternalServerError, msgInvalidTargetDefinition)
+	return nil, false
+}
+
+// gatewayRegistry 注册表快照，加载后只读
+type gatewayRegistry struct {
+	targets  map[string]map[string]*gatewayTarget
+	source   string
+	loadedAt time.Time
+}
+
+var gatewayTargets atomic.Pointer[gatewayRegistry]
+
+// currentGatewayRegistry 当前注册表；尚未加载时使用内置定义
+func currentGatewayRegistry() *gatewayRegistry {
+	if registry := gatewayTargets.Load(); registry != nil {
+		return registry
+	}
+	registry, err := loadGatewayRegistry("")
+	if err != nil {
+		log.Fatalf("内置网关目标无效: %v", err)
+	}
+	gatewayTargets.CompareAndSwap(nil, registry)
+	return gatewayTargets.Load()
+}
+
+// loadGatewayRegistry 以内置定义为基础，合并 path 指定的 YAML 文件（为空时只使用内置定义）
+func loadGatewayRegistry(path string) (*gatewayRegistry, error) {
+	registry := &gatewayRegistry{targets: defaultGatewayTargets(), source: "builtin", loadedAt: time.Now()}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var file map[string]map[string]*gatewayTarget
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, err
+		}
+		for endpoint, targets := range file {
+			if _, ok := registry.targets[endpoint]; !ok {
+				return nil, fmt.Errorf("unknown endpoint %q", endpoint)
+			}
+			for name, target := range targets {
+				if target == nil || target.Disabled {
+					delete(registry.targets[endpoint], name)
+					continue
+				}
+				registry.targets[endpoint][name] = target
+			}
+		}
+		registry.source = path
+	}
+
+	for endpoint, targets := range registry.targets {
+		for name, target := range targets {
+			if err := target.validate(); err != nil {
+				return nil, fmt.Errorf("%s/%s: %w", endpoint, name, err)
+			}
+			target.inherit()
+		}
+	}
+	return registry, nil
+}
+
+// reloadGatewayTargets 重新加载 GATEWAY_TARGETS_FILE，失败时保留当前注册表
+func reloadGatewayTargets() error {
+	registry, err := loadGatewayRegistry(gatewayTargetsFile)
+	if err != nil {
+		return err
+	}
+	gatewayTargets.Store(registry)
+	log.Printf("网关目标已从 %s 加载", registry.source)
+	return nil
+}
+
+// startGatewayTargetWatcher 定期检查 GATEWAY_TARGETS_FILE 的修改时间，变化后重新加载，直到 bgCtx 被取消
+func startGatewayTargetWatcher(bgCtx context.Context) {
+	if gatewayTargetsFile == "" {
+		return
+	}
+	var lastMod time.Time
+	var lastSize int64
+	if info, err := os.Stat(gatewayTargetsFile); err == nil {
+		lastMod, lastSize = info.ModTime(), info.Size()
+	}
+
+	ticker := time.NewTicker(gatewayReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-bgCtx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(gatewayTargetsFile)
+		if err != nil {
+			log.Printf("无法读取网关目标文件 %s: %v", gatewayTargetsFile, err)
+			continue
+		}
+		if info.ModTime().Equal(lastMod) && info.Size() == lastSize {
+			continue
+		}
+		lastMod, lastSize = info.ModTime(), info.Size()
+		if err := reloadGatewayTargets(); err != nil {
+			log.Printf("网关目标文件 %s 无效，继续使用上一份配置: %v", gatewayTargetsFile, err)
+		}
+	}
+}
+
+// serveGatewayTarget 处理网关请求：查找目标、校验权限、计算返回值
+func serveGatewayTarget(c *gin.Context, endpoint string) {
+	var req gatewayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidRequestBody, err)
+		return
+	}
+
+	if !resolveGatewayAlias(c, endpoint, &req) {
+		respondErrorCode(c, http.StatusNotFound, msgUnknownTarget)
+		return
+	}
+	target, ok := currentGatewayRegistry().targets[endpoint][req.Target]
+	if !ok {
+		respondErrorCode(c, http.StatusNotFound, msgUnknownTarget)
+		return
+	}
+	if len(target.Params) > 0 {
+		sub, ok := target.Params[req.Param]
+		if !ok {
+			// 目标定义中的 param_error 是面向用户的自定义文案，未设置时使用目录中的消息
+			if target.ParamError != "" {
+				writeErrorResponse(c, ErrorResponse{Status: http.StatusNotFound, Code: msgUnknownModuleParameter, Error: target.ParamError})
+			} else {
+				respondErrorCode(c, http.StatusNotFound, msgUnknownModuleParameter)
+			}
+			return
+		}
+		target = sub
+	}
+
+	if !currentKeyRecord(c).HasAll(target.Require) {
+		log.Printf("Access denied for key %s: %s/%s requires %v", c.GetString("longTermKey"), endpoint, req.Target, target.Require)
+		respondErrorCode(c, http.StatusForbidden, msgPermissionDenied)
+		return
+	}
+
+	data, ok := target.value(c, req)
+	if !ok {
+		return
+	}
+	if isShadowBanned(c) {
+		data = shadowDecoy(c, req.Target, target.Decoy, data)
+	} else if target.Watermark != "" {
+		data = applyWatermark(c, target.Watermark, data)
+	}
+	respondEncrypted(c, http.StatusOK, data)
+}
+
+// gatewayProvider 计算 provider 目标的返回值；出错时自行写出响应并返回 false
+type gatewayProvider func(c *gin.Context, req gatewayRequest, args map[string]string) (any, bool)
+
+// gatewayProviders 可在目标定义中引用的 provider
+var gatewayProviders = map[string]gatewayProvider{
+	"round":         provideRound,
+	"lottery":       provideLottery,
+	"sorted_params": provideSortedParams,
+	"shop_products": provideShopProducts,
+	"menu":          provideMenu,
+	"menu_tree":     provideMenuTree,
+}
+
+// provideRound 转盘列表，args.type 为 universal 或 wanneng
+func provideRound(c *gin.Context, req gatewayRequest, args map[string]string) (any, bool) {
+	if !enforceRateLimit(c, rateLimiterPoint) || !consumeQuota(c, featureRound) {
+		return nil, false
+	}
+	validRounds, err := GetRound(args["type"])
+	if err != nil {
+		// Log the detailed error on the server, but return a generic error to the client.
+		log.Printf("GetRound failed for type '%s': %v", args["type"], err)
+		respondErrorCode(c, http.StatusInternalServerError, msgRoundFetchFailed)
+		return nil, false
+	}
+	return validRounds, true
+}
+
+// provideLottery 商店抽奖商品列表
+func provideLottery(c *gin.Context, req gatewayRequest, args map[string]string) (any, bool) {
+	if !enforceRateLimit(c, rateLimiterHandshake) || !consumeQuota(c, featureLottery) {
+		return nil, false
+	}
+	validLottery, err := GetLottery()
+	if err != nil {
+		log.Printf("GetLottery failed for: %v", err)
+		respondErrorCode(c, http.StatusInternalServerError, msgLotteryFetchFailed)
+		return nil, false
+	}
+	return validLottery, true
+}
+
+// provideSortedParams 返回请求中的 params 加上 "secret" 后排序的结果
+func provideSortedParams(c *gin.Context, req gatewayRequest, args map[string]string) (any, bool) {
+	if req.Params == nil {
+		respondErrorCode(c, http.StatusBadRequest, msgMissingTargetParams, req.Target)
+		return nil, false
+	}
+	keys := make([]string, 0, len(req.Params)+1)
+	keys = append(keys, req.Params...)
+	keys = append(keys, "secret")
+	sort.Strings(keys)
+	return keys, true
+}
+
+// gatewayTargetSummary 管理接口展示的目标概要
+type gatewayTargetSummary struct {
+	Kind      string       `json:"kind,omitempty"`
+	Provider  string       `json:"provider,omitempty"`
+	Template  string       `json:"template,omitempty"`
+	Require   []permission `json:"require,omitempty"`
+	Watermark string       `json:"watermark,omitempty"`
+	Decoy     string       `json:"decoy,omitempty"`
+	Params    []string     `json:"params,omitempty"`
+}
+
+// summary 按端点列出全部目标
+func (r *gatewayRegistry) summary() map[string]map[string]gatewayTargetSummary {
+	out := make(map[string]map[string]gatewayTargetSummary, len(r.targets))
+	for endpoint, targets := range r.targets {
+		out[endpoint] = make(map[string]gatewayTargetSummary, len(targets))
+		for name, t := range targets {
+			s := gatewayTargetSummary{Kind: t.Kind, Provider: t.Provider, Template: t.Template, Require: t.Require, Watermark: t.Watermark, Decoy: t.Decoy}
+			for param := range t.Params {
+				s.Params = append(s.Params, param)
+			}
+			sort.Strings(s.Params)
+			out[endpoint][name] = s
+		}
+	}
+	return out
+}
@@ -0,0 +1,170 @@
+package main
+
+// --- 网关目标的内置定义 ---
+// GATEWAY_TARGETS_FILE 未配置或文件中没有覆盖的目标使用这里的定义，见 gateway.go
+
+// shopItem 网关下发的商店商品（pp、dg 目标中的一项）
+type shopItem struct {
+	ID string `json:"id" yaml:"id"`
+	LK string `json:"lk" yaml:"lk"`
+}
+
+// defaultGatewayTargets 返回 handleGateway、handleLucy、handleDavid 的内置目标
+// 其中的地址、常量与正则来自当前配置的 upstream 与 client 两节，配置重新加载后随网关目标重建
+func defaultGatewayTargets() map[string]map[string]*gatewayTarget {
+	upstream, client := currentConfig().Upstream, currentConfig().Client
+	return map[string]map[string]*gatewayTarget{
+		gatewayEndpointGateway: {
+			// 客户端菜单，定义见 menus/menus.yaml：cupboards 与 leave 返回文案列表，drawers 返回菜单树
+			"cupboards": {Kind: gatewayKindProvider, Provider: "menu", Args: map[string]string{"menu": "main"}},
+			"leave": {Params: map[string]*gatewayTarget{
+				"sign": {Kind: gatewayKindProvider, Provider: "menu", Args: map[string]string{"menu": "sign"}},
+			}},
+			"drawers": {Kind: gatewayKindProvider, Provider: "menu_tree", Args: map[string]string{"menu": "main"}},
+		},
+		gatewayEndpointLucy: {
+			// 实
+			"sd": {Kind: gatewayKindList, List: listItems(upstream.ShopInkindURLs...), Watermark: watermarkChannelQuery, Decoy: gatewayDecoyURL},
+			// 虚
+			"gb": {Kind: gatewayKindList, List: listItems(upstream.ShopVirtualURLs...), Watermark: watermarkChannelQuery, Decoy: gatewayDecoyURL},
+			// 商店商品，来自 shop_products 表，见 shop.go
+			"dg": {Kind: gatewayKindProvider, Provider: "shop_products", Args: map[string]string{"category": "dg"}},
+			"pp": {Kind: gatewayKindProvider, Provider: "shop_products", Args: map[string]string{"category": "pp"}},
+			// 统计报告模板，原文见 templates/ 目录
+			"love": {Kind: gatewayKindTemplate, Template: "farm"},
+			"face": {Kind: gatewayKindTemplate, Template: "round"},
+			"fade": {Kind: gatewayKindTemplate, Template: "game"},
+			// 转盘接口地址
+			"evening": {Kind: gatewayKindMap, Map: map[string]string{"universal": upstream.UniversalURL, "wanneng": upstream.WannengURL}, Watermark: watermarkChannelQuery, Decoy: gatewayDecoyURL},
+			// 农场提取正则
+			"sitting": {Watermark: watermarkChannelRegex, Params: map[string]*gatewayTarget{
+				"reading": {Kind: gatewayKindString, String: client.Patterns.Extract},
+				"lines":   {Kind: gatewayKindString, String: client.Patterns.ExtractS},
+			}},
+			// 农场接口地址
+			"time": {Kind: gatewayKindList, List: listItems(upstream.FarmURLs...), Watermark: watermarkChannelQuery, Decoy: gatewayDecoyURL},
+			// 小游戏接口地址与参数
+			"reason": {Kind: gatewayKindList, List: listItems(upstream.GameURLs...), Watermark: watermarkChannelQuery, Decoy: gatewayDecoyURL},
+			"really": listTarget(client.GameParams...),
+			// 客户端密钥键值对
+			"oh": {Kind: gatewayKindMap, Map: map[string]string{"key": client.SecretKey, "value": client.SecretValue}, Decoy: gatewayDecoyToken},
+			// params 加上 "secret" 后排序
+			"tell":       {Kind: gatewayKindProvider, Provider: "sorted_params"},
+			"going":      {Kind: gatewayKindString, String: client.AnotherSecret, Decoy: gatewayDecoyToken},
+			"stay":       {Kind: gatewayKindString, String: client.ActOnClick},
+			"compromise": {Kind: gatewayKindList, List: listItems(client.PageToken, client.PageRandomStr, client.XiaoyouxiInfo), Watermark: watermarkChannelOrder},
+			"know":       {Kind: gatewayKindList, List: listItems(client.Patterns.VarJSON), Watermark: watermarkChannelRegex},
+			"control":    {Kind: gatewayKindList, List: listItems(client.Patterns.VarQuoted, client.Patterns.VarUnquoted), Watermark: watermarkChannelRegex},
+			// 转盘列表：of 为 universal，view 为 wanneng
+			"point": {ParamError: "Unknown round parameter", Params: map[string]*gatewayTarget{
+				"of":   {Kind: gatewayKindProvider, Provider: "round", Args: map[string]string{"type": "universal"}},
+				"view": {Kind: gatewayKindProvider, Provider: "round", Args: map[string]string{"type": "wanneng"}},
+			}},
+		},
+		gatewayEndpointDavid: {
+			"feature":   {Kind: gatewayKindTemplate, Template: "lottery"},
+			"house":     listTarget("LotteryTask", "receivePrize"),
+			"handshake": {Kind: gatewayKindProvider, Provider: "lottery"},
+		},
+	}
+}
+
+func listItems(values ...string) []gatewayListItem {
+	items := make([]gatewayListItem, len(values))
+	for i, v := range values {
+		items[i] = gatewayListItem{Value: v}
+	}
+	return items
+}
+
+func listTarget(values ...string) *gatewayTarget {
+	return &gatewayTarget{Kind: gatewayKindList, List: listItems(values...)}
+}
+
+// defaultShopProducts shop_products 表为空时写入的初始商品（pp 分类），键为商品名
+var defaultShopProducts = map[string]shopItem{
+	"异环月卡（爆布斯专属）": {
+		ID: "14780",
+		LK: "https://shop.3839.com?id=14780&imm=1",
+	},
+	"异环6元充值助力金": {
+		ID: "14776",
+		LK: "https://shop.3839.com?id=14776&imm=1",
+	},
+	"火影忍者月卡(新人专属)": {
+		ID: "6429",
+		LK: "https://shop.3839.com/?id=6429&imm=1",
+	},
+	"王者荣耀188战令进阶卡(新人专属)": {
+		ID: "13211",
+		LK: "https://shop.3839.com/?id=13211&imm=1",
+	},
+	"火影忍者秘藏忍法帖(新人专属)": {
+		ID: "6428",
+		LK: "https://shop.3839.com/?id=6428&imm=1",
+	},
+	"王者荣耀288点券皮肤(新人专属)": {
+		ID: "4653",
+		LK: "https://shop.3839.com/?id=4653&imm=1",
+	},
+	"使命召唤手游使命手册普通版": {
+		ID: "4266",
+		LK: "https://shop.3839.com/?id=4266&imm=1",
+	},
+	"火影忍者月卡": {
+		ID: "6408",
+		LK: "https://shop.3839.com/?id=6408&imm=1",
+	},
+	"王者荣耀188战令进阶卡": {
+		ID: "13210",
+		LK: "https://shop.3839.com/?id=13210&imm=1",
+	},
+	"火影忍者秘藏忍法帖": {
+		ID: "6409",
+		LK: "https://shop.3839.com/?id=6409&imm=1",
+	},
+	"5Q币": {
+		ID: "12987",
+		LK: "https://shop.3839.com/?id=12987&imm=1",
+	},
+	"5Q币(老爆er专属)": {
+		ID: "12989",
+		LK: "https://shop.3839.com/?id=12989&imm=1",
+	},
+	"5Q币（老爆er lv5专属）": {
+		ID: "12990",
+		LK: "https://shop.3839.com/?id=12990&imm=1",
+	},
+	"5Q币（老爆er lv6专属）": {
+		ID: "12991",
+		LK: "https://shop.3839.com/?id=12991&imm=1",
+	},
+	"15Q币": {
+		ID: "12993",
+		LK: "https://shop.3839.com/?id=12993&imm=1",
+	},
+	"15Q币(老爆er专属)": {
+		ID: "12994",
+		LK: "https://shop.3839.com/?id=12994&imm=1",
+	},
+	"15Q币（老爆er lv5专属）": {
+		ID: "12995",
+		LK: "https://shop.3839.com/?id=12995&imm=1",
+	},
+	"15Q币（老爆er lv6专属）": {
+		ID: "12996",
+		LK: "https://shop.3839.com/?id=12996&imm=1",
+	},
+	"30Q币": {
+		ID: "12998",
+		LK: "https://shop.3839.com/?id=12998&imm=1",
+	},
+	"30Q币(老爆er专属)": {
+		ID: "12999",
+		LK: "https://shop.3839.com/?id=12999&imm=1",
+	},
+	"30Q币（老爆er lv5专属）": {
+		ID: "13000",
+		LK: "https://shop.3839.com/?id=13000&imm=1",
+	},
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func writeGatewayFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "targets.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuiltinGatewayTargetsAreValid(t *testing.T) {
+	registry, err := loadGatewayRegistry("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := registry.targets[gatewayEndpointLucy]["sitting"].Params["reading"]; got.String != currentConfig().Client.Patterns.Extract || got.Watermark != watermarkChannelRegex {
+		t.Errorf("sitting/reading = %+v, want the extract pattern with the inherited regex watermark", got)
+	}
+}
+
+func TestGatewayFileOverrides(t *testing.T) {
+	path := writeGatewayFile(t, `
+gateway:
+  cupboards:
+    kind: list
+    value:
+      - 公告
+      - {value: 转盘, require: [useTaie]}
+lucy:
+  going:
+    disabled: true
+  reason:
+    kind: list
+    value: [https://example.com/a.php]
+    watermark: query
+  point:
+    param_error: Unknown round parameter
+    require: [useTaie]
+    params:
+      of: {kind: provider, provider: round, args: {type: universal}}
+  pp:
+    kind: object
+    value:
+      月卡: {id: "1", lk: "https://shop.3839.com/?id=1"}
+`)
+	registry, err := loadGatewayRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lucy := registry.targets[gatewayEndpointLucy]
+	if _, ok := lucy["going"]; ok {
+		t.Error("disabled target is still registered")
+	}
+	if _, ok := lucy["oh"]; !ok {
+		t.Error("builtin target missing after merging the file")
+	}
+	if got := lucy["reason"].List; len(got) != 1 || got[0].Value != "https://example.com/a.php" {
+		t.Errorf("reason = %+v", got)
+	}
+	if got := lucy["point"].Params["of"]; got.Provider != "round" || got.Args["type"] != "universal" || len(got.Require) != 1 {
+		t.Errorf("point/of = %+v, want the round provider inheriting useTaie", got)
+	}
+	if _, ok := lucy["pp"].Object.(map[string]any)["月卡"]; !ok {
+		t.Errorf("pp = %#v", lucy["pp"].Object)
+	}
+
+	menu := registry.targets[gatewayEndpointGateway]["cupboards"].List
+	if len(menu) != 2 || menu[0].Value != "公告" || menu[1].Require[0] != permTaie {
+		t.Errorf("cupboards = %+v", menu)
+	}
+}
+
+func TestGatewayFileRejectsInvalidTargets(t *testing.T) {
+	cases := map[string]string{
+		"unknown kind":       "lucy:\n  x: {kind: blob, value: 1}\n",
+		"unknown provider":   "lucy:\n  x: {kind: provider, provider: nope}\n",
+		"unknown permission": "lucy:\n  x: {kind: string, value: a, require: [useEverything]}\n",
+		"unknown endpoint":   "bob:\n  x: {kind: string, value: a}\n",
+		"missing kind":       "lucy:\n  x: {value: a}\n",
+		"unknown decoy":      "lucy:\n  x: {kind: string, value: a, decoy: fake}\n",
+		"decoy on provider":  "lucy:\n  x: {kind: provider, provider: round, decoy: url}\n",
+	}
+	for name, content := range cases {
+		if _, err := loadGatewayRegistry(writeGatewayFile(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestGatewayListFiltersByPermission(t *testing.T) {
+	target := &gatewayTarget{Kind: gatewayKindList, List: []gatewayListItem{
+		{Value: "实", Require: []permission{permTaie}},
+		{Value: "退出"},
+	}}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(keyRecordContextKey, newKeyRecord("k", map[string]string{"useShop": "true"}))
+
+	got, ok := target.value(c, gatewayRequest{Target: "cupboards"})
+	if !ok || strings.Join(got.([]string), ",") != "退出" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestGatewayErrorsCarryCodes(t *testing.T) {
+	registry, err := loadGatewayRegistry(writeGatewayFile(t, `
+lucy:
+  point:
+    param_error: Unknown round parameter
+    params:
+      of: {kind: string, value: a}
+  secret:
+    kind: string
+    value: s
+    require: [useTaie]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := gatewayTargets.Load()
+	gatewayTargets.Store(registry)
+	t.Cleanup(func() { gatewayTargets.Store(old) })
+
+	for _, tc := range []struct {
+		body     string
+		wantCode messageCode
+		wantText string
+	}{
+		{`{"target": "nope"}`, msgUnknownTarget, "Unknown target"},
+		{`{"target": "point", "p": "x"}`, msgUnknownModuleParameter, "Unknown round parameter"},
+		{`{"target": "secret"}`, msgPermissionDenied, "You do not have permission to access this resource"},
+		{`{"target": `, msgInvalidRequestBody, ""},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/api/v1/lucy", strings.NewReader(tc.body))
+		c.Set(keyRecordContextKey, newKeyRecord("k", map[string]string{"useShop": "true"}))
+
+		serveGatewayTarget(c, gatewayEndpointLucy)
+
+		var body ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v", tc.body, err)
+		}
+		if body.Code != tc.wantCode || (tc.wantText != "" && body.Error != tc.wantText) {
+			t.Errorf("%s: got %d %+v, want code %s", tc.body, w.Code, body, tc.wantCode)
+		}
+	}
+}
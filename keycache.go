@@ -0,0 +1,198 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// --- Key 记录本地缓存 ---
+//
+// 认证与权限校验优先读取进程内的 LRU 缓存，缓存项最长保留 keyCacheTTL（且不超过 Key 自身的过期时间）。
+// 通过服务器修改 Key（封禁、权限编辑、位置更新）必须使用 updateKeyFields，
+// 它会在 keyCacheInvalidateChannel 上发布失效消息，使所有实例立即丢弃该 Key 的缓存。
+// 直接用 redis-cli 修改的 Key 不会触发失效，最迟 keyCacheTTL 后生效。
+
+const (
+	keyCacheTTL               = 5 * time.Minute
+	keyCacheSize              = 4096
+	keyCacheInvalidateChannel = "keycache:invalidate"
+)
+
+var keyRecords = newKeyCache(keyCacheSize, keyCacheTTL)
+
+type keyCacheEntry struct {
+	key     string
+	record  *keyRecord
+	expires time.Time
+}
+
+// keyCache 带 TTL 的 LRU 缓存，并发安全
+type keyCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+	// gen 每次失效时递增，防止失效前发起的 Redis 读取把旧数据写回缓存
+	gen uint64
+}
+
+func newKeyCache(size int, ttl time.Duration) *keyCache {
+	return &keyCache{size: size, ttl: ttl, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (kc *keyCache) get(key string) (*keyRecord, bool) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	elem, ok := kc.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*keyCacheEntry)
+	if time.Now().After(entry.expires) {
+		kc.ll.Remove(elem)
+		delete(kc.items, key)
+		return nil, false
+	}
+	kc.ll.MoveToFront(elem)
+	return entry.record, true
+}
+
+// generation 在读取 Redis 前调用，put 时传回以检测期间是否发生过失效
+func (kc *keyCache) generation() uint64 {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	return kc.gen
+}
+
+// put 写入缓存，ttl 为 Key 在 Redis 中的剩余有效期（<= 0 表示永不过期）
+func (kc *keyCache) put(key string, record *keyRecord, gen uint64, ttl time.Duration) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	if gen != kc.gen {
+		return
+	}
+	if ttl <= 0 || ttl > kc.ttl {
+		ttl = kc.ttl
+	}
+	entry := &keyCacheEntry{key: key, record: record, expires: time.Now().Add(ttl)}
+
+	if elem, ok := kc.items[key]; ok {
+		elem.Value = entry
+		kc.ll.MoveToFront(elem)
+		return
+	}
+	kc.items[key] = kc.ll.PushFront(entry)
+	if kc.ll.Len() > kc.size {
+		oldest := kc.ll.Back()
+		kc.ll.Remove(oldest)
+		delete(kc.items, oldest.Value.(*keyCacheEntry).key)
+	}
+}
+
+func (kc *keyCache) remove(key string) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	kc.gen++
+	if elem, ok := kc.items[key]; ok {
+		kc.ll.Remove(elem)
+		delete(kc.items, key)
+	}
+}
+
+func (kc *keyCache) purge() {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	kc.gen++
+	kc.ll.Init()
+	kc.items = make(map[string]*list.Element)
+}
+
+// loadKeyRecord 读取 Key 记录，优先使用本地缓存；Key 不存在时返回的记录 Fields 为空（不缓存）
+func loadKeyRecord(key string) (*keyRecord, error) {
+	if record, ok := keyRecords.get(key); ok {
+		return record, nil
+	}
+
+	gen := keyRecords.generation()
+	pipe := swordRdb.Pipeline()
+	fieldsCmd := pipe.HGetAll(ctx, key)
+	ttlCmd := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	record := newKeyRecord(key, fieldsCmd.Val())
+	if record.Exists() {
+		keyRecords.put(key, record, gen, ttlCmd.Val())
+	}
+	return record, nil
+}
+
+// updateKeyFields 修改 Key 的字段并通知所有实例丢弃缓存，参数与 HSet 相同
+func updateKeyFields(key string, values ...any) error {
+	if err := swordRdb.HSet(ctx, key, values...).Err(); err != nil {
+		return err
+	}
+	invalidateKeyRecord(key)
+	return nil
+}
+
+// deleteKeyFields 删除 Key 的字段并通知所有实例丢弃缓存
+func deleteKeyFields(key string, fields ...string) error {
+	if err := swordRdb.HDel(ctx, key, fields...).Err(); err != nil {
+		return err
+	}
+	invalidateKeyRecord(key)
+	return nil
+}
+
+// invalidateKeyRecord 丢弃本地缓存并广播失效消息
+func invalidateKeyRecord(key string) {
+	keyRecords.remove(key)
+	if err := swordRdb.Publish(ctx, keyCacheInvalidateChannel, key).Err(); err != nil {
+		log.Printf("发布 Key 缓存失效消息失败 (%s): %v", key, err)
+	}
+}
+
+// startKeyCacheInvalidation 订阅失效频道，直到 bgCtx 被取消
+// 每次（重新）订阅成功都会清空缓存，因为断线期间的失效消息已经丢失
+func startKeyCacheInvalidation(bgCtx context.Context) {
+	sub := swordRdb.Subscribe(bgCtx, keyCacheInvalidateChannel)
+	defer sub.Close()
+	log.Println("Key 缓存失效订阅已启动")
+
+	for {
+		msg, err := sub.Receive(bgCtx)
+		if err != nil {
+			if bgCtx.Err() != nil {
+				log.Println("Key 缓存失效订阅已停止")
+				return
+			}
+			log.Printf("Key 缓存失效订阅出错，清空缓存后重试: %v", err)
+			keyRecords.purge()
+			select {
+			case <-bgCtx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			keyRecords.purge()
+		case *redis.Message:
+			keyRecords.remove(m.Payload)
+		}
+	}
+}
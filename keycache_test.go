@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyCacheEvictionAndInvalidation(t *testing.T) {
+	kc := newKeyCache(2, time.Minute)
+	record := newKeyRecord("a", map[string]string{"useTaie": "true"})
+
+	kc.put("a", record, kc.generation(), 0)
+	kc.put("b", record, kc.generation(), 0)
+	kc.get("a")
+	kc.put("c", record, kc.generation(), 0)
+	if _, ok := kc.get("b"); ok {
+		t.Error("least recently used entry b should have been evicted")
+	}
+	if _, ok := kc.get("a"); !ok {
+		t.Error("recently used entry a should still be cached")
+	}
+
+	// 读取 Redis 期间发生失效时，旧数据不能写回缓存
+	gen := kc.generation()
+	kc.remove("a")
+	kc.put("a", record, gen, 0)
+	if _, ok := kc.get("a"); ok {
+		t.Error("stale record written back after invalidation")
+	}
+
+	kc.put("d", record, kc.generation(), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := kc.get("d"); ok {
+		t.Error("entry should expire with the key's own TTL")
+	}
+}
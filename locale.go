@@ -0,0 +1,97 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- 语言协商 ---
+//
+// 客户端通过 Accept-Language 请求文案的语言；Key 的 locale 字段（见 PUT /admin/keys/:key/locale）
+// 是该 Key 的语言偏好，优先于 Accept-Language。没有对应翻译时回退到默认文案：
+// 菜单默认使用简体中文，错误与提示消息默认使用英文，见 messages.go。
+
+const (
+	defaultLocale = "zh-CN"
+
+	// keyLocaleField Key 上保存语言偏好的字段
+	keyLocaleField = "locale"
+)
+
+// preferredLocales 按优先级排列的语言：Key 的语言偏好，然后是按 Accept-Language 的 q 值降序排列的语言，忽略 q=0 与 *
+func preferredLocales(c *gin.Context) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var prefs []weighted
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			prefs = append(prefs, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	var locales []string
+	if record := currentKeyRecord(c); record != nil && record.Fields[keyLocaleField] != "" {
+		locales = append(locales, record.Fields[keyLocaleField])
+	}
+	for _, p := range prefs {
+		locales = append(locales, p.tag)
+	}
+	return locales
+}
+
+// matchLocale 按 prefs 从 available 中选择语言：对每个首选语言先精确匹配（不区分大小写），
+// 再匹配主语言相同的语言（en-US -> en、zh-TW -> zh-CN），主语言相同的有多个时取排序最前的
+func matchLocale(available []string, prefs []string) (string, bool) {
+	sorted := slices.Clone(available)
+	slices.Sort(sorted)
+	for _, pref := range prefs {
+		for _, tag := range sorted {
+			if strings.EqualFold(tag, pref) {
+				return tag, true
+			}
+		}
+		base := localeBase(pref)
+		for _, tag := range sorted {
+			if strings.EqualFold(localeBase(tag), base) {
+				return tag, true
+			}
+		}
+	}
+	return "", false
+}
+
+// localeBase 语言标签的主语言部分
+func localeBase(tag string) string {
+	base, _, _ := strings.Cut(tag, "-")
+	return base
+}
+
+// localize 按 prefs 从 translations 中选择文案；fallback 为默认语言（简体中文）的文案，
+// 首选语言匹配到默认语言、或所有语言都没有翻译时返回 fallback
+func localize(fallback string, translations map[string]string, prefs []string) string {
+	available := append([]string{defaultLocale}, slices.Collect(maps.Keys(translations))...)
+	if tag, ok := matchLocale(available, prefs); ok && tag != defaultLocale {
+		return translations[tag]
+	}
+	return fallback
+}
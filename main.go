@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"io"
 	"log"
@@ -40,11 +41,51 @@ func main() {
 
 	initDB()
 	defer closeDB()
+	initShopCatalog()
+
+	initResponseSigning()
+
+	// 报告模板、网关目标与菜单定义有误时拒绝启动；运行中的重新加载失败只记录日志
+	if err := reloadTemplates(); err != nil {
+		log.Fatalf("加载报告模板失败: %v", err)
+	}
+	if err := reloadGatewayTargets(); err != nil {
+		log.Fatalf("加载网关目标失败: %v", err)
+	}
+	if err := reloadMenus(); err != nil {
+		log.Fatalf("加载客户端菜单失败: %v", err)
+	}
 
 	// ================= 3. 初始化定时器 =================
+	// 定时任务与监听地址等只在启动时读取，修改后需要重启
+	cfg := currentConfig()
 	cronManager := NewCronJobManager()
 
-	_, err = cronManager.AddTaskWithImmediate("3 10,12,14,16,18,20,22 * * *", BoxActivitiesAll)
+	_, err = cronManager.AddTaskWithImmediate(cfg.Cron.BoxActivities, BoxActivitiesAll)
+	if err != nil {
+		panic(err)
+	}
+
+	// 定期汇总用量到 Postgres
+	_, err = cronManager.AddTask(cfg.Cron.UsageRollup, rollupUsage)
+	if err != nil {
+		panic(err)
+	}
+
+	// 从 products.js 发现新商品（未配置 shop.discovery_pattern 时不执行）
+	_, err = cronManager.AddTask(cfg.Cron.ShopDiscovery, runShopDiscovery)
+	if err != nil {
+		panic(err)
+	}
+
+	// 清理过期的分享报告
+	_, err = cronManager.AddTask(cfg.Cron.ReportPurge, purgeExpiredReports)
+	if err != nil {
+		panic(err)
+	}
+
+	// 比较上游商店数据并记录变更
+	_, err = cronManager.AddTask(cfg.Cron.ShopFeedRefresh, refreshShopFeeds)
 	if err != nil {
 		panic(err)
 	}
@@ -54,8 +95,8 @@ func main() {
 
 	// ================= 4. 初始化 Gin 引擎 && 反向代理 =================
 	router := gin.New()
-	cyberProxy := ReverseProxy("http://127.0.0.1:8000")
-	wooProxy := ReverseProxy("http://127.0.0.1:13456")
+	cyberProxy := ReverseProxy(cfg.Server.CyberProxy)
+	wooProxy := ReverseProxy(cfg.Server.WooProxy)
 
 	// 中间件配置
 	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{
@@ -63,17 +104,21 @@ func main() {
 		Formatter: nil,
 	}))
 	router.Use(gin.Logger())
-	router.SetTrustedProxies([]string{"127.0.0.1"})
+	router.Use(responseSigningMiddleware())
+	router.SetTrustedProxies(cfg.Server.TrustedProxies)
 
-	// 路由注册
+	// 路由注册，各路由所需权限见 permissions.go 中的 routePermissionRegistry
 	router.POST("/authenticate", handleAuthentication)
+	router.GET("/keys", handlePublicKeys)
+	router.GET("/challenge", handleChallenge)
+	router.GET("/reports/:id", viewReportHandler)
 
 	apiGroup := router.Group("/api")
-	apiGroup.Use(authMiddleware(), appIntegrityMiddleware())
+	apiGroup.Use(clientVersionMiddleware(), authMiddleware(), appIntegrityMiddleware(), rateLimitMiddleware(), permissionMiddleware())
 	{
-		apiGroup.POST("/v1/gateway", taiePermissionMiddlerware(false), shopPermissionMiddlerware(false), lightPermissionMiddlerware(false), encryptionMiddleware(), handleGateway)
-		apiGroup.POST("/v1/lucy", taiePermissionMiddlerware(false), encryptionMiddleware(), handleLucy)
-		apiGroup.POST("/v1/david", shopPermissionMiddlerware(true), encryptionMiddleware(), handleDavid)
+		apiGroup.POST("/v1/gateway", encryptionMiddleware(), handleGateway)
+		apiGroup.POST("/v1/lucy", encryptionMiddleware(), handleLucy)
+		apiGroup.POST("/v1/david", encryptionMiddleware(), handleDavid)
 
 		v1 := apiGroup.Group("/v1")
 		{
@@ -81,15 +126,22 @@ func main() {
 			v1.POST("/b474528334283249d218771959415853", submitTaskHandler)
 		}
 
-		apiGroup.GET("/activities", activitiesPermissionMiddleware(), encryptionMiddleware(), getActivitiesHandler)
-		apiGroup.GET("/activities/add", activitiesPermissionMiddleware(), addUserActivityHandler)
-		apiGroup.GET("/activities/getall", activitiesPermissionMiddleware(), getUserActivitiesIntsHandler)
-		apiGroup.GET("/activities/search", activitiesPermissionMiddleware(), encryptionMiddleware(), searchActivitiesHandler)
-		apiGroup.GET("/activities/getself", activitiesPermissionMiddleware(), encryptionMiddleware(), getUserActivitiesHandler)
+		apiGroup.GET("/activities", encryptionMiddleware(), getActivitiesHandler)
+		apiGroup.GET("/activities/add", addUserActivityHandler)
+		apiGroup.GET("/activities/getall", getUserActivitiesIntsHandler)
+		apiGroup.GET("/activities/search", encryptionMiddleware(), searchActivitiesHandler)
+		apiGroup.GET("/activities/getself", encryptionMiddleware(), getUserActivitiesHandler)
+		apiGroup.GET("/activities/export", exportActivitiesHandler)
+		apiGroup.GET("/usage", encryptionMiddleware(), getUsageHandler)
+		apiGroup.POST("/reports", encryptionMiddleware(), createReportHandler)
+		apiGroup.POST("/runs", encryptionMiddleware(), submitRunHandler)
+		apiGroup.GET("/runs", encryptionMiddleware(), getRunsHandler)
+		apiGroup.GET("/runs/trends", encryptionMiddleware(), getRunTrendsHandler)
+		apiGroup.GET("/shop/changes", encryptionMiddleware(), getShopFeedChangesHandler)
 	}
 
 	cyberGroup := router.Group("/apk")
-	cyberGroup.Use(authMiddleware(), appIntegrityMiddleware(), cyberPermissionMiddleware())
+	cyberGroup.Use(authMiddleware(), appIntegrityMiddleware(), rateLimitMiddleware(), permissionMiddleware())
 	{
 		cyberGroup.GET("/load_cache", loadSearchCache)
 		cyberGroup.POST("/submit_cache", submitSearchCache)
@@ -100,18 +152,51 @@ func main() {
 	}
 
 	wooGroup := router.Group("/woo")
-	wooGroup.Use(authMiddleware(), appIntegrityMiddleware(), wooPermissionMiddlerware())
+	wooGroup.Use(authMiddleware(), appIntegrityMiddleware(), rateLimitMiddleware(), permissionMiddleware())
 	{
 		wooGroup.GET("/box_search", searchBoxActs)
-		wooGroup.GET("/stock/:lottery_id", wooProPermissionMiddlerware(), TapStockProxy(wooProxy))
+		wooGroup.GET("/stock/:lottery_id", TapStockProxy(wooProxy))
 	}
 
 	safeGroup := router.Group("/safe")
-	safeGroup.Use(authMiddleware(), appIntegrityMiddleware())
+	safeGroup.Use(authMiddleware(), appIntegrityMiddleware(), permissionMiddleware())
 	{
 		safeGroup.GET("/log", logSubmit)
 	}
 
+	adminGroup := router.Group("/admin")
+	adminGroup.Use(adminMiddleware())
+	{
+		adminGroup.GET("/config", getConfigHandler)
+		adminGroup.POST("/config/reload", reloadConfigHandler)
+		adminGroup.GET("/keys/:key", getKeyHandler)
+		adminGroup.PATCH("/keys/:key/permissions", updateKeyPermissionsHandler)
+		adminGroup.POST("/keys/:key/ban", banKeyHandler)
+		adminGroup.DELETE("/keys/:key/ban", unbanKeyHandler)
+		adminGroup.GET("/keys/:key/shadow-log", getShadowLogHandler)
+		adminGroup.PATCH("/keys/:key/flags", updateKeyFlagsHandler)
+		adminGroup.PUT("/keys/:key/locale", updateKeyLocaleHandler)
+		adminGroup.POST("/watermark/identify", identifyWatermarkHandler)
+		adminGroup.GET("/gateway/targets", getGatewayTargetsHandler)
+		adminGroup.POST("/gateway/reload", reloadGatewayTargetsHandler)
+		adminGroup.GET("/shop/products", listShopProductsHandler)
+		adminGroup.POST("/shop/products", createShopProductHandler)
+		adminGroup.PUT("/shop/products/:id", updateShopProductHandler)
+		adminGroup.DELETE("/shop/products/:id", deleteShopProductHandler)
+		adminGroup.POST("/shop/discover", discoverShopProductsHandler)
+		adminGroup.POST("/shop/feeds/refresh", refreshShopFeedsHandler)
+		adminGroup.GET("/menus", getMenusHandler)
+		adminGroup.POST("/menus/reload", reloadMenusHandler)
+		adminGroup.GET("/menus/preview", previewMenusHandler)
+		adminGroup.GET("/templates", getTemplatesHandler)
+		adminGroup.POST("/templates/reload", reloadTemplatesHandler)
+		adminGroup.GET("/aliases", getAliasesHandler)
+		adminGroup.GET("/usage", getUsageReportHandler)
+		adminGroup.GET("/metrics", gin.WrapH(expvar.Handler()))
+		adminGroup.GET("/security/events", getSecurityEventsHandler)
+		adminGroup.DELETE("/security/lockouts/:ip", unlockAuthHandler)
+	}
+
 	// ================= 5. 启动后台任务 =================
 	// 创建一个可取消的 context，用于向后台协程发送停止信号
 	bgCtx, bgCancel := context.WithCancel(context.Background())
@@ -119,16 +204,19 @@ func main() {
 
 	go startTaskTimeoutWatcher(bgCtx)
 	go startTaskGenerator(bgCtx)
+	go startKeyCacheInvalidation(bgCtx)
+	go startGatewayTargetWatcher(bgCtx)
+	go startConfigReloader(bgCtx)
 
 	// ================= 6. 启动 HTTP Server =================
 	srv := &http.Server{
-		Addr:    ":3839",
-		Handler: router,
+		Addr:    cfg.Server.Addr,
+		Handler: aliasRouteHandler(router),
 	}
 
 	// 在单独的 goroutine 中启动服务，避免阻塞主线程
 	go func() {
-		log.Println("服务器启动，监听端口 :3839")
+		log.Printf("服务器启动，监听 %s", cfg.Server.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Gin 服务器启动失败: %v", err)
 		}
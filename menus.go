@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// --- 客户端菜单 ---
+//
+// 菜单以声明式的 YAML 定义（内置 menus/menus.yaml，MENUS_FILE 指定的文件整体替换它）：
+// 每个菜单是一组菜单项，菜单项可以声明所需权限（require）、功能开关（flag）、排序（order）、
+// 各语言的文案（labels）以及子菜单（submenu，引用另一个菜单）。
+// 服务器按 Key 的权限与功能开关过滤菜单项，并按 Accept-Language 选择文案：
+//   - 网关 cupboards、leave 目标（provider menu）返回菜单项文案列表，兼容旧客户端；
+//   - 网关 drawers 目标（provider menu_tree）返回带 id 与子菜单的完整菜单树。
+// 功能开关的默认状态在文件的 flags 中声明，可以通过 PATCH /admin/keys/:key/flags 为单个 Key 开启或关闭，
+// 用于向测试用户提前开放新功能。菜单文件在 POST /admin/menus/reload 或配置重新加载（SIGHUP）时重新读取，
+// 校验失败时继续使用上一份菜单。
+
+//go:embed menus/menus.yaml
+var embeddedMenus []byte
+
+// menuItem 菜单中的一项
+type menuItem struct {
+	ID      string            `yaml:"id" json:"id"`
+	Label   string            `yaml:"label" json:"label"`
+	Labels  map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Require []permission      `yaml:"require,omitempty" json:"require,omitempty"`
+	Flag    string            `yaml:"flag,omitempty" json:"flag,omitempty"`
+	Order   int               `yaml:"order,omitempty" json:"order,omitempty"`
+	Submenu string            `yaml:"submenu,omitempty" json:"submenu,omitempty"`
+}
+
+// menuFlag 功能开关及其默认状态
+type menuFlag struct {
+	Description string `yaml:"description" json:"description"`
+	Enabled     bool   `yaml:"enabled" json:"enabled"`
+}
+
+// menuFile 菜单文件的结构
+type menuFile struct {
+	Flags map[string]menuFlag   `yaml:"flags"`
+	Menus map[string][]menuItem `yaml:"menus"`
+}
+
+// menuStore 菜单快照，加载后只读；每个菜单的项已按 order 排序
+type menuStore struct {
+	menus    map[string][]menuItem
+	flags    map[string]menuFlag
+	source   string
+	loadedAt time.Time
+}
+
+var clientMenus atomic.Pointer[menuStore]
+
+// currentMenuStore 当前菜单；尚未加载时使用内置定义
+func currentMenuStore() *menuStore {
+	if store := clientMenus.Load(); store != nil {
+		return store
+	}
+	store, err := loadMenuStore("")
+	if err != nil {
+		log.Fatalf("内置菜单定义无效: %v", err)
+	}
+	clientMenus.CompareAndSwap(nil, store)
+	return clientMenus.Load()
+}
+
+// loadMenuStore 读取 path 指定的菜单文件（为空时使用内置定义）并校验
+func loadMenuStore(path string) (*menuStore, error) {
+	data, source := embeddedMenus, "builtin"
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+		source = path
+	}
+
+	var file menuFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return nil, err
+	}
+	if err := file.validate(); err != nil {
+		return nil, err
+	}
+
+	store := &menuStore{menus: file.Menus, flags: file.Flags, source: source, loadedAt: time.Now()}
+	if store.flags == nil {
+		store.flags = map[string]menuFlag{}
+	}
+	for _, items := range store.menus {
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Order < items[j].Order })
+	}
+	return store, nil
+}
+
+// validate 检查菜单项的 id、文案、权限、功能开关与子菜单引用，子菜单不能形成环
+func (f *menuFile) validate() error {
+	if len(f.Menus) == 0 {
+		return errors.New("no menus defined")
+	}
+	for name, items := range f.Menus {
+		seen := make(map[string]bool, len(items))
+		for _, item := range items {
+			if item.ID == "" {
+				return fmt.Errorf("menu %s: item %q has no id", name, item.Label)
+			}
+			if seen[item.ID] {
+				return fmt.Errorf("menu %s: duplicate item id %q", name, item.ID)
+			}
+			seen[item.ID] = true
+			if item.Label == "" {
+				return fmt.Errorf("menu %s/%s: missing label", name, item.ID)
+			}
+			for locale, text := range item.Labels {
+				if locale == "" || text == "" {
+					return fmt.Errorf("menu %s/%s: empty translation for locale %q", name, item.ID, locale)
+				}
+			}
+			for _, p := range item.Require {
+				if !slices.Contains(allPermissions, p) {
+					return fmt.Errorf("menu %s/%s: unknown permission %q", name, item.ID, p)
+				}
+			}
+			if _, ok := f.Flags[item.Flag]; item.Flag != "" && !ok {
+				return fmt.Errorf("menu %s/%s: unknown flag %q", name, item.ID, item.Flag)
+			}
+			if _, ok := f.Menus[item.Submenu]; item.Submenu != "" && !ok {
+				return fmt.Errorf("menu %s/%s: unknown submenu %q", name, item.ID, item.Submenu)
+			}
+		}
+	}
+
+	// 从每个菜单出发做深度优先搜索，遇到仍在路径上的菜单即为环
+	const visiting, done = 1, 2
+	state := make(map[string]int, len(f.Menus))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("menu %s: submenus form a cycle", name)
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, item := range f.Menus[name] {
+			if item.Submenu != "" {
+				if err := visit(item.Submenu); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = done
+		return nil
+	}
+	for name := range f.Menus {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reloadMenus 重新加载 MENUS_FILE，失败时保留当前菜单
+func reloadMenus() error {
+	store, err := loadMenuStore(menusFile)
+	if err != nil {
+		return err
+	}
+	clientMenus.Store(store)
+	log.Printf("客户端菜单已从 %s 加载", store.source)
+	return nil
+}
+
+// flagEnabled 功能开关对 Key 是否开启：Key 上的 flag:<名称> 字段优先于默认状态
+func (s *menuStore) flagEnabled(record *keyRecord, name string) bool {
+	if name == "" {
+		return true
+	}
+	if enabled, ok := record.FlagOverride(name); ok {
+		return enabled
+	}
+	return s.flags[name].Enabled
+}
+
+// visibleItems 菜单中 Key 可以看到的项
+func (s *menuStore) visibleItems(name string, record *keyRecord) []menuItem {
+	var items []menuItem
+	for _, item := range s.menus[name] {
+		if record.HasAll(item.Require) && s.flagEnabled(record, item.Flag) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// labels 菜单项文案列表
+func (s *menuStore) labels(name string, record *keyRecord, prefs []string) []string {
+	labels := []string{}
+	for _, item := range s.visibleItems(name, record) {
+		labels = append(labels, localize(item.Label, item.Labels, prefs))
+	}
+	return labels
+}
+
+// tree 展开子菜单后的菜单树
+func (s *menuStore) tree(name string, record *keyRecord, prefs []string) []MenuNode {
+	nodes := []MenuNode{}
+	for _, item := range s.visibleItems(name, record) {
+		node := MenuNode{ID: item.ID, Label: localize(item.Label, item.Labels, prefs)}
+		if item.Submenu != "" {
+			node.Children = s.tree(item.Submenu, record, prefs)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// provideMenu 网关 provider：args.menu 菜单的文案列表
+func provideMenu(c *gin.Context, req gatewayRequest, args map[string]string) (any, bool) {
+	store := currentMenuStore()
+	if _, ok := store.menus[args["menu"]]; !ok {
+		respondErrorCode(c, http.StatusInternalServerError, msgInvalidTargetDefinition)
+		return nil, false
+	}
+	return store.labels(args["menu"], currentKeyRecord(c), preferredLocales(c)), true
+}
+
+// provideMenuTree 网关 provider：以 args.menu 为根的菜单树
+func provideMenuTree(c *gin.Context, req gatewayRequest, args map[string]string) (any, bool) {
+	store := currentMenuStore()
+	if _, ok := store.menus[args["menu"]]; !ok {
+		respondErrorCode(c, http.StatusInternalServerError, msgInvalidTargetDefinition)
+		return nil, false
+	}
+	return store.tree(args["menu"], currentKeyRecord(c), preferredLocales(c)), true
+}
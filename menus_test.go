@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBuiltinMenus(t *testing.T) {
+	store, err := loadMenuStore("")
+	if err != nil {
+		t.Fatalf("builtin menus are invalid: %v", err)
+	}
+
+	all := newKeyRecord("k", map[string]string{"useTaie": "true", "useShop": "true", "useLight": "true"})
+	got := strings.Join(store.labels("main", all, nil), ",")
+	if got != "实,虚,转盘v2,商店抽奖,玉米农场,亮评,退出" {
+		t.Errorf("main = %s", got)
+	}
+	if sign := store.labels("sign", all, nil); len(sign) != 11 || sign[1] != "添加单个转盘(暂不可用)" {
+		t.Errorf("sign = %v", sign)
+	}
+
+	shopOnly := newKeyRecord("k", map[string]string{"useShop": "true"})
+	if got := strings.Join(store.labels("main", shopOnly, nil), ","); got != "玉米农场,退出" {
+		t.Errorf("main for useShop = %s", got)
+	}
+}
+
+func TestMenuFlagsAndLocales(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "menus.yaml")
+	os.WriteFile(path, []byte(`
+flags:
+  beta: {description: 新功能, enabled: false}
+menus:
+  main:
+    - {id: exit, label: 退出, labels: {en: Exit}, order: 100}
+    - {id: more, label: 更多, labels: {en: More, en-GB: More…}, order: 10, submenu: more}
+  more:
+    - {id: beta, label: 测试功能, flag: beta}
+    - {id: back, label: 返回}
+`), 0600)
+	store, err := loadMenuStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := newKeyRecord("k", map[string]string{"useTaie": "true"})
+	tree := store.tree("main", record, nil)
+	if len(tree) != 2 || tree[0].ID != "more" || len(tree[0].Children) != 1 || tree[0].Children[0].ID != "back" {
+		t.Errorf("tree = %+v", tree)
+	}
+
+	// 按 Key 开启默认关闭的功能
+	beta := newKeyRecord("k", map[string]string{"useTaie": "true", keyFlagFieldPrefix + "beta": "true"})
+	if got := store.labels("more", beta, nil); len(got) != 2 || got[0] != "测试功能" {
+		t.Errorf("more with beta = %v", got)
+	}
+
+	for _, tc := range []struct {
+		prefs []string
+		want  string
+	}{
+		{nil, "更多,退出"},
+		{[]string{"en-US"}, "More,Exit"},
+		{[]string{"en-GB"}, "More…,Exit"},
+		{[]string{"zh-TW", "en"}, "更多,退出"},
+		{[]string{"fr", "en"}, "More,Exit"},
+	} {
+		if got := strings.Join(store.labels("main", record, tc.prefs), ","); got != tc.want {
+			t.Errorf("labels(%v) = %s, want %s", tc.prefs, got, tc.want)
+		}
+	}
+}
+
+func TestMenuValidation(t *testing.T) {
+	for name, content := range map[string]string{
+		"cycle":           "menus: {a: [{id: x, label: x, submenu: b}], b: [{id: y, label: y, submenu: a}]}",
+		"unknown flag":    "menus: {a: [{id: x, label: x, flag: nope}]}",
+		"unknown submenu": "menus: {a: [{id: x, label: x, submenu: b}]}",
+		"unknown perm":    "menus: {a: [{id: x, label: x, require: [useEverything]}]}",
+		"duplicate id":    "menus: {a: [{id: x, label: x}, {id: x, label: y}]}",
+		"missing label":   "menus: {a: [{id: x}]}",
+		"unknown field":   "menus: {a: [{id: x, label: x, hidden: true}]}",
+	} {
+		path := filepath.Join(t.TempDir(), "menus.yaml")
+		os.WriteFile(path, []byte(content), 0600)
+		if _, err := loadMenuStore(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPreferredLocales(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Request.Header.Set("Accept-Language", "fr;q=0.5, en-US, de;q=0, *;q=0.1, ja;q=0.8")
+	if got := strings.Join(preferredLocales(c), ","); got != "en-US,ja,fr" {
+		t.Errorf("preferredLocales = %s", got)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- 错误码与消息目录 ---
+//
+// 返回给客户端的错误与提示消息都通过稳定的消息码从目录中取得：错误响应为
+// {"status": 401, "code": "invalid_key", "error": "<按语言选择的消息>"}，提示消息为 {"code": ..., "message": ...}。
+// 客户端应按 code 判断错误类型，error / message 只用于展示，可能随语言与版本变化。
+// 每个语言一份目录，新增语言只需增加一份目录；目录中缺少的消息回退到英文（defaultMessageLocale）。
+// 带参数的消息使用 fmt 格式，参数的个数与顺序在所有语言中保持一致。
+
+type messageCode string
+
+const (
+	// 认证
+	msgMissingToken          messageCode = "missing_token"
+	msgMissingDef            messageCode = "missing_def"
+	msgAuthLockedOut         messageCode = "auth_locked_out"
+	msgKeyLookupFailed       messageCode = "key_lookup_failed"
+	msgInvalidKey            messageCode = "invalid_key"
+	msgFeatureNotAllowed     messageCode = "feature_not_allowed"
+	msgKeyBanned             messageCode = "key_banned"
+	msgGeolocationFailed     messageCode = "geolocation_failed"
+	msgLocationUpdateFailed  messageCode = "location_update_failed"
+	msgLocationBindFailed    messageCode = "location_bind_failed"
+	msgCityUpdateFailed      messageCode = "city_update_failed"
+	msgBannedProvince        messageCode = "banned_province"
+	msgBannedCityLimit       messageCode = "banned_city_limit"
+	msgTokenGenerationFailed messageCode = "token_generation_failed"
+	msgChallengeIssueFailed  messageCode = "challenge_issue_failed"
+	msgChallengeRequired     messageCode = "challenge_required"
+	msgChallengeFailed       messageCode = "challenge_failed"
+	msgUpgradeRequired       messageCode = "upgrade_required"
+
+	// 权限、限流与配额
+	msgAuthKeyMissing        messageCode = "auth_key_missing"
+	msgPermissionDenied      messageCode = "permission_denied"
+	msgPermissionCheckFailed messageCode = "permission_check_failed"
+	msgRateLimited           messageCode = "rate_limited"
+	msgQuotaExceeded         messageCode = "quota_exceeded"
+	msgUsageFetchFailed      messageCode = "usage_fetch_failed"
+
+	// 网关
+	msgUnknownTarget           messageCode = "unknown_target"
+	msgUnknownModuleParameter  messageCode = "unknown_module_parameter"
+	msgInvalidTargetDefinition messageCode = "invalid_target_definition"
+	msgMissingTargetParams     messageCode = "missing_target_params"
+	msgRoundFetchFailed        messageCode = "round_fetch_failed"
+	msgLotteryFetchFailed      messageCode = "lottery_fetch_failed"
+	msgShopProductsFailed      messageCode = "shop_products_failed"
+
+	// 中间件
+	msgMissingAuthorization messageCode = "missing_authorization"
+	msgInvalidAuthorization messageCode = "invalid_authorization"
+	msgInvalidToken         messageCode = "invalid_token"
+	msgTokenExpired         messageCode = "token_expired"
+	msgMissingIntegrity     messageCode = "missing_integrity_headers"
+	msgInvalidTimestamp     messageCode = "invalid_timestamp"
+	msgTimestampOutOfRange  messageCode = "timestamp_out_of_range"
+	msgInvalidSignature     messageCode = "invalid_signature"
+	msgNotFound             messageCode = "not_found"
+	msgInvalidAdminToken    messageCode = "invalid_admin_token"
+	msgEmptyResponse        messageCode = "empty_response"
+
+	// 请求参数
+	msgNotAuthenticated   messageCode = "not_authenticated"
+	msgInvalidRequestBody messageCode = "invalid_request_body"
+	msgMissingParameter   messageCode = "missing_parameter"
+	msgMissingParameters  messageCode = "missing_parameters"
+	msgInvalidParameter   messageCode = "invalid_parameter"
+	msgInvalidQuery       messageCode = "invalid_query"
+
+	// 任务与活动
+	msgTaskFetchFailed        messageCode = "task_fetch_failed"
+	msgNoMoreTasks            messageCode = "no_more_tasks"
+	msgTaskSubmitted          messageCode = "task_submitted"
+	msgTaskSaveFailed         messageCode = "task_save_failed"
+	msgActivitiesFetchFailed  messageCode = "activities_fetch_failed"
+	msgActivitiesSearchFailed messageCode = "activities_search_failed"
+	msgActivityLogFailed      messageCode = "activity_log_failed"
+	msgShopChangesFailed      messageCode = "shop_changes_failed"
+
+	// 报告与运行历史
+	msgUnknownTemplate    messageCode = "unknown_template"
+	msgReportDataMismatch messageCode = "report_data_mismatch"
+	msgReportSaveFailed   messageCode = "report_save_failed"
+	msgInvalidRunStats    messageCode = "invalid_run_stats"
+	msgRanAtInFuture      messageCode = "ran_at_in_future"
+	msgInvalidDate        messageCode = "invalid_date"
+	msgInvalidDateRange   messageCode = "invalid_date_range"
+	msgRunSaveFailed      messageCode = "run_save_failed"
+	msgRunsFetchFailed    messageCode = "runs_fetch_failed"
+	msgTrendsFetchFailed  messageCode = "trends_fetch_failed"
+
+	// 搜索缓存
+	msgInvalidCacheData messageCode = "invalid_cache_data"
+	msgEmptyCacheData   messageCode = "empty_cache_data"
+	msgCacheSubmitted   messageCode = "cache_submitted"
+
+	// APK 构建
+	msgApkJobFailed      messageCode = "apk_job_failed"
+	msgApkJobSubmitted   messageCode = "apk_job_submitted"
+	msgApkNotReady       messageCode = "apk_not_ready"
+	msgApkPathMissing    messageCode = "apk_path_missing"
+	msgApkDownloadFailed messageCode = "apk_download_failed"
+	msgApkUpstreamError  messageCode = "apk_upstream_error"
+)
+
+const defaultMessageLocale = "en"
+
+// messageCatalogs 各语言的消息目录，键为语言标签
+var messageCatalogs = map[string]map[messageCode]string{
+	"en": {
+		msgMissingToken:            "X-Token header is required",
+		msgMissingDef:              "X-Def header is required",
+		msgAuthLockedOut:           "Too many failed attempts, try again later",
+		msgKeyLookupFailed:         "Database error on key check",
+		msgInvalidKey:              "Invalid X-Token",
+		msgFeatureNotAllowed:       "You're not allowed to use this software.",
+		msgKeyBanned:               "This key has been banned due to security policy violations.",
+		msgGeolocationFailed:       "IP geolocation failed: %v",
+		msgLocationUpdateFailed:    "Failed to update location for whitelisted key",
+		msgLocationBindFailed:      "Failed to bind location",
+		msgCityUpdateFailed:        "Failed to update city list",
+		msgBannedProvince:          "Security risk: Access from a different province is not allowed. This key has been banned.",
+		msgBannedCityLimit:         "Security risk: Access from more than 3 cities is not allowed. This key has been banned.",
+		msgTokenGenerationFailed:   "Failed to generate token",
+		msgChallengeIssueFailed:    "Failed to issue challenge",
+		msgChallengeRequired:       "Proof-of-work challenge required",
+		msgChallengeFailed:         "Proof-of-work challenge failed: %v",
+		msgUpgradeRequired:         "Client version is no longer supported, please upgrade",
+		msgAuthKeyMissing:          "Internal server error: authentication key missing",
+		msgPermissionDenied:        "You do not have permission to access this resource",
+		msgPermissionCheckFailed:   "Failed to verify permissions",
+		msgRateLimited:             "Rate limit exceeded",
+		msgQuotaExceeded:           "Daily quota exceeded",
+		msgUsageFetchFailed:        "Failed to load usage",
+		msgUnknownTarget:           "Unknown target",
+		msgUnknownModuleParameter:  "Unknown module parameter",
+		msgInvalidTargetDefinition: "Invalid target definition",
+		msgMissingTargetParams:     "Missing 'params' in request body for target '%s'",
+		msgRoundFetchFailed:        "Failed to process round data",
+		msgLotteryFetchFailed:      "Failed to process lottery data",
+		msgShopProductsFailed:      "Failed to load shop products",
+		msgMissingAuthorization:    "Authorization header is required",
+		msgInvalidAuthorization:    "Authorization header format must be Bearer {token}",
+		msgInvalidToken:            "Invalid token",
+		msgTokenExpired:            "Token has expired",
+		msgMissingIntegrity:        "Missing required integrity headers",
+		msgInvalidTimestamp:        "Invalid timestamp format",
+		msgTimestampOutOfRange:     "Timestamp is out of date",
+		msgInvalidSignature:        "Invalid signature",
+		msgNotFound:                "Not found",
+		msgInvalidAdminToken:       "Invalid admin token",
+		msgEmptyResponse:           "No data to encrypt",
+		msgNotAuthenticated:        "Not authenticated",
+		msgInvalidRequestBody:      "Invalid request body: %v",
+		msgMissingParameter:        "%s is required",
+		msgMissingParameters:       "Missing required parameters",
+		msgInvalidParameter:        "Invalid %s parameter",
+		msgInvalidQuery:            "Missing required parameters: %v",
+		msgTaskFetchFailed:         "Failed to get a new task",
+		msgNoMoreTasks:             "No more tasks available",
+		msgTaskSubmitted:           "Task result submitted successfully",
+		msgTaskSaveFailed:          "Failed to save task result",
+		msgActivitiesFetchFailed:   "Failed to retrieve activities",
+		msgActivitiesSearchFailed:  "Failed to search activities",
+		msgActivityLogFailed:       "Failed to save activity log",
+		msgShopChangesFailed:       "Failed to load shop changes",
+		msgUnknownTemplate:         "Unknown template",
+		msgReportDataMismatch:      "Report data does not match the template",
+		msgReportSaveFailed:        "Failed to save report",
+		msgInvalidRunStats:         "Invalid run statistics: %v",
+		msgRanAtInFuture:           "ran_at is in the future",
+		msgInvalidDate:             "Invalid date, expected YYYY-MM-DD",
+		msgInvalidDateRange:        "Invalid date range",
+		msgRunSaveFailed:           "Failed to save run",
+		msgRunsFetchFailed:         "Failed to load runs",
+		msgTrendsFetchFailed:       "Failed to load trends",
+		msgInvalidCacheData:        "Invalid cache data: %v",
+		msgEmptyCacheData:          "Data cannot be empty",
+		msgCacheSubmitted:          "Cache submitted successfully",
+		msgApkJobFailed:            "Failed to add build job",
+		msgApkJobSubmitted:         "ok",
+		msgApkNotReady:             "APK is not ready",
+		msgApkPathMissing:          "APK file not found",
+		msgApkDownloadFailed:       "Download failed",
+		msgApkUpstreamError:        "Upstream error",
+	},
+	"zh-CN": {
+		msgMissingToken:            "缺少 X-Token 请求头",
+		msgMissingDef:              "缺少 X-Def 请求头",
+		msgAuthLockedOut:           "失败次数过多，请稍后再试",
+		msgKeyLookupFailed:         "校验 Key 时数据库出错",
+		msgInvalidKey:              "X-Token 无效",
+		msgFeatureNotAllowed:       "该 Key 无权使用此软件。",
+		msgKeyBanned:               "该 Key 因违反安全策略已被封禁。",
+		msgGeolocationFailed:       "查询 IP 归属地失败: %v",
+		msgLocationUpdateFailed:    "更新白名单 Key 的位置信息失败",
+		msgLocationBindFailed:      "绑定地区失败",
+		msgCityUpdateFailed:        "更新城市列表失败",
+		msgBannedProvince:          "安全风险：不允许跨省使用，该 Key 已被封禁。",
+		msgBannedCityLimit:         "安全风险：不允许在 3 个以上的城市使用，该 Key 已被封禁。",
+		msgTokenGenerationFailed:   "生成令牌失败",
+		msgChallengeIssueFailed:    "签发挑战失败",
+		msgChallengeRequired:       "需要先完成工作量证明挑战",
+		msgChallengeFailed:         "工作量证明挑战未通过: %v",
+		msgUpgradeRequired:         "客户端版本已不再受支持，请升级",
+		msgAuthKeyMissing:          "服务器内部错误：缺少认证 Key",
+		msgPermissionDenied:        "无权访问该资源",
+		msgPermissionCheckFailed:   "校验权限失败",
+		msgRateLimited:             "请求过于频繁，请稍后再试",
+		msgQuotaExceeded:           "今日配额已用完",
+		msgUsageFetchFailed:        "查询用量失败",
+		msgUnknownTarget:           "未知的目标",
+		msgUnknownModuleParameter:  "未知的模块参数",
+		msgInvalidTargetDefinition: "目标定义无效",
+		msgMissingTargetParams:     "目标 %s 的请求体缺少 params",
+		msgRoundFetchFailed:        "获取转盘数据失败",
+		msgLotteryFetchFailed:      "获取抽奖数据失败",
+		msgShopProductsFailed:      "加载商品目录失败",
+		msgMissingAuthorization:    "缺少 Authorization 请求头",
+		msgInvalidAuthorization:    "Authorization 请求头的格式必须为 Bearer {token}",
+		msgInvalidToken:            "令牌无效",
+		msgTokenExpired:            "令牌已过期",
+		msgMissingIntegrity:        "缺少完整性校验请求头",
+		msgInvalidTimestamp:        "时间戳格式无效",
+		msgTimestampOutOfRange:     "时间戳已过期",
+		msgInvalidSignature:        "签名无效",
+		msgNotFound:                "未找到",
+		msgInvalidAdminToken:       "管理令牌无效",
+		msgEmptyResponse:           "没有可加密的数据",
+		msgNotAuthenticated:        "用户未认证",
+		msgInvalidRequestBody:      "请求体无效: %v",
+		msgMissingParameter:        "缺少参数 %s",
+		msgMissingParameters:       "缺少必填参数",
+		msgInvalidParameter:        "参数 %s 无效",
+		msgInvalidQuery:            "缺少必填参数: %v",
+		msgTaskFetchFailed:         "获取任务失败",
+		msgNoMoreTasks:             "没有更多任务了",
+		msgTaskSubmitted:           "任务结果已提交",
+		msgTaskSaveFailed:          "保存任务结果失败",
+		msgActivitiesFetchFailed:   "获取活动失败",
+		msgActivitiesSearchFailed:  "搜索活动失败",
+		msgActivityLogFailed:       "保存活动记录失败",
+		msgShopChangesFailed:       "查询商店数据变更失败",
+		msgUnknownTemplate:         "未知的报告模板",
+		msgReportDataMismatch:      "报告数据与模板不匹配",
+		msgReportSaveFailed:        "保存报告失败",
+		msgInvalidRunStats:         "运行统计无效: %v",
+		msgRanAtInFuture:           "ran_at 晚于当前时间",
+		msgInvalidDate:             "日期无效，格式应为 YYYY-MM-DD",
+		msgInvalidDateRange:        "日期区间无效",
+		msgRunSaveFailed:           "保存运行记录失败",
+		msgRunsFetchFailed:         "查询运行记录失败",
+		msgTrendsFetchFailed:       "查询趋势失败",
+		msgInvalidCacheData:        "缓存数据无效: %v",
+		msgEmptyCacheData:          "数据不能为空",
+		msgCacheSubmitted:          "缓存已提交",
+		msgApkJobFailed:            "添加构建任务失败",
+		msgApkJobSubmitted:         "构建任务已提交",
+		msgApkNotReady:             "安装包尚未构建完成",
+		msgApkPathMissing:          "找不到安装包文件",
+		msgApkDownloadFailed:       "下载失败",
+		msgApkUpstreamError:        "上游服务出错",
+	},
+}
+
+// messageLocales 有消息目录的语言
+func messageLocales() []string {
+	return slices.Sorted(maps.Keys(messageCatalogs))
+}
+
+// messageLocale 按 prefs 选择消息目录的语言
+func messageLocale(prefs []string) string {
+	if tag, ok := matchLocale(messageLocales(), prefs); ok {
+		return tag
+	}
+	return defaultMessageLocale
+}
+
+// text 按 prefs 选择语言并代入 args 的消息文本
+func (code messageCode) text(prefs []string, args ...any) string {
+	format, ok := messageCatalogs[messageLocale(prefs)][code]
+	if !ok {
+		format, ok = messageCatalogs[defaultMessageLocale][code]
+	}
+	if !ok {
+		return string(code)
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// respondErrorCode 以消息码写出错误响应，消息按请求的语言选择；加密路由与 respondError 一样加密返回
+func respondErrorCode(c *gin.Context, status int, code messageCode, args ...any) {
+	writeErrorResponse(c, ErrorResponse{Status: status, Code: code, Error: code.text(preferredLocales(c), args...)})
+}
+
+// respondMessage 以消息码写出提示消息，extra 中的字段一并返回
+func respondMessage(c *gin.Context, status int, code messageCode, extra gin.H) {
+	body := gin.H{"code": code, "message": code.text(preferredLocales(c))}
+	for k, v := range extra {
+		body[k] = v
+	}
+	c.JSON(status, body)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMessageCatalogsComplete(t *testing.T) {
+	verbs := regexp.MustCompile(`%[vsd]`)
+	base := messageCatalogs[defaultMessageLocale]
+	for locale, catalog := range messageCatalogs {
+		if len(catalog) != len(base) {
+			t.Errorf("%s has %d messages, %s has %d", locale, len(catalog), defaultMessageLocale, len(base))
+		}
+		for code, text := range catalog {
+			want, ok := base[code]
+			if !ok {
+				t.Errorf("%s/%s is missing from %s", locale, code, defaultMessageLocale)
+				continue
+			}
+			if got, want := verbs.FindAllString(text, -1), verbs.FindAllString(want, -1); len(got) != len(want) {
+				t.Errorf("%s/%s takes %d arguments, %s takes %d", locale, code, len(got), defaultMessageLocale, len(want))
+			}
+		}
+	}
+}
+
+func TestRespondErrorCodeLocalized(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		header   string
+		fields   map[string]string
+		wantText string
+	}{
+		{"default", "", nil, "status is required"},
+		{"accept-language", "zh-TW, en;q=0.5", nil, "缺少参数 status"},
+		{"unknown locale", "fr", nil, "status is required"},
+		{"key preference wins", "zh-CN", map[string]string{keyLocaleField: "en"}, "status is required"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/", nil)
+			c.Request.Header.Set("Accept-Language", tc.header)
+			if tc.fields != nil {
+				c.Set(keyRecordContextKey, newKeyRecord("k", tc.fields))
+			}
+
+			respondErrorCode(c, http.StatusBadRequest, msgMissingParameter, "status")
+
+			var body ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusBadRequest || body.Code != msgMissingParameter || body.Error != tc.wantText {
+				t.Errorf("got %d %+v", w.Code, body)
+			}
+		})
+	}
+}
@@ -2,8 +2,9 @@ package main
 
 import (
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -20,13 +21,13 @@ func authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			respondErrorCode(c, http.StatusUnauthorized, msgMissingAuthorization)
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
+			respondErrorCode(c, http.StatusUnauthorized, msgInvalidAuthorization)
 			return
 		}
 
@@ -38,8 +39,13 @@ func authMiddleware() gin.HandlerFunc {
 			return []byte(jwtSecretKey), nil
 		})
 
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			respondErrorCode(c, http.StatusUnauthorized, msgTokenExpired)
+			return
+		}
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("Invalid token: %v", err)})
+			log.Printf("来自 %s 的请求携带了无效的令牌: %v", c.ClientIP(), err)
+			respondErrorCode(c, http.StatusUnauthorized, msgInvalidToken)
 			return
 		}
 
@@ -48,7 +54,7 @@ func authMiddleware() gin.HandlerFunc {
 			c.Set("longTermKey", claims["sub"])
 			c.Next()
 		} else {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			respondErrorCode(c, http.StatusUnauthorized, msgInvalidToken)
 		}
 	}
 }
@@ -60,25 +66,25 @@ func appIntegrityMiddleware() gin.HandlerFunc {
 		clientSignature := c.GetHeader("X-Signature")
 
 		if timestampStr == "" || clientSignature == "" {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing required integrity headers"})
+			respondErrorCode(c, http.StatusForbidden, msgMissingIntegrity)
 			return
 		}
 
 		// 1. 校验时间戳 (允许10秒的误差范围)
 		timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid timestamp format"})
+			respondErrorCode(c, http.StatusForbidden, msgInvalidTimestamp)
 			return
 		}
 
 		if time.Now().Unix()-timestamp > 5 || timestamp-time.Now().Unix() > 5 {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Timestamp is out of date"})
+			respondErrorCode(c, http.StatusForbidden, msgTimestampOutOfRange)
 			return
 		}
 
 		// 2. 在服务器端重新计算签名
-		path := c.Request.URL.Path
-		payload := fmt.Sprintf("%s,%s,%s", path, timestampStr, appIntegritySecret)
+		path := originalRequestPath(c.Request)
+		payload := fmt.Sprintf("%s,%s,%s", path, timestampStr, appIntegritySecret.Reveal())
 
 		hasher := sha256.New()
 		hasher.Write([]byte(payload))
@@ -86,211 +92,7 @@ func appIntegrityMiddleware() gin.HandlerFunc {
 
 		// 3. 比较签名
 		if serverSignature != clientSignature {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid signature"})
-			return
-		}
-
-		c.Next()
-	}
-}
-
-// 检查用户是否拥有访问taie的权限
-func taiePermissionMiddlerware(force bool) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		longTermKey, exists := c.Get("longTermKey")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error: authentication key missing"})
-			return
-		}
-
-		keyData, err := swordRdb.HGetAll(ctx, longTermKey.(string)).Result()
-		if err != nil {
-			fmt.Printf("Failed to retrieve key data from Redis for %s: %v\n", longTermKey, err)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
-			return
-		}
-
-		if force {
-			if keyData["useTaie"] != "true" {
-				fmt.Printf("Access denied for key %s: useTaie permission not granted\n", longTermKey)
-				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
-				return
-			}
-		} else {
-			if keyData["useTaie"] != "true" {
-				c.Set("useTaie", false)
-			} else {
-				c.Set("useTaie", true)
-			}
-		}
-
-		c.Next()
-	}
-}
-
-// 检查用户是否拥有商店奖券的权限
-func shopPermissionMiddlerware(force bool) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		longTermKey, exists := c.Get("longTermKey")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error: authentication key missing"})
-			return
-		}
-
-		keyData, err := swordRdb.HGetAll(ctx, longTermKey.(string)).Result()
-		if err != nil {
-			fmt.Printf("Failed to retrieve key data from Redis for %s: %v\n", longTermKey, err)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
-			return
-		}
-
-		if force {
-			if keyData["useShop"] != "true" {
-				fmt.Printf("Access denied for key %s: useShop permission not granted\n", longTermKey)
-				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
-				return
-			}
-		} else {
-			if keyData["useShop"] != "true" {
-				c.Set("useShop", false)
-			} else {
-				c.Set("useShop", true)
-			}
-		}
-
-		c.Next()
-	}
-}
-
-// 检查用户是否拥有亮评的权限
-func lightPermissionMiddlerware(force bool) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		longTermKey, exists := c.Get("longTermKey")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error: authentication key missing"})
-			return
-		}
-
-		keyData, err := swordRdb.HGetAll(ctx, longTermKey.(string)).Result()
-		if err != nil {
-			fmt.Printf("Failed to retrieve key data from Redis for %s: %v\n", longTermKey, err)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
-			return
-		}
-
-		if force {
-			if keyData["useLight"] != "true" {
-				fmt.Printf("Access denied for key %s: useLight permission not granted\n", longTermKey)
-				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
-				return
-			}
-		} else {
-			if keyData["useLight"] != "true" {
-				c.Set("useLight", false)
-			} else {
-				c.Set("useLight", true)
-			}
-		}
-
-		c.Next()
-	}
-}
-
-// activitiesPermissionMiddleware 检查用户是否拥有访问活动的权限
-func activitiesPermissionMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		longTermKey, exists := c.Get("longTermKey")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error: authentication key missing"})
-			return
-		}
-
-		keyData, err := swordRdb.HGetAll(ctx, longTermKey.(string)).Result()
-		if err != nil {
-			fmt.Printf("Failed to retrieve key data from Redis for %s: %v\n", longTermKey, err)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
-			return
-		}
-
-		if keyData["useActivities"] != "true" {
-			fmt.Printf("Access denied for key %s: useActivities permission not granted\n", longTermKey)
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
-			return
-		}
-
-		c.Next()
-	}
-}
-
-// cyberPermissionMiddleware 检查用户是否有构造安装包的权限
-func cyberPermissionMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		longTermKey, exists := c.Get("longTermKey")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error: authentication key missing"})
-			return
-		}
-
-		keyData, err := swordRdb.HGetAll(ctx, longTermKey.(string)).Result()
-		if err != nil {
-			fmt.Printf("Failed to retrieve key data from Redis for %s: %v\n", longTermKey, err)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
-			return
-		}
-
-		if keyData["useCyber"] != "true" {
-			fmt.Printf("Access denied for key %s: useCyber permission not granted\n", longTermKey)
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
-			return
-		}
-
-		c.Next()
-	}
-}
-
-func wooPermissionMiddlerware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		longTermKey, exists := c.Get("longTermKey")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error: authentication key missing"})
-			return
-		}
-
-		keyData, err := swordRdb.HGetAll(ctx, longTermKey.(string)).Result()
-		if err != nil {
-			fmt.Printf("Failed to retrieve key data from Redis for %s: %v\n", longTermKey, err)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
-			return
-		}
-
-		if keyData["useWoo"] != "true" {
-			fmt.Printf("Access denied for key %s: useWoo permission not granted\n", longTermKey)
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
-			return
-		}
-
-		c.Next()
-	}
-}
-
-func wooProPermissionMiddlerware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		longTermKey, exists := c.Get("longTermKey")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error: authentication key missing"})
-			return
-		}
-
-		keyData, err := swordRdb.HGetAll(ctx, longTermKey.(string)).Result()
-		if err != nil {
-			fmt.Printf("Failed to retrieve key data from Redis for %s: %v\n", longTermKey, err)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
-			return
-		}
-
-		if keyData["useWooPro"] != "true" {
-			fmt.Printf("Access denied for key %s: useWoo permission not granted\n", longTermKey)
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
+			respondErrorCode(c, http.StatusForbidden, msgInvalidSignature)
 			return
 		}
 
@@ -298,24 +100,18 @@ func wooProPermissionMiddlerware() gin.HandlerFunc {
 	}
 }
 
-func whiteUserMiddlerware() gin.HandlerFunc {
+// adminMiddleware 校验 X-Admin-Token；未配置 ADMIN_TOKEN 时管理接口整体关闭
+func adminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		longTermKey, exists := c.Get("longTermKey")
-		if !exists {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error: authentication key missing"})
-			return
-		}
-
-		keyData, err := swordRdb.HGetAll(ctx, longTermKey.(string)).Result()
-		if err != nil {
-			fmt.Printf("Failed to retrieve key data from Redis for %s: %v\n", longTermKey, err)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
+		if adminToken == "" {
+			respondErrorCode(c, http.StatusNotFound, msgNotFound)
 			return
 		}
 
-		if keyData["whitelisted"] != "true" {
-			fmt.Printf("Access denied for key %s: useWoo permission not granted\n", longTermKey)
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
+		token := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			log.Printf("安全警报: 来自 %s 的管理接口请求使用了无效的 X-Admin-Token", c.ClientIP())
+			respondErrorCode(c, http.StatusUnauthorized, msgInvalidAdminToken)
 			return
 		}
 
@@ -323,34 +119,16 @@ func whiteUserMiddlerware() gin.HandlerFunc {
 	}
 }
 
-// encryptionMiddleware	响应加密中间件
+// encryptionMiddleware 标记当前路由的响应需要加密
+// 处理函数通过 respondEncrypted / respondErrorCode 写出响应；若处理函数什么都没写，这里补一个加密的 500 错误
 func encryptionMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		c.Set(encryptedRouteKey, true)
 		c.Next()
 
-		longTermKey, _ := c.Get("longTermKey")
-		dataToEncrypt, exists := c.Get("dataToEncrypt")
-
-		if !exists {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "No data to encrypt"})
-			return
-		}
-
-		log.Printf("本次响应: %v", dataToEncrypt)
-		jsonData, err := json.Marshal(dataToEncrypt)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to serialize data"})
-			return
-		}
-
-		encryptionKey := []byte(longTermKey.(string))
-		encryptedPayload, err := encrypt(jsonData, encryptionKey)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Encryption failed: %v", err)})
-			return
+		if !c.Writer.Written() {
+			log.Printf("警告: %s 的处理函数没有写出响应", c.Request.URL.Path)
+			respondErrorCode(c, http.StatusInternalServerError, msgEmptyResponse)
 		}
-
-		c.Status(http.StatusOK)
-		c.JSON(http.StatusOK, EncryptedResponse{Payload: encryptedPayload})
 	}
 }
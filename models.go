@@ -17,8 +17,17 @@ type GeoInfo struct {
 }
 
 // EncryptedResponse API 返回的加密数据结构
+// Encoding 非空时表示 payload 解密后的明文经过了对应算法的压缩
 type EncryptedResponse struct {
-	Payload string `json:"payload"`
+	Payload  string `json:"payload"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// ErrorResponse 统一的错误响应结构，加密路由上作为明文加密后返回；Code 为稳定的消息码，见 messages.go
+type ErrorResponse struct {
+	Status int         `json:"status"`
+	Code   messageCode `json:"code,omitempty"`
+	Error  string      `json:"error"`
 }
 
 // Item 用于描述 Awards 数组中的项目
@@ -195,3 +204,236 @@ func (r *Result) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// UsageRecord 某个 Key 某天某个功能的用量
+type UsageRecord struct {
+	Day     string `json:"day"`
+	UserKey string `json:"user_key,omitempty"`
+	Feature string `json:"feature"`
+	Count   int64  `json:"count"`
+}
+
+// UsageReport /api/usage 返回给 Key 持有者的用量报告
+type UsageReport struct {
+	Plan    string           `json:"plan"`
+	Today   map[string]int64 `json:"today"`
+	Quotas  map[string]int64 `json:"quotas"`
+	History []UsageRecord    `json:"history"`
+}
+
+// SecurityEvent 一条安全事件，如认证锁定、进入严格模式
+type SecurityEvent struct {
+	Time   string `json:"time"`
+	Type   string `json:"type"`
+	IP     string `json:"ip,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// ShadowLogEntry 影子封禁 Key 的一次请求记录
+type ShadowLogEntry struct {
+	Time      string `json:"time"`
+	IP        string `json:"ip"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// WatermarkMatch 水印识别结果：Key、匹配到的标记比特数与证据
+type WatermarkMatch struct {
+	Key      string   `json:"key"`
+	Bits     int      `json:"bits"`
+	Evidence []string `json:"evidence"`
+}
+
+// ReportUser 统计报告头部的用户信息，结构与快爆用户信息接口的返回一致
+type ReportUser struct {
+	Result struct {
+		Data struct {
+			BaseInfo struct {
+				Avatar          string
+				NickName        string
+				UserAchievement struct {
+					ShowCollect struct {
+						Icon string
+					}
+				}
+			}
+		}
+	}
+}
+
+// CornFarmReport 玉米农场统计（farm 模板的 .Ecd）
+type CornFarmReport struct {
+	PopcornTotal  int64
+	TaskNum       int
+	CsdGained     int
+	CornGained    int
+	PopcornGained int
+	GeneratedAt   string
+}
+
+// RoundReward 转盘额外获得的奖品
+type RoundReward struct {
+	Name   string
+	Source string
+}
+
+// RoundReport 转盘统计（round 模板的 .Ed）
+type RoundReport struct {
+	PopcornTotal    int64
+	RoundCount      int
+	RealRoundCount  int
+	PopcornGained   int
+	DrawCount       int
+	AveragePerDraw  float64
+	DurationMinutes int
+	Rewards         []RoundReward
+	GeneratedAt     string
+}
+
+// MiniGameReport 小游戏统计（game 模板的 .Egd）
+type MiniGameReport struct {
+	PopcornTotal  int64
+	GameNum       int
+	JklGained     int
+	CsdGained     int
+	PopcornGained int
+	GeneratedAt   string
+}
+
+// LotteryPrize 获得的奖券
+type LotteryPrize struct {
+	Pic  string
+	Name string
+	Num  int
+}
+
+// LotteryReport 奖券统计（lottery 模板的 .Eld）
+type LotteryReport struct {
+	LD          []LotteryPrize
+	GeneratedAt string
+}
+
+// ReportData 统计报告模板的数据，每个模板只使用 User 与自己的那一节
+type ReportData struct {
+	User ReportUser
+	Ecd  *CornFarmReport `json:",omitempty"`
+	Ed   *RoundReport    `json:",omitempty"`
+	Egd  *MiniGameReport `json:",omitempty"`
+	Eld  *LotteryReport  `json:",omitempty"`
+}
+
+// ReportRequest /api/reports 的请求体：模板名与统计数据
+type ReportRequest struct {
+	Template string     `json:"template"`
+	Data     ReportData `json:"data"`
+}
+
+// StoredReport 服务器渲染并保存的报告
+type StoredReport struct {
+	ID              string    `json:"id"`
+	UserKey         string    `json:"-"`
+	Template        string    `json:"template"`
+	TemplateVersion string    `json:"template_version"`
+	HTML            string    `json:"html"`
+	URL             string    `json:"url"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// RunReward 一次运行获得的奖品
+type RunReward struct {
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"`
+	Num    int    `json:"num"`
+}
+
+// RunRecord 一次运行（农场、转盘、小游戏或抽奖）的统计，由客户端提交的 ReportData 提取
+type RunRecord struct {
+	ID              int64       `json:"id"`
+	RunID           string      `json:"run_id,omitempty"`
+	Kind            string      `json:"kind"`
+	PopcornTotal    int64       `json:"popcorn_total"`
+	PopcornGained   int         `json:"popcorn_gained"`
+	Tasks           int         `json:"tasks"`
+	Rounds          int         `json:"rounds"`
+	Draws           int         `json:"draws"`
+	Games           int         `json:"games"`
+	DurationMinutes int         `json:"duration_minutes"`
+	Rewards         []RunReward `json:"rewards"`
+	RanAt           time.Time   `json:"ran_at"`
+}
+
+// RunTrendBucket 趋势中的一天或一周
+type RunTrendBucket struct {
+	Start          string  `json:"start"`
+	Runs           int64   `json:"runs"`
+	PopcornGained  int64   `json:"popcorn_gained"`
+	PopcornPerRun  float64 `json:"popcorn_per_run"`
+	Draws          int64   `json:"draws"`
+	AveragePerDraw float64 `json:"average_per_draw"`
+	Tasks          int64   `json:"tasks"`
+	Games          int64   `json:"games"`
+	Rewards        int64   `json:"rewards"`
+}
+
+// RewardFrequency 某个奖品在统计区间内出现的次数与频率
+type RewardFrequency struct {
+	Name      string  `json:"name"`
+	Source    string  `json:"source,omitempty"`
+	Count     int64   `json:"count"`
+	Runs      int64   `json:"runs"`
+	Frequency float64 `json:"frequency"` // 获得该奖品的运行数 / 总运行数
+}
+
+// RunTrends /api/runs/trends 的返回值
+type RunTrends struct {
+	Kind    string            `json:"kind"`
+	Period  string            `json:"period"`
+	From    string            `json:"from"`
+	To      string            `json:"to"`
+	Buckets []RunTrendBucket  `json:"buckets"`
+	Rewards []RewardFrequency `json:"rewards"`
+}
+
+// ShopProduct shop_products 表中的一个商品
+// ValidFrom、ValidUntil 为空表示不限制；只有启用且处于有效期内的商品会下发给客户端
+type ShopProduct struct {
+	ID         int64      `json:"id"`
+	ProductID  string     `json:"product_id"`
+	Name       string     `json:"name"`
+	Link       string     `json:"link"`
+	Category   string     `json:"category"`
+	Enabled    bool       `json:"enabled"`
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	Source     string     `json:"source"` // seed / admin / discovered
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// MenuNode 下发给客户端的菜单项，children 为展开后的子菜单
+type MenuNode struct {
+	ID       string     `json:"id"`
+	Label    string     `json:"label"`
+	Children []MenuNode `json:"children,omitempty"`
+}
+
+// ShopFeedChange 商店数据（products.js、classify_24.js）中一项的变化，见 shopfeed.go
+type ShopFeedChange struct {
+	ID         int64           `json:"id"`
+	Feed       string          `json:"feed"`    // products / rounds
+	ItemID     string          `json:"item_id"` // 商品 ID
+	Change     string          `json:"change"`  // added / removed / changed
+	Name       string          `json:"name"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	DetectedAt time.Time       `json:"detected_at"`
+}
+
+// ShopFeedChangesPage GET /api/shop/changes 的响应
+type ShopFeedChangesPage struct {
+	Changes []ShopFeedChange `json:"changes"`
+	HasMore bool             `json:"has_more"`
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- 权限注册表 ---
+//
+// 长期 Key 以 Hash 形式存储在 Redis 中，值为 "true" 的 useXxx 字段即为该 Key 拥有的权限；
+// roles 字段（逗号分隔）可以一次授予一组权限。
+// 每个请求只读取一次 Key 记录并存入 context，路由需要（require）或仅读取（observe）哪些权限
+// 在 routePermissionRegistry 中声明，新增功能只需登记权限与路由，无需再写中间件。
+
+// permission Key Hash 中的一个权限字段
+type permission string
+
+const (
+	permTaie        permission = "useTaie"
+	permShop        permission = "useShop"
+	permLight       permission = "useLight"
+	permActivities  permission = "useActivities"
+	permCyber       permission = "useCyber"
+	permWoo         permission = "useWoo"
+	permWooPro      permission = "useWooPro"
+	permWhitelisted permission = "whitelisted"
+)
+
+// allPermissions 全部已知权限，管理接口据此校验输入
+var allPermissions = []permission{permTaie, permShop, permLight, permActivities, permCyber, permWoo, permWooPro, permWhitelisted}
+
+const (
+	// keyRecordContextKey Key 记录在 gin.Context 中的键
+	keyRecordContextKey = "keyRecord"
+	// defaultPlan Key 未设置 plan 字段时所属的套餐
+	defaultPlan = "default"
+
+	// keyStatusBanned 封禁：所有请求返回 403
+	keyStatusBanned = "banned"
+	// keyStatusShadowBanned 影子封禁：照常应答但返回诱饵数据，见 shadow.go
+	keyStatusShadowBanned = "shadowbanned"
+
+	// keyFlagFieldPrefix Key 上覆盖功能开关的字段前缀，值为 "true" 或 "false"，见 menus.go
+	keyFlagFieldPrefix = "flag:"
+)
+
+// roleBundles 角色到权限的映射，Key 的 roles 字段中列出的角色会授予其全部权限
+var roleBundles = map[string][]permission{
+	"taie":     {permTaie, permLight},
+	"shop-pro": {permShop, permWoo, permWooPro},
+	"builder":  {permCyber},
+}
+
+// routePermissions 一个路由需要的权限与仅读取的权限
+// require 中任一权限缺失即返回 403；observe 中的权限以 bool 形式存入 context，供处理函数调整返回内容
+type routePermissions struct {
+	require []permission
+	observe []permission
+	// meter 非空时每次请求计入该功能的每日用量，超出套餐配额返回 429
+	meter string
+}
+
+// routePermissionRegistry 以 c.FullPath() 为键的路由权限声明
+// 挂载了 permissionMiddleware 的路由必须在这里登记，未登记的路由一律拒绝
+var routePermissionRegistry = map[string]routePermissions{
+	// 网关各目标所需的权限在网关目标注册表中声明，见 gateway.go
+	"/api/v1/gateway": {},
+	"/api/v1/lucy":    {},
+	"/api/v1/david":   {require: []permission{permShop}},
+
+	"/api/v1/5a3919568264927d643a934a51a439e6": {},
+	"/api/v1/b474528334283249d218771959415853": {},
+
+	"/api/activities":         {require: []permission{permActivities}},
+	"/api/activities/add":     {require: []permission{permActivities}},
+	"/api/activities/getall":  {require: []permission{permActivities}},
+	"/api/activities/search":  {require: []permission{permActivities}},
+	"/api/activities/getself": {require: []permission{permActivities}},
+	"/api/activities/export":  {require: []permission{permActivities}, meter: featureExport},
+	"/api/usage":              {},
+	"/api/reports":            {meter: featureReport},
+	"/api/runs":               {},
+	"/api/runs/trends":        {},
+	"/api/shop/changes":       {require: []permission{permShop}},
+
+	"/apk/load_cache":                    {require: []permission{permCyber}},
+	"/apk/submit_cache":                  {require: []permission{permCyber}},
+	"/apk/submit":                        {require: []permission{permCyber}, meter: featureApkBuild},
+	"/apk/download":                      {require: []permission{permCyber}},
+	"/apk/operations":                    {require: []permission{permCyber}},
+	"/apk/operations/:operation_id/apps": {require: []permission{permCyber}},
+	"/woo/box_search":                    {require: []permission{permWoo}, meter: featureBoxSearch},
+	"/woo/stock/:lottery_id":             {require: []permission{permWoo, permWooPro}},
+	"/safe/log":                          {},
+}
+
+// keyRecord 一个长期 Key 在 Redis 中的完整记录
+type keyRecord struct {
+	Key    string
+	Fields map[string]string
+	perms  map[permission]bool
+}
+
+// newKeyRecord 根据 Hash 字段与 roles 计算 Key 拥有的权限
+func newKeyRecord(key string, fields map[string]string) *keyRecord {
+	record := &keyRecord{Key: key, Fields: fields, perms: make(map[permission]bool)}
+	for field, value := range fields {
+		if value == "true" {
+			record.perms[permission(field)] = true
+		}
+	}
+	for _, role := range record.Roles() {
+		bundle, ok := roleBundles[role]
+		if !ok {
+			log.Printf("Key '%s' 配置了未知角色 '%s'，已忽略", key, role)
+			continue
+		}
+		for _, p := range bundle {
+			record.perms[p] = true
+		}
+	}
+	return record
+}
+
+// Exists Key 是否存在
+func (r *keyRecord) Exists() bool {
+	return len(r.Fields) > 0
+}
+
+// Banned Key 是否已被封禁
+func (r *keyRecord) Banned() bool {
+	return r.Fields["status"] == keyStatusBanned
+}
+
+// ShadowBanned Key 是否处于影子封禁状态
+func (r *keyRecord) ShadowBanned() bool {
+	return r.Fields["status"] == keyStatusShadowBanned
+}
+
+// Roles Key 的角色列表
+func (r *keyRecord) Roles() []string {
+	var roles []string
+	for _, role := range strings.Split(r.Fields["roles"], ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Plan Key 所属的套餐，决定限流等配额
+func (r *keyRecord) Plan() string {
+	if plan := r.Fields["plan"]; plan != "" {
+		return plan
+	}
+	return defaultPlan
+}
+
+// FlagOverride Key 对功能开关的单独设置；没有设置时 ok 为 false
+func (r *keyRecord) FlagOverride(name string) (enabled, ok bool) {
+	if r == nil {
+		return false, false
+	}
+	v, ok := r.Fields[keyFlagFieldPrefix+name]
+	return v == "true", ok
+}
+
+// Has Key 是否拥有某个权限（直接授予或通过角色授予）
+func (r *keyRecord) Has(p permission) bool {
+	return r.perms[p]
+}
+
+// HasAll Key 是否拥有全部权限；记录为空时只有 perms 为空才返回 true
+func (r *keyRecord) HasAll(perms []permission) bool {
+	for _, p := range perms {
+		if r == nil || !r.Has(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Allows /authenticate 的 X-Def 校验：字段存在（不论取值）或通过角色授予即可
+func (r *keyRecord) Allows(def string) bool {
+	if _, ok := r.Fields[def]; ok {
+		return true
+	}
+	return r.Has(permission(def))
+}
+
+// currentKeyRecord 返回 permissionMiddleware 载入的 Key 记录
+func currentKeyRecord(c *gin.Context) *keyRecord {
+	if v, ok := c.Get(keyRecordContextKey); ok {
+		return v.(*keyRecord)
+	}
+	return nil
+}
+
+// hasPermission 读取路由在注册表中 observe 或 require 的权限
+func hasPermission(c *gin.Context, p permission) bool {
+	return c.GetBool(string(p))
+}
+
+// permissionMiddleware 每个请求读取一次 Key 记录，并按注册表校验路由所需权限
+func permissionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		longTermKey := c.GetString("longTermKey")
+		if longTermKey == "" {
+			respondErrorCode(c, http.StatusInternalServerError, msgAuthKeyMissing)
+			c.Abort()
+			return
+		}
+
+		route, ok := routePermissionRegistry[c.FullPath()]
+		if !ok {
+			log.Printf("路由 %s 未在权限注册表中登记，拒绝访问", c.FullPath())
+			respondErrorCode(c, http.StatusForbidden, msgPermissionDenied)
+			c.Abort()
+			return
+		}
+
+		record, err := loadKeyRecord(longTermKey)
+		if err != nil {
+			log.Printf("Failed to retrieve key data from Redis for %s: %v", longTermKey, err)
+			respondErrorCode(c, http.StatusInternalServerError, msgPermissionCheckFailed)
+			c.Abort()
+			return
+		}
+		if !record.Exists() {
+			respondErrorCode(c, http.StatusUnauthorized, msgInvalidKey)
+			c.Abort()
+			return
+		}
+		if record.Banned() {
+			respondErrorCode(c, http.StatusForbidden, msgKeyBanned)
+			c.Abort()
+			return
+		}
+
+		for _, p := range route.require {
+			if !record.Has(p) {
+				log.Printf("Access denied for key %s: %s permission not granted", longTermKey, p)
+				respondErrorCode(c, http.StatusForbidden, msgPermissionDenied)
+				c.Abort()
+				return
+			}
+			c.Set(string(p), true)
+		}
+		for _, p := range route.observe {
+			c.Set(string(p), record.Has(p))
+		}
+
+		c.Set(keyRecordContextKey, record)
+		if record.ShadowBanned() {
+			c.Set(shadowBannedContextKey, true)
+			// 每个请求只记录一条，处理函数通过 setShadowDetail 补充的说明一并写入
+			entry := newShadowLogEntry(c, "")
+			defer func() {
+				entry.Detail = c.GetString(shadowDetailContextKey)
+				saveShadowLogEntry(longTermKey, entry)
+			}()
+		}
+		if route.meter != "" && !consumeQuota(c, route.meter) {
+			return
+		}
+		c.Next()
+		refundFailedQuota(c)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestKeyRecordRoles(t *testing.T) {
+	record := newKeyRecord("k", map[string]string{
+		"useTaie": "false",
+		"useShop": "true",
+		"roles":   "shop-pro, unknown",
+	})
+
+	for _, p := range []permission{permShop, permWoo, permWooPro} {
+		if !record.Has(p) {
+			t.Errorf("expected %s to be granted", p)
+		}
+	}
+	if record.Has(permTaie) || record.Has(permCyber) {
+		t.Error("useTaie=false and useCyber should not be granted")
+	}
+
+	// X-Def 只要求字段存在，兼容历史上值为 false 的 Key
+	if !record.Allows("useTaie") || !record.Allows("useWooPro") || record.Allows("useCyber") {
+		t.Error("Allows does not match field presence or role grants")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// --- 限流 ---
+//
+// 基于 Redis 的令牌桶，按 (限流器, 长期 Key) 计数，多个服务器实例共享同一个桶。
+// 限流器的速率按套餐（Key 的 plan 字段）在配置的 limits.rate_limits 中设置，路由级限流在 limits.routes 中登记，
+// 网关中开销较大的目标（如 point、handshake）由处理函数调用 enforceRateLimit；两者都随配置重新加载生效。
+
+// 网关处理函数使用的限流器，必须在 limits.rate_limits 中配置
+const (
+	rateLimiterPoint     = "lucy:point"
+	rateLimiterHandshake = "david:handshake"
+)
+
+// rateLimit 令牌桶参数：每隔 Interval 补充一个令牌，桶容量 Burst
+type rateLimit struct {
+	Interval duration `yaml:"interval" json:"interval"`
+	Burst    int      `yaml:"burst" json:"burst"`
+}
+
+// rate 每秒补充的令牌数
+func (l rateLimit) rate() float64 {
+	return float64(time.Second) / float64(l.Interval)
+}
+
+// tokenBucketScript 原子地补充并消耗令牌，使用 Redis 服务器时间避免各实例时钟不一致
+// 返回 {是否允许, 剩余令牌数, 需要等待的毫秒数}
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + (now - ts) * rate / 1000)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), retry}
+`)
+
+// rateLimitFor 返回限流器在指定套餐下的参数
+func rateLimitFor(name, plan string) (rateLimit, bool) {
+	plans, ok := currentConfig().Limits.RateLimits[name]
+	if !ok {
+		return rateLimit{}, false
+	}
+	if limit, ok := plans[plan]; ok {
+		return limit, true
+	}
+	limit, ok := plans[defaultPlan]
+	return limit, ok
+}
+
+// enforceRateLimit 消耗一个令牌；超出限制时写出 429 并返回 false
+// Redis 出错时放行，避免限流故障导致整个服务不可用
+func enforceRateLimit(c *gin.Context, name string) bool {
+	longTermKey := c.GetString("longTermKey")
+	plan := defaultPlan
+	if record := currentKeyRecord(c); record != nil {
+		plan = record.Plan()
+	} else if record, err := loadKeyRecord(longTermKey); err == nil {
+		plan = record.Plan()
+	}
+
+	limit, ok := rateLimitFor(name, plan)
+	if !ok {
+		log.Printf("警告: 限流器 %s 未配置，已放行", name)
+		return true
+	}
+
+	redisKey := fmt.Sprintf("ratelimit:%s:%s", name, longTermKey)
+	res, err := tokenBucketScript.Run(ctx, swordRdb, []string{redisKey}, limit.rate(), limit.Burst).Int64Slice()
+	if err != nil || len(res) != 3 {
+		log.Printf("限流器 %s 执行失败，已放行: %v", name, err)
+		return true
+	}
+	allowed, remaining, retryMs := res[0] == 1, res[1], res[2]
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+	c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	if allowed {
+		return true
+	}
+
+	retryAfter := (time.Duration(retryMs)*time.Millisecond + time.Second - 1) / time.Second
+	c.Header("Retry-After", strconv.FormatInt(int64(retryAfter), 10))
+	log.Printf("Key '%s' 触发限流 %s (套餐 %s)", longTermKey, name, plan)
+	respondErrorCode(c, http.StatusTooManyRequests, msgRateLimited)
+	c.Abort()
+	return false
+}
+
+// rateLimitMiddleware 对 limits.routes 中登记的路由限流
+// 应位于 permissionMiddleware 之前，使被限流的请求不计入每日用量
+func rateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if name := currentConfig().Limits.Routes[c.FullPath()]; name != "" && !enforceRateLimit(c, name) {
+			return
+		}
+		c.Next()
+	}
+}
@@ -26,13 +26,13 @@ const (
 func initRedis() {
 	swordRdb = redis.NewClient(&redis.Options{
 		Addr:     redisAddress,
-		Password: redisPassword,
+		Password: redisPassword.Reveal(),
 		DB:       swordRedisDB,
 	})
 
 	apkRdb = redis.NewClient(&redis.Options{
 		Addr:     redisAddress,
-		Password: redisPassword,
+		Password: redisPassword.Reveal(),
 		DB:       apkRedisDB,
 	})
 
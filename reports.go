@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// --- 服务端渲染的统计报告 ---
+//
+// 客户端通过 POST /api/reports 提交统计数据（ReportData），服务器用 templates.go 中的同一套模板渲染，
+// 保存到 reports 表并返回随机的报告 ID；任何人都可以通过 GET /reports/<ID> 查看渲染结果，ID 即分享凭证。
+// 模板更新后新的报告立即使用新模板，客户端无需更新。报告保存 reportRetention 后由定时任务清理。
+
+const (
+	reportIDBytes = 16
+	// maxReportBody 请求体上限，统计数据通常只有几 KB
+	maxReportBody = 64 << 10
+
+	reportRetention = 90 * 24 * time.Hour
+)
+
+// reportPagePrefix 与 reportPageSuffix 包裹报告片段，组成可以直接在浏览器中打开的页面
+const (
+	reportPagePrefix = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>数据统计</title>
+</head>
+<body style="margin: 0; background-color: #f8fafc;">
+`
+	reportPageSuffix = `
+</body>
+</html>
+`
+)
+
+var errUnknownTemplate = errors.New("unknown template")
+
+// newReportID 生成随机报告 ID
+func newReportID() (string, error) {
+	b := make([]byte, reportIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// reportURL 报告的分享路径
+func reportURL(id string) string {
+	return "/reports/" + id
+}
+
+// renderReport 用当前模板渲染统计数据；数据缺少模板需要的那一节时返回错误
+func renderReport(name string, data ReportData) (StoredReport, error) {
+	tpl, ok := lookupTemplate(name)
+	if !ok {
+		return StoredReport{}, errUnknownTemplate
+	}
+	html, err := tpl.Execute(data)
+	if err != nil {
+		return StoredReport{}, err
+	}
+	return StoredReport{Template: name, TemplateVersion: tpl.Version, HTML: html, CreatedAt: time.Now()}, nil
+}
+
+// createReportHandler 渲染并保存报告，返回报告 ID、分享路径与渲染结果
+// 影子封禁的 Key 照常得到渲染结果，但报告不会保存，分享链接打开后为 404
+func createReportHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReportBody)
+	var req ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidRequestBody, err)
+		return
+	}
+
+	report, err := renderReport(req.Template, req.Data)
+	if errors.Is(err, errUnknownTemplate) {
+		respondErrorCode(c, http.StatusNotFound, msgUnknownTemplate)
+		return
+	}
+	if err != nil {
+		log.Printf("渲染报告 %s 失败: %v", req.Template, err)
+		respondErrorCode(c, http.StatusBadRequest, msgReportDataMismatch)
+		return
+	}
+
+	if report.ID, err = newReportID(); err != nil {
+		log.Printf("生成报告 ID 失败: %v", err)
+		respondErrorCode(c, http.StatusInternalServerError, msgReportSaveFailed)
+		return
+	}
+	report.UserKey = c.GetString("longTermKey")
+	report.URL = reportURL(report.ID)
+
+	// 影子封禁 Key 的报告不保存
+	if isShadowBanned(c) {
+		respondEncrypted(c, http.StatusOK, report)
+		return
+	}
+
+	data, err := json.Marshal(req.Data)
+	if err != nil {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidRequestBody, err)
+		return
+	}
+	if err := insertReport(report, data); err != nil {
+		log.Printf("保存 Key '%s' 的报告失败: %v", report.UserKey, err)
+		respondErrorCode(c, http.StatusInternalServerError, msgReportSaveFailed)
+		return
+	}
+	respondEncrypted(c, http.StatusOK, report)
+}
+
+// viewReportHandler 公开的报告页面，不需要认证
+func viewReportHandler(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := hex.DecodeString(id); err != nil || len(id) != reportIDBytes*2 {
+		c.String(http.StatusNotFound, "report not found")
+		return
+	}
+
+	report, err := getReport(id)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && time.Since(report.CreatedAt) > reportRetention) {
+		c.String(http.StatusNotFound, "report not found")
+		return
+	}
+	if err != nil {
+		log.Printf("读取报告 %s 失败: %v", id, err)
+		c.String(http.StatusInternalServerError, "failed to load report")
+		return
+	}
+
+	// 报告只包含内联样式与外部图片，禁止脚本
+	c.Header("Content-Security-Policy", "default-src 'none'; img-src https: data:; style-src 'unsafe-inline'")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(reportPagePrefix+report.HTML+reportPageSuffix))
+}
+
+// purgeExpiredReports 删除超过保留期限的报告，由定时任务调用
+func purgeExpiredReports() {
+	n, err := deleteReportsBefore(time.Now().Add(-reportRetention))
+	if err != nil {
+		log.Printf("清理过期报告失败: %v", err)
+		return
+	}
+	log.Printf("已清理 %d 份过期报告", n)
+}
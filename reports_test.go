@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRenderReport(t *testing.T) {
+	data := reportTemplateSamples["round"]()
+	data.User.Result.Data.BaseInfo.NickName = `<script>alert(1)</script>`
+	data.User.Result.Data.BaseInfo.Avatar = "javascript:alert(1)"
+
+	report, err := renderReport("round", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl, _ := lookupTemplate("round")
+	if report.TemplateVersion != tpl.Version {
+		t.Errorf("template version = %q, want %q", report.TemplateVersion, tpl.Version)
+	}
+	if strings.Contains(report.HTML, "<script>") || strings.Contains(report.HTML, "javascript:") {
+		t.Error("submitted statistics must be escaped")
+	}
+	if !strings.Contains(report.HTML, "1,234,567") || !strings.Contains(report.HTML, "6.67") {
+		t.Error("report is missing rendered statistics")
+	}
+
+	// 只提交了其它报告的数据
+	if _, err := renderReport("round", reportTemplateSamples["farm"]()); err == nil {
+		t.Error("rendering without the template's section should fail")
+	}
+	if _, err := renderReport("nope", data); !errors.Is(err, errUnknownTemplate) {
+		t.Errorf("unknown template: err = %v", err)
+	}
+}
+
+func TestViewReportRejectsMalformedIDs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/reports/:id", viewReportHandler)
+
+	for _, id := range []string{"abc", strings.Repeat("z", reportIDBytes*2)} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/"+id, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET /reports/%s = %d, want 404", id, w.Code)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// encryptedRouteKey 由 encryptionMiddleware 写入 context，标记当前路由的响应（包括错误）需要加密
+const encryptedRouteKey = "encryptResponse"
+
+// respondEncrypted 序列化、按协商压缩并加密 data，以给定状态码写出唯一一次响应
+// 加密过程中出错时退化为明文错误响应，因为此时已无法保证客户端能解密
+func respondEncrypted(c *gin.Context, status int, data any) {
+	if c.Writer.Written() {
+		log.Printf("警告: %s 的响应已写出，忽略重复的加密响应", c.Request.URL.Path)
+		return
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("序列化响应失败: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Status: http.StatusInternalServerError, Error: "Failed to serialize data"})
+		return
+	}
+	log.Printf("本次响应: %s %d %d 字节", c.Request.URL.Path, status, len(jsonData))
+
+	// 压缩在加密之前进行，由客户端通过 X-Payload-Encoding 头协商
+	plaintext, encoding, err := compressPayload(jsonData, negotiatePayloadEncoding(c.GetHeader("X-Payload-Encoding")))
+	if err != nil {
+		log.Printf("压缩响应失败: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Status: http.StatusInternalServerError, Error: "Failed to compress data"})
+		return
+	}
+
+	encryptedPayload, err := encrypt(plaintext, []byte(c.GetString("longTermKey")))
+	if err != nil {
+		log.Printf("加密响应失败: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Status: http.StatusInternalServerError, Error: "Encryption failed"})
+		return
+	}
+
+	c.AbortWithStatusJSON(status, EncryptedResponse{Payload: encryptedPayload, Encoding: encoding})
+}
+
+// writeErrorResponse 以统一的 ErrorResponse 结构写出错误体，加密路由上加密返回
+// 客户端可以用同一套逻辑解析所有响应；处理函数应使用 respondErrorCode
+func writeErrorResponse(c *gin.Context, body ErrorResponse) {
+	if c.GetBool(encryptedRouteKey) && c.GetString("longTermKey") != "" {
+		respondEncrypted(c, body.Status, body)
+		return
+	}
+
+	if c.Writer.Written() {
+		log.Printf("警告: %s 的响应已写出，忽略错误响应: %s", c.Request.URL.Path, body.Error)
+		return
+	}
+	c.AbortWithStatusJSON(body.Status, body)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- 运行历史与趋势 ---
+//
+// 客户端每次运行农场、转盘、小游戏或商店抽奖后，把生成报告用的统计数据（ReportData 中对应的一节）
+// 通过 POST /api/runs 提交，服务器提取爆米花、任务数、抽取次数、奖品等指标写入 Postgres 的 runs 表。
+// GET /api/runs 分页返回历史，GET /api/runs/trends 按天或周汇总（每次运行的爆米花、平均每抽爆米花、奖品频率），
+// 均为加密接口，只返回当前 Key 自己的数据。客户端可以带上 run_id 以便重试时去重。
+
+const (
+	runKindFarm     = "farm"
+	runKindRound    = "round"
+	runKindGame     = "game"
+	runKindLottery  = "lottery"
+	runPeriodDay    = "day"
+	runPeriodWeek   = "week"
+	maxRunIDLength  = 64
+	maxRunsPageSize = 100
+	// maxTrendRange 趋势查询的最长区间
+	maxTrendRange = 366 * 24 * time.Hour
+	// maxRunClockSkew 运行时间最多可以比服务器时间晚多少
+	maxRunClockSkew = 5 * time.Minute
+	// topRewards 趋势中返回的奖品种类上限
+	topRewards = 20
+)
+
+var runKinds = []string{runKindFarm, runKindRound, runKindGame, runKindLottery}
+
+// runRequest /api/runs 的请求体；kind 与报告模板名一致，data 只需填写对应的一节
+type runRequest struct {
+	RunID string     `json:"run_id"`
+	Kind  string     `json:"kind"`
+	RanAt *time.Time `json:"ran_at"`
+	Data  ReportData `json:"data"`
+}
+
+var errRunSectionMissing = errors.New("statistics for this kind are missing")
+
+// runFromReport 从报告数据中提取一次运行的指标
+func runFromReport(kind string, data ReportData) (RunRecord, error) {
+	run := RunRecord{Kind: kind, Rewards: []RunReward{}}
+	switch kind {
+	case runKindFarm:
+		if data.Ecd == nil {
+			return run, errRunSectionMissing
+		}
+		run.PopcornTotal, run.PopcornGained, run.Tasks = data.Ecd.PopcornTotal, data.Ecd.PopcornGained, data.Ecd.TaskNum
+	case runKindRound:
+		if data.Ed == nil {
+			return run, errRunSectionMissing
+		}
+		ed := data.Ed
+		run.PopcornTotal, run.PopcornGained = ed.PopcornTotal, ed.PopcornGained
+		run.Rounds, run.Draws, run.DurationMinutes = ed.RealRoundCount, ed.DrawCount, ed.DurationMinutes
+		for _, r := range ed.Rewards {
+			run.Rewards = append(run.Rewards, RunReward{Name: r.Name, Source: r.Source, Num: 1})
+		}
+	case runKindGame:
+		if data.Egd == nil {
+			return run, errRunSectionMissing
+		}
+		run.PopcornTotal, run.PopcornGained, run.Games = data.Egd.PopcornTotal, data.Egd.PopcornGained, data.Egd.GameNum
+	case runKindLottery:
+		if data.Eld == nil {
+			return run, errRunSectionMissing
+		}
+		for _, p := range data.Eld.LD {
+			run.Rewards = append(run.Rewards, RunReward{Name: p.Name, Num: p.Num})
+		}
+	default:
+		return run, errors.New("unknown kind")
+	}
+
+	if run.PopcornTotal < 0 || run.PopcornGained < 0 || run.Tasks < 0 || run.Rounds < 0 ||
+		run.Draws < 0 || run.Games < 0 || run.DurationMinutes < 0 {
+		return run, errors.New("negative statistics")
+	}
+	for _, r := range run.Rewards {
+		if r.Name == "" || r.Num < 0 {
+			return run, errors.New("invalid reward")
+		}
+	}
+	return run, nil
+}
+
+// submitRunHandler 记录一次运行；同一 Key 重复提交相同的 run_id 只记录一次
+func submitRunHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReportBody)
+	var req runRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidRequestBody, err)
+		return
+	}
+	if len(req.RunID) > maxRunIDLength {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidParameter, "run_id")
+		return
+	}
+
+	run, err := runFromReport(req.Kind, req.Data)
+	if err != nil {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidRunStats, err)
+		return
+	}
+	run.RunID = req.RunID
+	run.RanAt = time.Now()
+	if req.RanAt != nil && !req.RanAt.IsZero() {
+		if req.RanAt.After(run.RanAt.Add(maxRunClockSkew)) {
+			respondErrorCode(c, http.StatusBadRequest, msgRanAtInFuture)
+			return
+		}
+		run.RanAt = *req.RanAt
+	}
+
+	// 影子封禁 Key 的数据不可信，不写入历史
+	if isShadowBanned(c) {
+		respondEncrypted(c, http.StatusOK, run)
+		return
+	}
+
+	longTermKey := c.GetString("longTermKey")
+	if run.ID, err = insertRun(longTermKey, run); err != nil {
+		log.Printf("保存 Key '%s' 的运行记录失败: %v", longTermKey, err)
+		respondErrorCode(c, http.StatusInternalServerError, msgRunSaveFailed)
+		return
+	}
+	respondEncrypted(c, http.StatusOK, run)
+}
+
+// getRunsHandler 分页返回运行历史，按运行时间倒序；参数 kind 可选，limit 默认 20
+func getRunsHandler(c *gin.Context) {
+	kind := c.Query("kind")
+	if kind != "" && !slices.Contains(runKinds, kind) {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidParameter, "kind")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidParameter, "limit")
+		return
+	}
+	limit = min(limit, maxRunsPageSize)
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidParameter, "offset")
+		return
+	}
+
+	runs, err := getRuns(c.GetString("longTermKey"), kind, limit, offset)
+	if err != nil {
+		log.Printf("查询运行记录失败: %v", err)
+		respondErrorCode(c, http.StatusInternalServerError, msgRunsFetchFailed)
+		return
+	}
+	respondEncrypted(c, http.StatusOK, runs)
+}
+
+// getRunTrendsHandler 按天或周汇总某类运行
+//
+//	GET /api/runs/trends?kind=round&period=week&from=2024-01-01&to=2024-03-31
+//
+// period 默认 day；from/to 为 YYYY-MM-DD（北京时间，含两端），默认最近 30 天（按周时最近 12 周）
+func getRunTrendsHandler(c *gin.Context) {
+	kind := c.Query("kind")
+	if !slices.Contains(runKinds, kind) {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidParameter, "kind")
+		return
+	}
+	period := c.DefaultQuery("period", runPeriodDay)
+	defaultDays := 30
+	switch period {
+	case runPeriodDay:
+	case runPeriodWeek:
+		defaultDays = 12 * 7
+	default:
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidParameter, "period")
+		return
+	}
+
+	now := time.Now()
+	from, err := time.ParseInLocation(usageDateLayout, c.DefaultQuery("from", now.AddDate(0, 0, -defaultDays+1).Format(usageDateLayout)), time.Local)
+	if err != nil {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidDate)
+		return
+	}
+	to, err := time.ParseInLocation(usageDateLayout, c.DefaultQuery("to", now.Format(usageDateLayout)), time.Local)
+	if err != nil {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidDate)
+		return
+	}
+	end := to.AddDate(0, 0, 1)
+	if !end.After(from) || end.Sub(from) > maxTrendRange {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidDateRange)
+		return
+	}
+
+	longTermKey := c.GetString("longTermKey")
+	buckets, err := getRunTrendBuckets(longTermKey, kind, period, from, end)
+	if err != nil {
+		log.Printf("查询运行趋势失败: %v", err)
+		respondErrorCode(c, http.StatusInternalServerError, msgTrendsFetchFailed)
+		return
+	}
+	rewards, err := getRewardFrequencies(longTermKey, kind, from, end, topRewards)
+	if err != nil {
+		log.Printf("查询奖品频率失败: %v", err)
+		respondErrorCode(c, http.StatusInternalServerError, msgTrendsFetchFailed)
+		return
+	}
+
+	respondEncrypted(c, http.StatusOK, buildRunTrends(kind, period, from, to, buckets, rewards))
+}
+
+// buildRunTrends 计算各区间的平均值与奖品频率
+func buildRunTrends(kind, period string, from, to time.Time, buckets []RunTrendBucket, rewards []RewardFrequency) RunTrends {
+	var totalRuns int64
+	for i := range buckets {
+		b := &buckets[i]
+		totalRuns += b.Runs
+		if b.Runs > 0 {
+			b.PopcornPerRun = float64(b.PopcornGained) / float64(b.Runs)
+		}
+		if b.Draws > 0 {
+			b.AveragePerDraw = float64(b.PopcornGained) / float64(b.Draws)
+		}
+	}
+	for i := range rewards {
+		if totalRuns > 0 {
+			rewards[i].Frequency = float64(rewards[i].Runs) / float64(totalRuns)
+		}
+	}
+	return RunTrends{
+		Kind:    kind,
+		Period:  period,
+		From:    from.Format(usageDateLayout),
+		To:      to.Format(usageDateLayout),
+		Buckets: buckets,
+		Rewards: rewards,
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunFromReport(t *testing.T) {
+	round, err := runFromReport(runKindRound, reportTemplateSamples["round"]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if round.PopcornGained != 120 || round.Draws != 18 || round.Rounds != 6 || len(round.Rewards) != 1 || round.Rewards[0].Source != "万能转盘" {
+		t.Errorf("round run = %+v", round)
+	}
+
+	lottery, err := runFromReport(runKindLottery, reportTemplateSamples["lottery"]())
+	if err != nil || len(lottery.Rewards) != 1 || lottery.Rewards[0].Num != 3 {
+		t.Errorf("lottery run = %+v, %v", lottery, err)
+	}
+
+	if _, err := runFromReport(runKindFarm, reportTemplateSamples["game"]()); !errors.Is(err, errRunSectionMissing) {
+		t.Errorf("farm run without Ecd: err = %v", err)
+	}
+	negative := reportTemplateSamples["game"]()
+	negative.Egd.PopcornGained = -1
+	if _, err := runFromReport(runKindGame, negative); err == nil {
+		t.Error("negative statistics should be rejected")
+	}
+	if _, err := runFromReport("unknown", negative); err == nil {
+		t.Error("unknown kind should be rejected")
+	}
+}
+
+func TestBuildRunTrends(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	buckets := []RunTrendBucket{
+		{Start: "2024-01-01", Runs: 2, PopcornGained: 300, Draws: 40},
+		{Start: "2024-01-02", Runs: 2, PopcornGained: 0},
+	}
+	rewards := []RewardFrequency{{Name: "5Q币", Count: 1, Runs: 1}}
+
+	trends := buildRunTrends(runKindRound, runPeriodDay, from, from.AddDate(0, 0, 1), buckets, rewards)
+	if b := trends.Buckets[0]; b.PopcornPerRun != 150 || b.AveragePerDraw != 7.5 {
+		t.Errorf("first bucket = %+v", b)
+	}
+	if b := trends.Buckets[1]; b.AveragePerDraw != 0 {
+		t.Errorf("bucket without draws should have no average, got %v", b.AveragePerDraw)
+	}
+	if trends.Rewards[0].Frequency != 0.25 || trends.To != "2024-01-02" {
+		t.Errorf("trends = %+v", trends)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// --- 密钥 ---
+//
+// 密钥与密码使用 secret 类型保存：用 fmt 格式化、写入日志或序列化为 JSON 时只显示 [redacted]，
+// 需要原值的地方显式调用 Reveal（转换为 []byte 同样得到原值）。
+// 所有支持 _FILE 的环境变量（JWT_SECRET_KEY_FILE 等）从文件读取密钥，适用于 Docker / Kubernetes secrets；
+// 与同名变量同时设置视为配置错误。
+// environment 为 production 时，使用内置默认值、常见弱口令或长度不足的密钥会拒绝启动；
+// development 下只在启动日志中警告。
+
+const (
+	envDevelopment = "development"
+	envProduction  = "production"
+
+	redactedValue = "[redacted]"
+
+	// minSecretLength 签名密钥与令牌的最短长度，minPasswordLength 数据库密码的最短长度
+	minSecretLength   = 32
+	minPasswordLength = 12
+)
+
+// weakSecrets 常见的弱口令，比较时不区分大小写
+var weakSecrets = []string{"password", "secret", "changeme", "change-me", "admin", "123456", "postgres", "redis"}
+
+// secret 不会被意外打印的字符串
+type secret string
+
+// Reveal 返回原值
+func (s secret) Reveal() string {
+	return string(s)
+}
+
+func (s secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+func (s secret) GoString() string {
+	return strconv.Quote(s.String())
+}
+
+// MarshalText 同时用于 JSON 与 YAML 序列化
+func (s secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// lookupEnvOverride 读取环境变量 env；allowFile 为 true 时也接受 <env>_FILE 指定的文件
+// 返回实际使用的变量名，文件内容去掉末尾换行
+func lookupEnvOverride(env string, allowFile bool) (name, value string, ok bool, err error) {
+	value, ok = os.LookupEnv(env)
+	if !allowFile {
+		return env, value, ok, nil
+	}
+	fileEnv := env + "_FILE"
+	path, hasFile := os.LookupEnv(fileEnv)
+	if !hasFile {
+		return env, value, ok, nil
+	}
+	if ok {
+		return fileEnv, "", false, fmt.Errorf("%s and %s are both set", env, fileEnv)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fileEnv, "", false, fmt.Errorf("%s: %w", fileEnv, err)
+	}
+	return fileEnv, strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+// insecureSecrets 列出使用默认值、弱口令或长度不足的密钥，只包含字段名，不包含密钥本身
+func (c *appConfig) insecureSecrets() []string {
+	defaults := defaultConfig()
+	var problems []string
+	check := func(field string, v, builtin secret, minLength int, required bool) {
+		switch {
+		case v == "":
+			if required {
+				problems = append(problems, field+": must be set")
+			}
+		case v == builtin || isWeakSecret(v.Reveal()):
+			problems = append(problems, field+": uses a well-known default value")
+		case len(v) < minLength:
+			problems = append(problems, fmt.Sprintf("%s: must be at least %d characters", field, minLength))
+		}
+	}
+	check("auth.jwt_secret_key", c.Auth.JWTSecretKey, defaults.Auth.JWTSecretKey, minSecretLength, true)
+	check("auth.app_integrity_secret", c.Auth.AppIntegritySecret, defaults.Auth.AppIntegritySecret, minSecretLength, true)
+	check("auth.admin_token", c.Auth.AdminToken, "", minSecretLength, false)
+	check("auth.target_alias_secret", c.Auth.TargetAliasSecret, "", minSecretLength, false)
+	// 签名私钥的格式由 initResponseSigning 检查；缺少时临时生成的公钥无法被客户端 pin
+	check("auth.signing_key", c.Auth.SigningKey, "", 0, true)
+	check("postgres.password", c.Postgres.Password, defaults.Postgres.Password, minPasswordLength, true)
+	check("redis.password", c.Redis.Password, "", minPasswordLength, false)
+	return problems
+}
+
+func isWeakSecret(s string) bool {
+	return slices.Contains(weakSecrets, strings.ToLower(strings.TrimSpace(s)))
+}
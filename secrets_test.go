@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretIsRedacted(t *testing.T) {
+	s := secret("hunter2-hunter2")
+	cfg := struct {
+		Password secret `json:"password"`
+	}{s}
+
+	out, _ := json.Marshal(cfg)
+	for _, formatted := range []string{
+		fmt.Sprint(s), fmt.Sprintf("%s %v %q %+v %#v", s, s, s, cfg, cfg), string(out),
+	} {
+		if strings.Contains(formatted, "hunter2") {
+			t.Errorf("secret leaked: %s", formatted)
+		}
+	}
+	if s.Reveal() != "hunter2-hunter2" || string([]byte(s)) != "hunter2-hunter2" {
+		t.Error("Reveal and []byte conversion should return the original value")
+	}
+	if secret("").String() != "" {
+		t.Error("an empty secret should print as empty")
+	}
+}
+
+func TestSecretFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwt")
+	if err := os.WriteFile(path, []byte("from-file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_SECRET_KEY_FILE", path)
+
+	loaded, err := loadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.cfg.Auth.JWTSecretKey.Reveal() != "from-file-secret" {
+		t.Errorf("jwt_secret_key = %q", loaded.cfg.Auth.JWTSecretKey.Reveal())
+	}
+	if strings.Join(loaded.envOverrides, ",") != "JWT_SECRET_KEY_FILE" {
+		t.Errorf("env overrides = %v", loaded.envOverrides)
+	}
+
+	t.Setenv("JWT_SECRET_KEY", "also-set")
+	if _, err := loadConfig(""); err == nil || !strings.Contains(err.Error(), "both set") {
+		t.Errorf("setting both JWT_SECRET_KEY and JWT_SECRET_KEY_FILE should fail, err = %v", err)
+	}
+
+	// t.Setenv 负责在测试结束后恢复，随后取消设置
+	t.Setenv("JWT_SECRET_KEY", "")
+	os.Unsetenv("JWT_SECRET_KEY")
+	t.Setenv("JWT_SECRET_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := loadConfig(""); err == nil || !strings.Contains(err.Error(), "JWT_SECRET_KEY_FILE") {
+		t.Errorf("a missing secret file should fail, err = %v", err)
+	}
+}
+
+func TestProductionRefusesInsecureSecrets(t *testing.T) {
+	t.Setenv("APP_ENV", envProduction)
+	t.Setenv("APP_INTEGRITY_SECRET", "short")
+	t.Setenv("ADMIN_TOKEN", "changeme")
+
+	_, err := loadConfig("")
+	if err == nil {
+		t.Fatal("production mode accepted the default secrets")
+	}
+	for _, want := range []string{
+		"auth.jwt_secret_key: uses a well-known default value",
+		"auth.app_integrity_secret: must be at least 32 characters",
+		"auth.admin_token: uses a well-known default value",
+		"postgres.password: uses a well-known default value",
+		"auth.signing_key: must be set",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not contain %q:\n%v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "short") || strings.Contains(err.Error(), defaultConfig().Auth.JWTSecretKey.Reveal()) {
+		t.Errorf("error leaks a secret:\n%v", err)
+	}
+
+	t.Setenv("JWT_SECRET_KEY", strings.Repeat("j", minSecretLength))
+	t.Setenv("APP_INTEGRITY_SECRET", strings.Repeat("a", minSecretLength))
+	t.Setenv("ADMIN_TOKEN", strings.Repeat("t", minSecretLength))
+	t.Setenv("POSTGRES_PASSWORD", "a-long-db-password")
+	t.Setenv("SERVER_SIGNING_KEY", base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize)))
+	if _, err := loadConfig(""); err != nil {
+		t.Errorf("strong secrets were rejected: %v", err)
+	}
+}
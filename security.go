@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// --- 安全事件 ---
+// 锁定、严格模式等安全相关事件写入日志（带 [SECURITY] 前缀便于检索），
+// 同时保存最近 securityEventsMax 条到 Redis，供管理接口查看
+
+const (
+	securityEventsKey = "security:events"
+	securityEventsMax = 1000
+)
+
+// logSecurityEvent 记录一条安全事件，Redis 写入失败只记录日志
+func logSecurityEvent(eventType, ip, key, detail string) {
+	event := SecurityEvent{
+		Time:   time.Now().Format(time.RFC3339),
+		Type:   eventType,
+		IP:     ip,
+		Key:    key,
+		Detail: detail,
+	}
+	data, _ := json.Marshal(event)
+	log.Printf("[SECURITY] %s", data)
+
+	pipe := swordRdb.Pipeline()
+	pipe.LPush(ctx, securityEventsKey, data)
+	pipe.LTrim(ctx, securityEventsKey, 0, securityEventsMax-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("保存安全事件失败: %v", err)
+	}
+}
+
+// recentSecurityEvents 返回最近的 n 条安全事件，最新的在前
+func recentSecurityEvents(n int64) ([]SecurityEvent, error) {
+	raw, err := swordRdb.LRange(ctx, securityEventsKey, 0, n-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]SecurityEvent, 0, len(raw))
+	for _, item := range raw {
+		var event SecurityEvent
+		if err := json.Unmarshal([]byte(item), &event); err == nil {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
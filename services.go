@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
@@ -26,6 +28,9 @@ const (
 	saltSize         = 8
 	pbkdf2Iterations = 4096
 	ipCacheTTL       = 120 * time.Hour // IP 地址缓存的有效期
+
+	compressionThreshold = 1024   // 明文小于该字节数时不压缩
+	payloadEncodingGzip  = "gzip" // 目前支持的唯一压缩算法
 )
 
 // getGeoInfoForIP 调用外部服务获取 IP 的地理位置，并使用 Redis 进行缓存
@@ -265,6 +270,51 @@ func encrypt(plaintext []byte, password []byte) (string, error) {
 	return base64.StdEncoding.EncodeToString(finalPayload), nil
 }
 
+// negotiatePayloadEncoding 从客户端的 X-Payload-Encoding 头中选出服务端支持的压缩算法
+// 头的格式与 Accept-Encoding 类似，例如 "zstd, gzip"；没有可用算法时返回空字符串
+// q=0 表示客户端明确拒绝该算法
+func negotiatePayloadEncoding(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), payloadEncodingGzip) {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(k, "q") {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && q == 0 {
+					return ""
+				}
+			}
+		}
+		return payloadEncodingGzip
+	}
+	return ""
+}
+
+// compressPayload 在加密前压缩明文，返回压缩后的数据及实际使用的算法
+// 未协商算法或明文小于 compressionThreshold 时原样返回，算法为空字符串
+func compressPayload(plaintext []byte, encoding string) ([]byte, string, error) {
+	if encoding != payloadEncodingGzip || len(plaintext) < compressionThreshold {
+		return plaintext, "", nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(plaintext); err != nil {
+		return nil, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+
+	// 压缩后反而更大时（例如已是高熵数据）直接发送原文
+	if buf.Len() >= len(plaintext) {
+		return plaintext, "", nil
+	}
+	return buf.Bytes(), payloadEncodingGzip, nil
+}
+
 // saveActivityResult 将爬取结果保存到 PostgreSQL 数据库
 func saveActivityResult(activity ActivityResp, taskID string) error {
 	// 1. 将切片/数组字段序列化为 JSON
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestNegotiatePayloadEncoding(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                   "",
+		"gzip":               payloadEncodingGzip,
+		"GZIP":               payloadEncodingGzip,
+		"zstd, gzip":         payloadEncodingGzip,
+		" gzip ; q=0.5":      payloadEncodingGzip,
+		"gzip;q=1":           payloadEncodingGzip,
+		"gzip;q=0":           "",
+		"gzip; q=0.0, br":    "",
+		"zstd, br":           "",
+		"identity":           "",
+		"gzipped":            "",
+		"gzip;level=9;q=0.1": payloadEncodingGzip,
+	} {
+		if got := negotiatePayloadEncoding(header); got != want {
+			t.Errorf("negotiatePayloadEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompressPayload(t *testing.T) {
+	random := make([]byte, 4096)
+	rand.Read(random)
+	compressible := []byte(strings.Repeat("a", compressionThreshold))
+
+	for _, tc := range []struct {
+		name      string
+		plaintext []byte
+		encoding  string
+		want      string
+	}{
+		{"not negotiated", compressible, "", ""},
+		{"below threshold", compressible[:compressionThreshold-1], payloadEncodingGzip, ""},
+		{"at threshold", compressible, payloadEncodingGzip, payloadEncodingGzip},
+		{"larger after gzip", random, payloadEncodingGzip, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, encoding, err := compressPayload(tc.plaintext, tc.encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encoding != tc.want {
+				t.Fatalf("encoding = %q, want %q", encoding, tc.want)
+			}
+			if encoding == "" {
+				if !bytes.Equal(out, tc.plaintext) {
+					t.Error("uncompressed payload differs from the plaintext")
+				}
+				return
+			}
+			if len(out) >= len(tc.plaintext) {
+				t.Errorf("compressed %d bytes into %d", len(tc.plaintext), len(out))
+			}
+			zr, err := gzip.NewReader(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := io.ReadAll(zr); !bytes.Equal(got, tc.plaintext) {
+				t.Error("gzip round trip differs from the plaintext")
+			}
+		})
+	}
+}
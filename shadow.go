@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- 影子封禁 ---
+//
+// status 为 shadowbanned 的 Key 不会收到任何封禁提示：认证与各接口照常应答，
+// 但网关返回看似正常的诱饵数据（已结束的转盘、失效的 URL、错误的密钥，后两者由目标的 decoy 声明），任务接口不再分配任务。
+// 诱饵对同一个 Key 是确定的，多次请求结果一致，不易被察觉。
+// 影子封禁 Key 的每个请求都会记录到 shadow:log:<Key>，供管理员调查。
+
+const (
+	shadowBannedContextKey = "shadowBanned"
+	shadowDetailContextKey = "shadowDetail"
+	shadowLogMax           = 500
+	shadowLogTTL           = 30 * 24 * time.Hour
+
+	// 网关目标的 decoy：url 只替换 URL 的文件名，token 生成长度与字符集相同的假值，none 原样返回
+	gatewayDecoyNone  = "none"
+	gatewayDecoyURL   = "url"
+	gatewayDecoyToken = "token"
+)
+
+// isShadowBanned 当前请求的 Key 是否处于影子封禁状态
+func isShadowBanned(c *gin.Context) bool {
+	return c.GetBool(shadowBannedContextKey)
+}
+
+func shadowLogKey(key string) string {
+	return "shadow:log:" + key
+}
+
+// recordShadowActivity 记录影子封禁 Key 的一次请求
+func recordShadowActivity(c *gin.Context, key, detail string) {
+	saveShadowLogEntry(key, newShadowLogEntry(c, detail))
+}
+
+// newShadowLogEntry 在处理函数改写请求（如反向代理）之前取得请求信息
+func newShadowLogEntry(c *gin.Context, detail string) ShadowLogEntry {
+	return ShadowLogEntry{
+		Time:      time.Now().Format(time.RFC3339),
+		IP:        c.ClientIP(),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		UserAgent: c.Request.UserAgent(),
+		Detail:    detail,
+	}
+}
+
+// setShadowDetail 由处理函数补充说明，permissionMiddleware 在请求结束时连同请求信息记录一条日志
+func setShadowDetail(c *gin.Context, detail string) {
+	c.Set(shadowDetailContextKey, detail)
+}
+
+func saveShadowLogEntry(key string, entry ShadowLogEntry) {
+	data, _ := json.Marshal(entry)
+	log.Printf("[SHADOW] key=%s %s", key, data)
+
+	pipe := swordRdb.Pipeline()
+	pipe.LPush(ctx, shadowLogKey(key), data)
+	pipe.LTrim(ctx, shadowLogKey(key), 0, shadowLogMax-1)
+	pipe.Expire(ctx, shadowLogKey(key), shadowLogTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("保存影子封禁日志失败: %v", err)
+	}
+}
+
+// shadowLog 返回 Key 最近的影子封禁日志，最新的在前
+func shadowLog(key string) ([]ShadowLogEntry, error) {
+	raw, err := swordRdb.LRange(ctx, shadowLogKey(key), 0, shadowLogMax-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ShadowLogEntry, 0, len(raw))
+	for _, item := range raw {
+		var entry ShadowLogEntry
+		if err := json.Unmarshal([]byte(item), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// shadowDigest 诱饵数据的确定性来源：HMAC(派生密钥, key | value)
+func shadowDigest(key, value string) []byte {
+	mac := hmac.New(sha256.New, []byte(jwtSecretKey))
+	mac.Write([]byte("corn-shadow-v1|" + key + "|" + value))
+	return mac.Sum(nil)
+}
+
+// decoyURL 保留协议、域名、目录与查询参数，只替换文件名，使 URL 看起来合理但无法使用
+func decoyURL(key, raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	digest := hex.EncodeToString(shadowDigest(key, raw))[:6]
+	dir, file := path.Split(u.Path)
+	if ext := path.Ext(file); ext != "" {
+		base := strings.TrimSuffix(file, ext)
+		if i := strings.LastIndex(base, "_"); i > 0 {
+			base = base[:i]
+		}
+		u.Path = dir + base + "_" + digest + ext
+	} else {
+		u.Path = path.Join(u.Path, digest)
+	}
+	return u.String()
+}
+
+// decoyToken 生成与原值长度、字符集相同的假密钥
+func decoyToken(key, value string) string {
+	alphabet := "abcdefghijklmnopqrstuvwxyz"
+	if _, err := hex.DecodeString(value); err == nil {
+		alphabet = "0123456789abcdef"
+	}
+
+	out := make([]byte, len(value))
+	digest := shadowDigest(key, value)
+	for i := range out {
+		if i > 0 && i%len(digest) == 0 {
+			digest = shadowDigest(key, string(digest))
+		}
+		out[i] = alphabet[int(digest[i%len(digest)])%len(alphabet)]
+	}
+	return string(out)
+}
+
+// shadowDecoy 返回影子封禁 Key 看到的诱饵数据：string、list、map 目标按声明的 decoy 替换每个值，
+// 转盘与抽奖 provider 的结果按类型替换，其余原样返回
+func shadowDecoy(c *gin.Context, target, decoy string, data any) any {
+	key := c.GetString("longTermKey")
+	setShadowDetail(c, "gateway target "+target)
+
+	var replace func(key, value string) string
+	switch decoy {
+	case gatewayDecoyURL:
+		replace = decoyURL
+	case gatewayDecoyToken:
+		replace = decoyToken
+	}
+
+	switch v := data.(type) {
+	case string:
+		if replace != nil {
+			return replace(key, v)
+		}
+	case []string:
+		if replace != nil {
+			out := make([]string, len(v))
+			for i, s := range v {
+				out[i] = replace(key, s)
+			}
+			return out
+		}
+	case map[string]string:
+		if replace != nil {
+			out := make(map[string]string, len(v))
+			for k, s := range v {
+				out[k] = replace(key, s)
+			}
+			return out
+		}
+	case []ValidRound:
+		// 过期的转盘列表：全部标记为已结束
+		out := make([]ValidRound, len(v))
+		for i, r := range v {
+			r.IsFinished = true
+			out[i] = r
+		}
+		return out
+	case []LotteryProduct:
+		return []LotteryProduct{}
+	}
+	return data
+}
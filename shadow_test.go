@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDecoyURL(t *testing.T) {
+	raw := "https://huodong3.3839.com/n/hykb/cornfarm/ajax_daily.php?ac=1"
+
+	got := decoyURL("key-a", raw)
+	if got == raw {
+		t.Fatal("decoy URL equals the original")
+	}
+	if again := decoyURL("key-a", raw); again != got {
+		t.Errorf("decoy URL is not deterministic: %s vs %s", got, again)
+	}
+	if other := decoyURL("key-b", raw); other == got {
+		t.Error("different keys got the same decoy URL")
+	}
+
+	u, err := url.Parse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "huodong3.3839.com" || u.RawQuery != "ac=1" {
+		t.Errorf("decoy URL should keep host and query, got %s", got)
+	}
+	if !strings.HasPrefix(u.Path, "/n/hykb/cornfarm/ajax_") || !strings.HasSuffix(u.Path, ".php") {
+		t.Errorf("decoy URL should look like the original, got %s", got)
+	}
+}
+
+func TestDecoyToken(t *testing.T) {
+	for _, value := range []string{"hbktahqbyihfiidc", "c1714e4e2a0d5f3b9e8f7a6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b7c6d5e4f"} {
+		got := decoyToken("key-a", value)
+		if got == value || len(got) != len(value) {
+			t.Errorf("decoyToken(%q) = %q, want a different value of the same length", value, got)
+		}
+		if decoyToken("key-a", value) != got {
+			t.Errorf("decoyToken(%q) is not deterministic", value)
+		}
+	}
+	if got := decoyToken("key-a", "c1714e4e"); strings.Trim(got, "0123456789abcdef") != "" {
+		t.Errorf("hex secret should stay hex, got %q", got)
+	}
+}
+
+func TestShadowDecoyFollowsTargetDecoy(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("longTermKey", "key-a")
+	link := "https://api.3839app.com/kuaibao/android/api.php"
+	secret := "c1714e41e5a907874c59a4d81a8486ea"
+
+	for _, tc := range []struct {
+		decoy string
+		data  any
+		want  any
+	}{
+		{gatewayDecoyURL, []string{link}, []string{decoyURL("key-a", link)}},
+		{gatewayDecoyToken, secret, decoyToken("key-a", secret)},
+		{gatewayDecoyToken, map[string]string{"value": secret}, map[string]string{"value": decoyToken("key-a", secret)}},
+		// 未声明 decoy 的目标即使值像 URL 或叫 value 也原样返回
+		{"", []string{link}, []string{link}},
+		{gatewayDecoyNone, map[string]string{"value": secret}, map[string]string{"value": secret}},
+	} {
+		if got := shadowDecoy(c, "x", tc.decoy, tc.data); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("decoy %q: got %v, want %v", tc.decoy, got, tc.want)
+		}
+	}
+}
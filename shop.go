@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// --- 商店商品目录 ---
+//
+// pp、dg 等网关目标下发的商品来自 Postgres 的 shop_products 表，按 category 区分（网关目标的 args.category），
+// 由 /admin/shop/products 增删改，表为空时写入 defaultShopProducts。
+// 只有启用且处于有效期 [valid_from, valid_until) 内的商品会下发；目录在内存中缓存 shopCatalogTTL，
+// 本实例的修改立即生效，其它实例最多延迟一个 TTL。
+// 自动发现：从 products.js（GetAllProducts）中找出名称匹配 SHOP_DISCOVERY_PATTERN 的新商品，
+// 以停用状态写入 SHOP_DISCOVERY_CATEGORY，管理员审核后启用；也可以调用 POST /admin/shop/discover 手动触发。
+
+const (
+	shopCatalogTTL = 30 * time.Second
+
+	shopSourceSeed       = "seed"
+	shopSourceAdmin      = "admin"
+	shopSourceDiscovered = "discovered"
+)
+
+// shopCatalog 启用的商品缓存
+var shopCatalog struct {
+	mu       sync.Mutex
+	products []ShopProduct
+	loadedAt time.Time
+}
+
+// shopProductLink 商品的默认链接
+func shopProductLink(productID string) string {
+	return "https://shop.3839.com/?id=" + url.QueryEscape(productID) + "&imm=1"
+}
+
+// initShopCatalog 表为空时写入内置商品
+func initShopCatalog() {
+	names := make([]string, 0, len(defaultShopProducts))
+	for name := range defaultShopProducts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	products := make([]ShopProduct, len(names))
+	for i, name := range names {
+		item := defaultShopProducts[name]
+		products[i] = ShopProduct{ProductID: item.ID, Name: name, Link: item.LK, Category: "pp", Enabled: true, Source: shopSourceSeed}
+	}
+	if err := seedShopProducts(products); err != nil {
+		log.Printf("写入内置商品失败: %v", err)
+	}
+}
+
+// loadShopCatalog 返回启用的商品；缓存过期后重新查询，查询失败时继续使用过期的缓存
+func loadShopCatalog() ([]ShopProduct, error) {
+	shopCatalog.mu.Lock()
+	defer shopCatalog.mu.Unlock()
+	if shopCatalog.products != nil && time.Since(shopCatalog.loadedAt) < shopCatalogTTL {
+		return shopCatalog.products, nil
+	}
+
+	products, err := listShopProducts("", true)
+	if err != nil {
+		if shopCatalog.products != nil {
+			log.Printf("刷新商品目录失败，继续使用缓存: %v", err)
+			return shopCatalog.products, nil
+		}
+		return nil, err
+	}
+	shopCatalog.products, shopCatalog.loadedAt = products, time.Now()
+	return products, nil
+}
+
+// invalidateShopCatalog 管理接口修改商品后清空缓存
+func invalidateShopCatalog() {
+	shopCatalog.mu.Lock()
+	shopCatalog.products = nil
+	shopCatalog.mu.Unlock()
+}
+
+// activeAt 商品在 now 时是否应当下发
+func (p ShopProduct) activeAt(now time.Time) bool {
+	return p.Enabled &&
+		(p.ValidFrom == nil || !now.Before(*p.ValidFrom)) &&
+		(p.ValidUntil == nil || now.Before(*p.ValidUntil))
+}
+
+// activeShopItems 分类下当前可以下发的商品，键为商品名
+func activeShopItems(products []ShopProduct, category string, now time.Time) map[string]shopItem {
+	items := make(map[string]shopItem)
+	for _, p := range products {
+		if p.Category == category && p.activeAt(now) {
+			items[p.Name] = shopItem{ID: p.ProductID, LK: p.Link}
+		}
+	}
+	return items
+}
+
+// provideShopProducts 网关 provider：args.category 分类下当前有效的商品
+func provideShopProducts(c *gin.Context, req gatewayRequest, args map[string]string) (any, bool) {
+	products, err := loadShopCatalog()
+	if err != nil {
+		log.Printf("加载商品目录失败: %v", err)
+		respondErrorCode(c, http.StatusInternalServerError, msgShopProductsFailed)
+		return nil, false
+	}
+	return activeShopItems(products, args["category"], time.Now()), true
+}
+
+// shopProductInput 管理接口新增、修改商品的请求体；link 为空时按商品 ID 生成，enabled 默认为 true
+type shopProductInput struct {
+	ProductID  string     `json:"product_id"`
+	Name       string     `json:"name"`
+	Link       string     `json:"link"`
+	Category   string     `json:"category"`
+	Enabled    *bool      `json:"enabled"`
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until"`
+}
+
+// product 校验输入并转换为 ShopProduct
+func (in shopProductInput) product() (ShopProduct, error) {
+	p := ShopProduct{
+		ProductID:  in.ProductID,
+		Name:       in.Name,
+		Link:       in.Link,
+		Category:   in.Category,
+		Enabled:    in.Enabled == nil || *in.Enabled,
+		ValidFrom:  in.ValidFrom,
+		ValidUntil: in.ValidUntil,
+		Source:     shopSourceAdmin,
+	}
+	if p.ProductID == "" || p.Name == "" || p.Category == "" {
+		return p, errors.New("product_id, name and category are required")
+	}
+	if p.Link == "" {
+		p.Link = shopProductLink(p.ProductID)
+	}
+	if u, err := url.Parse(p.Link); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return p, errors.New("link must be an http(s) URL")
+	}
+	if p.ValidFrom != nil && p.ValidUntil != nil && !p.ValidUntil.After(*p.ValidFrom) {
+		return p, errors.New("valid_until must be after valid_from")
+	}
+	return p, nil
+}
+
+// isUniqueViolation 写入是否因为唯一约束冲突失败
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// discoverShopProducts 从 products.js 中找出名称匹配 pattern、且不在该分类中的商品，按商品 ID 排序
+func discoverShopProducts(pattern *regexp.Regexp, category string) ([]ShopProduct, error) {
+	feed, err := GetAllProducts(strconv.FormatInt(time.Now().Unix(), 10))
+	if err != nil {
+		return nil, fmt.Errorf("GetAllProducts failed: %w", err)
+	}
+	existing, err := listShopProducts(category, false)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(existing)*2)
+	for _, p := range existing {
+		known["id:"+p.ProductID] = true
+		known["name:"+p.Name] = true
+	}
+
+	var found []ShopProduct
+	for id, fields := range feed {
+		name, _ := fields["product_name"].(string)
+		if name == "" || !pattern.MatchString(name) || known["id:"+id] || known["name:"+name] {
+			continue
+		}
+		found = append(found, ShopProduct{
+			ProductID: id,
+			Name:      name,
+			Link:      shopProductLink(id),
+			Category:  category,
+			Source:    shopSourceDiscovered,
+		})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].ProductID < found[j].ProductID })
+	return found, nil
+}
+
+// runShopDiscovery 定时任务：按 SHOP_DISCOVERY_PATTERN 发现新商品并以停用状态写入
+func runShopDiscovery() {
+	cfg := currentConfig().Shop
+	if cfg.DiscoveryPattern == "" {
+		return
+	}
+	pattern, err := regexp.Compile(cfg.DiscoveryPattern)
+	if err != nil {
+		log.Printf("无效的 SHOP_DISCOVERY_PATTERN: %v", err)
+		return
+	}
+	found, err := discoverShopProducts(pattern, cfg.DiscoveryCategory)
+	if err != nil {
+		log.Printf("发现商品失败: %v", err)
+		return
+	}
+	n, err := insertShopProductsIfAbsent(found)
+	if err != nil {
+		log.Printf("写入发现的商品失败: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("发现 %d 个新商品，已以停用状态写入分类 %s，等待管理员审核", n, cfg.DiscoveryCategory)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActiveShopItems(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+	products := []ShopProduct{
+		{ProductID: "1", Name: "always", Link: "l1", Category: "pp", Enabled: true},
+		{ProductID: "2", Name: "disabled", Category: "pp"},
+		{ProductID: "3", Name: "started", Category: "pp", Enabled: true, ValidFrom: &before},
+		{ProductID: "4", Name: "upcoming", Category: "pp", Enabled: true, ValidFrom: &after},
+		{ProductID: "5", Name: "expired", Category: "pp", Enabled: true, ValidUntil: &now},
+		{ProductID: "6", Name: "other", Category: "dg", Enabled: true},
+	}
+
+	items := activeShopItems(products, "pp", now)
+	if len(items) != 2 || items["always"] != (shopItem{ID: "1", LK: "l1"}) || items["started"].ID != "3" {
+		t.Errorf("active pp items = %+v", items)
+	}
+	if items := activeShopItems(products, "dg", now); len(items) != 1 {
+		t.Errorf("active dg items = %+v", items)
+	}
+	if items := activeShopItems(nil, "pp", now); items == nil || len(items) != 0 {
+		t.Errorf("empty catalog should yield an empty map, got %#v", items)
+	}
+}
+
+func TestShopProductInput(t *testing.T) {
+	p, err := shopProductInput{ProductID: "12 3", Name: "5Q币", Category: "pp"}.product()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Enabled || p.Source != shopSourceAdmin || p.Link != "https://shop.3839.com/?id=12+3&imm=1" {
+		t.Errorf("product = %+v", p)
+	}
+
+	disabled := false
+	if p, _ := (shopProductInput{ProductID: "1", Name: "a", Category: "pp", Enabled: &disabled}).product(); p.Enabled {
+		t.Error("enabled=false should be kept")
+	}
+
+	from := time.Now()
+	until := from.Add(-time.Minute)
+	invalid := []shopProductInput{
+		{Name: "a", Category: "pp"},
+		{ProductID: "1", Category: "pp"},
+		{ProductID: "1", Name: "a"},
+		{ProductID: "1", Name: "a", Category: "pp", Link: "javascript:alert(1)"},
+		{ProductID: "1", Name: "a", Category: "pp", ValidFrom: &from, ValidUntil: &until},
+	}
+	for _, in := range invalid {
+		if _, err := in.product(); err == nil {
+			t.Errorf("input %+v should be rejected", in)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- 商店数据变更记录 ---
+//
+// 定时任务（cron.shop_feed_refresh）重新下载 products.js 与 classify_24.js（同时刷新 feedcache.go 中的缓存），
+// 与 Postgres 中上一次的快照（shop_feed_snapshots）逐项比较，把新增、删除与内容变化的商品和转盘写入 shop_feed_changes。
+// 第一次运行只保存快照作为基线，不产生变更；上游返回空数据时视为异常，不会把全部条目记为删除。
+// 每个实例都会运行定时任务，比较与保存在同一个持有 advisory 锁的事务中完成，同一变化只会被记录一次。
+// 客户端通过 GET /api/shop/changes?since=<RFC 3339 时间> 查询某个时间之后的变化，按 after_id 翻页。
+// 管理员也可以调用 POST /admin/shop/feeds/refresh 立即执行一次。
+
+const (
+	shopFeedProducts = "products"
+	shopFeedRounds   = "rounds"
+
+	shopFeedAdded   = "added"
+	shopFeedRemoved = "removed"
+	shopFeedChanged = "changed"
+
+	// shopFeedChangeRetention 变更记录的保留时长，也是 since 最早可以查询的时间
+	shopFeedChangeRetention = 180 * 24 * time.Hour
+	// shopFeedDefaultSince 未指定 since 时返回最近多久的变更
+	shopFeedDefaultSince   = 7 * 24 * time.Hour
+	maxShopFeedChangesPage = 500
+)
+
+var shopFeeds = []string{shopFeedProducts, shopFeedRounds}
+
+// shopFeedRefreshMu 本实例的定时任务与管理接口不会同时刷新；多个实例之间由 updateShopFeedSnapshot 的 advisory 锁保证
+var shopFeedRefreshMu sync.Mutex
+
+// shopFeedItem 快照中的一项；Hash 为 Data 的 SHA-256，用于判断内容是否变化
+type shopFeedItem struct {
+	Name string
+	Data json.RawMessage
+	Hash string
+}
+
+func newShopFeedItem(name string, v any) (shopFeedItem, error) {
+	// encoding/json 对 map 的键排序，相同内容总是得到相同的 JSON
+	data, err := json.Marshal(v)
+	if err != nil {
+		return shopFeedItem{}, err
+	}
+	sum := sha256.Sum256(data)
+	return shopFeedItem{Name: name, Data: data, Hash: hex.EncodeToString(sum[:])}, nil
+}
+
+// productFeedItems products.js 中的商品，键为商品 ID
+func productFeedItems(products map[string]map[string]any) (map[string]shopFeedItem, error) {
+	items := make(map[string]shopFeedItem, len(products))
+	for id, product := range products {
+		name, _ := product["product_name"].(string)
+		item, err := newShopFeedItem(name, product)
+		if err != nil {
+			return nil, err
+		}
+		items[id] = item
+	}
+	return items, nil
+}
+
+// roundFeedItems classify_24.js 中的转盘，键为商品 ID；名称取自 products（可以为空）
+func roundFeedItems(rounds []ProductRound, products map[string]map[string]any) (map[string]shopFeedItem, error) {
+	items := make(map[string]shopFeedItem, len(rounds))
+	for _, round := range rounds {
+		name, _ := products[round.ProductID]["product_name"].(string)
+		item, err := newShopFeedItem(name, round)
+		if err != nil {
+			return nil, err
+		}
+		items[round.ProductID] = item
+	}
+	return items, nil
+}
+
+// diffShopFeed 比较两份快照，按条目 ID 排序返回变更
+func diffShopFeed(feed string, previous, current map[string]shopFeedItem) []ShopFeedChange {
+	var changes []ShopFeedChange
+	for id, item := range current {
+		old, ok := previous[id]
+		switch {
+		case !ok:
+			changes = append(changes, ShopFeedChange{Feed: feed, ItemID: id, Change: shopFeedAdded, Name: item.Name, After: item.Data})
+		case old.Hash != item.Hash:
+			changes = append(changes, ShopFeedChange{Feed: feed, ItemID: id, Change: shopFeedChanged, Name: item.Name, Before: old.Data, After: item.Data})
+		}
+	}
+	for id, old := range previous {
+		if _, ok := current[id]; !ok {
+			changes = append(changes, ShopFeedChange{Feed: feed, ItemID: id, Change: shopFeedRemoved, Name: old.Name, Before: old.Data})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ItemID < changes[j].ItemID })
+	return changes
+}
+
+// refreshShopFeeds 下载两份数据并记录变更，由定时任务调用
+func refreshShopFeeds() {
+	if _, err := runShopFeedRefresh(); err != nil {
+		log.Printf("刷新商店数据失败: %v", err)
+	}
+}
+
+// runShopFeedRefresh 下载两份数据并记录变更，返回各数据新增的变更数；一份数据失败不影响另一份
+func runShopFeedRefresh() (map[string]int, error) {
+	shopFeedRefreshMu.Lock()
+	defer shopFeedRefreshMu.Unlock()
+
+	recorded := map[string]int{}
+	var errs []error
+
+	products, err := shopProductsFeed.refresh()
+	if err == nil {
+		var items map[string]shopFeedItem
+		if items, err = productFeedItems(products); err == nil {
+			recorded[shopFeedProducts], err = recordShopFeed(shopFeedProducts, items)
+		}
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", shopFeedProducts, err))
+	}
+
+	// products 下载失败时转盘照常比较，只是没有名称
+	rounds, err := shopRoundsFeed.refresh()
+	if err == nil {
+		var items map[string]shopFeedItem
+		if items, err = roundFeedItems(rounds, products); err == nil {
+			recorded[shopFeedRounds], err = recordShopFeed(shopFeedRounds, items)
+		}
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", shopFeedRounds, err))
+	}
+
+	if n, err := deleteShopFeedChangesBefore(time.Now().Add(-shopFeedChangeRetention)); err != nil {
+		log.Printf("清理过期的商店数据变更失败: %v", err)
+	} else if n > 0 {
+		log.Printf("已清理 %d 条过期的商店数据变更", n)
+	}
+	return recorded, errors.Join(errs...)
+}
+
+// recordShopFeed 与快照比较并保存变更，返回变更数
+func recordShopFeed(feed string, current map[string]shopFeedItem) (int, error) {
+	var recorded int
+	err := updateShopFeedSnapshot(feed, current, func(previous map[string]shopFeedItem) ([]ShopFeedChange, bool) {
+		changes, record := planShopFeedChanges(feed, previous, current)
+		if record {
+			recorded = len(changes)
+		}
+		return changes, record
+	})
+	if err != nil {
+		return 0, err
+	}
+	if recorded > 0 {
+		log.Printf("商店数据 %s 有 %d 项变更", feed, recorded)
+	}
+	return recorded, nil
+}
+
+// planShopFeedChanges 决定如何更新快照：返回需要应用到快照的变更，以及是否把它们记为变更
+// 没有快照时全部条目作为基线保存但不记录；上游数据为空时视为异常，什么也不做
+func planShopFeedChanges(feed string, previous, current map[string]shopFeedItem) ([]ShopFeedChange, bool) {
+	if len(current) == 0 && len(previous) > 0 {
+		log.Printf("警告: 上游的 %s 数据为空，跳过本次比较以免把全部 %d 项记为删除", feed, len(previous))
+		return nil, false
+	}
+	if len(previous) == 0 {
+		log.Printf("商店数据 %s 尚无快照，保存 %d 项作为基线", feed, len(current))
+		return diffShopFeed(feed, nil, current), false
+	}
+	return diffShopFeed(feed, previous, current), true
+}
+
+// getShopFeedChangesHandler 某个时间之后的商店数据变更
+//
+//	GET /api/shop/changes?since=2024-05-01T00:00:00%2B08:00&feed=rounds&after_id=0&limit=100
+//
+// since 默认 7 天前，最早为保留期的开始；按 id 升序返回，has_more 为 true 时以最后一条的 id 作为 after_id 继续查询
+func getShopFeedChangesHandler(c *gin.Context) {
+	now := time.Now()
+	since := now.Add(-shopFeedDefaultSince)
+	if v := c.Query("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondErrorCode(c, http.StatusBadRequest, msgInvalidParameter, "since")
+			return
+		}
+		since = t
+		if earliest := now.Add(-shopFeedChangeRetention); since.Before(earliest) {
+			since = earliest
+		}
+	}
+	feed := c.Query("feed")
+	if feed != "" && !slices.Contains(shopFeeds, feed) {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidParameter, "feed")
+		return
+	}
+	afterID, err := strconv.ParseInt(c.DefaultQuery("after_id", "0"), 10, 64)
+	if err != nil || afterID < 0 {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidParameter, "after_id")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		respondErrorCode(c, http.StatusBadRequest, msgInvalidParameter, "limit")
+		return
+	}
+	limit = min(limit, maxShopFeedChangesPage)
+
+	changes, err := getShopFeedChanges(since, feed, afterID, limit+1)
+	if err != nil {
+		log.Printf("查询商店数据变更失败: %v", err)
+		respondErrorCode(c, http.StatusInternalServerError, msgShopChangesFailed)
+		return
+	}
+	hasMore := len(changes) > limit
+	if hasMore {
+		changes = changes[:limit]
+	}
+	respondEncrypted(c, http.StatusOK, ShopFeedChangesPage{Changes: changes, HasMore: hasMore})
+}
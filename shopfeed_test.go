@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestDiffShopFeed(t *testing.T) {
+	item := func(name string, v any) shopFeedItem {
+		t.Helper()
+		it, err := newShopFeedItem(name, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return it
+	}
+	previous := map[string]shopFeedItem{
+		"1": item("a", map[string]any{"price": 1}),
+		"2": item("b", map[string]any{"price": 2}),
+		"3": item("c", map[string]any{"price": 3}),
+	}
+	current := map[string]shopFeedItem{
+		"1": item("a", map[string]any{"price": 1}),
+		"2": item("b", map[string]any{"price": 20}),
+		"4": item("d", map[string]any{"price": 4}),
+	}
+
+	changes := diffShopFeed(shopFeedProducts, previous, current)
+	want := []struct{ id, change string }{{"2", shopFeedChanged}, {"3", shopFeedRemoved}, {"4", shopFeedAdded}}
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes, want %d: %+v", len(changes), len(want), changes)
+	}
+	for i, w := range want {
+		ch := changes[i]
+		if ch.ItemID != w.id || ch.Change != w.change || ch.Feed != shopFeedProducts {
+			t.Errorf("changes[%d] = %s/%s, want %s/%s", i, ch.ItemID, ch.Change, w.id, w.change)
+		}
+	}
+	if changes[0].Before == nil || changes[0].After == nil || changes[1].After != nil || changes[2].Before != nil {
+		t.Errorf("unexpected before/after: %+v", changes)
+	}
+}
+
+func TestProductFeedItemsStableHash(t *testing.T) {
+	products := map[string]map[string]any{
+		"1": {"product_name": "转盘", "price": 10, "tags": []any{"x", "y"}},
+	}
+	a, err := productFeedItems(products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := productFeedItems(map[string]map[string]any{
+		"1": {"tags": []any{"x", "y"}, "price": 10, "product_name": "转盘"},
+	})
+	if a["1"].Hash != b["1"].Hash || a["1"].Name != "转盘" {
+		t.Errorf("hash differs for identical products: %+v %+v", a["1"], b["1"])
+	}
+}
+
+func TestPlanShopFeedChanges(t *testing.T) {
+	items, err := productFeedItems(map[string]map[string]any{"1": {"product_name": "a"}, "2": {"product_name": "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有快照：全部作为基线保存，不记为变更
+	if changes, record := planShopFeedChanges(shopFeedProducts, nil, items); record || len(changes) != 2 {
+		t.Errorf("baseline = %d changes, record %v; want 2 snapshot rows and no record", len(changes), record)
+	}
+	// 上游为空：不删除快照，也不记录
+	if changes, record := planShopFeedChanges(shopFeedProducts, items, nil); record || len(changes) != 0 {
+		t.Errorf("empty feed = %d changes, record %v; want nothing", len(changes), record)
+	}
+	// 已有快照：记录差异
+	if changes, record := planShopFeedChanges(shopFeedProducts, map[string]shopFeedItem{"1": items["1"]}, items); !record || len(changes) != 1 || changes[0].ItemID != "2" {
+		t.Errorf("diff = %+v, record %v; want item 2 recorded as added", changes, record)
+	}
+}
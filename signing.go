@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- 服务端响应签名 ---
+//
+// 每个响应都附带 Ed25519 签名，客户端内置（pin）服务器公钥后即可确认响应确实来自服务器。
+// 签名内容为：
+//
+//	status \n method \n path \n timestamp \n hex(sha256(body))
+//
+// 普通响应的签名放在响应头中；流式响应（处理函数调用了 Flush）无法预先得知完整响应体，
+// 签名改为通过 HTTP Trailer 在响应结束时发送。
+
+const (
+	headerServerTimestamp = "X-Server-Timestamp"
+	headerServerKeyID     = "X-Server-Key-Id"
+	headerServerSignature = "X-Server-Signature"
+)
+
+// signingKey 当前用于签名的私钥，nextPublicKey 为轮换前预先公布的下一把公钥（可为空）
+var (
+	signingKey    ed25519.PrivateKey
+	signingKeyID  string
+	nextPublicKey ed25519.PublicKey
+)
+
+// initResponseSigning 加载签名私钥与预公布的下一把公钥
+// 开发环境未配置私钥时生成临时密钥，此时客户端无法预先 pin 公钥；生产环境由 validate 拒绝启动
+func initResponseSigning() {
+	if serverSigningKey == "" {
+		if currentConfig().Environment != envDevelopment {
+			log.Fatalf("未配置 SERVER_SIGNING_KEY，只有开发环境可以使用临时签名密钥")
+		}
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatalf("生成临时签名密钥失败: %v", err)
+		}
+		signingKey = priv
+		log.Printf("警告: 未配置 SERVER_SIGNING_KEY，已生成临时签名密钥 %s，重启后会变化", publicKeyID(priv.Public().(ed25519.PublicKey)))
+	} else {
+		priv, err := parseSigningKey(serverSigningKey.Reveal())
+		if err != nil {
+			log.Fatalf("无效的 SERVER_SIGNING_KEY: %v", err)
+		}
+		signingKey = priv
+	}
+	signingKeyID = publicKeyID(signingKey.Public().(ed25519.PublicKey))
+
+	if serverSigningNextPublicKey != "" {
+		raw, err := base64.StdEncoding.DecodeString(serverSigningNextPublicKey)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			log.Fatalf("无效的 SERVER_SIGNING_NEXT_PUBLIC_KEY: 需要 base64 编码的 %d 字节公钥", ed25519.PublicKeySize)
+		}
+		nextPublicKey = raw
+	}
+
+	log.Printf("响应签名已启用，当前公钥 ID: %s", signingKeyID)
+}
+
+// parseSigningKey 解析 base64 编码的 32 字节种子或 64 字节私钥
+func parseSigningKey(encoded string) (ed25519.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, fmt.Errorf("需要 %d 字节种子或 %d 字节私钥，实际 %d 字节", ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+	}
+}
+
+// publicKeyID 公钥的短标识，客户端据此在 pin 的多把公钥中选择
+func publicKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// responseSignatureMessage 构造待签名的消息，客户端必须以完全相同的方式构造
+func responseSignatureMessage(status int, method, path, timestamp string, bodyHash []byte) []byte {
+	return fmt.Appendf(nil, "%d\n%s\n%s\n%s\n%s", status, method, path, timestamp, hex.EncodeToString(bodyHash))
+}
+
+// signingWriter 缓存响应体，在处理结束时计算签名后一次性写出
+// 处理函数调用 Flush 时切换为流式模式，签名改为通过 Trailer 发送
+type signingWriter struct {
+	gin.ResponseWriter
+	request   *http.Request
+	path      string // 签名使用的路径，在反向代理改写 URL 之前取得
+	status    int
+	body      bytes.Buffer
+	written   bool
+	streaming bool
+	hash      hash.Hash
+	timestamp string
+}
+
+func (w *signingWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *signingWriter) WriteHeaderNow() {
+	w.written = true
+	if w.streaming {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *signingWriter) Write(data []byte) (int, error) {
+	w.written = true
+	if !w.streaming {
+		return w.body.Write(data)
+	}
+	w.hash.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *signingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *signingWriter) Written() bool {
+	return w.written
+}
+
+func (w *signingWriter) Status() int {
+	return w.status
+}
+
+func (w *signingWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	if w.streaming {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+// Flush 第一次调用时发送响应头与已缓存的数据，此后直接透传
+func (w *signingWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		w.written = true
+		w.hash = sha256.New()
+		w.timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+
+		header := w.ResponseWriter.Header()
+		header.Set(headerServerTimestamp, w.timestamp)
+		header.Set(headerServerKeyID, signingKeyID)
+		header.Set("Trailer", headerServerSignature)
+		header.Del("Content-Length")
+
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.WriteHeaderNow()
+		if w.body.Len() > 0 {
+			w.hash.Write(w.body.Bytes())
+			w.ResponseWriter.Write(w.body.Bytes())
+			w.body.Reset()
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+// finish 计算签名并写出响应；流式模式下签名作为 Trailer 发送
+func (w *signingWriter) finish() {
+	if w.streaming {
+		message := responseSignatureMessage(w.status, w.request.Method, w.path, w.timestamp, w.hash.Sum(nil))
+		w.ResponseWriter.Header().Set(headerServerSignature, base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, message)))
+		return
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	bodyHash := sha256.Sum256(w.body.Bytes())
+	message := responseSignatureMessage(w.status, w.request.Method, w.path, timestamp, bodyHash[:])
+
+	header := w.ResponseWriter.Header()
+	header.Set(headerServerTimestamp, timestamp)
+	header.Set(headerServerKeyID, signingKeyID)
+	header.Set(headerServerSignature, base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, message)))
+
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	if w.body.Len() > 0 {
+		w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
+
+// ginDefaultBodies 未匹配路由时 gin 的默认响应体；签名中间件写出响应后 gin 不会再补写，因此由这里代为写出并签名
+var ginDefaultBodies = map[int]string{
+	http.StatusNotFound:         "404 page not found",
+	http.StatusMethodNotAllowed: "405 method not allowed",
+}
+
+// responseSigningMiddleware 为所有响应附加 Ed25519 签名，应注册在所有会写响应的中间件之前
+func responseSigningMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		original := c.Writer
+		// 未匹配路由（404/405）时 gin 在调用中间件之前已设置好状态码
+		status := original.Status()
+		sw := &signingWriter{ResponseWriter: original, request: c.Request, path: originalRequestPath(c.Request), status: status}
+		c.Writer = sw
+
+		c.Next()
+
+		if body, ok := ginDefaultBodies[status]; ok && !sw.written && sw.status == status {
+			sw.Header().Set("Content-Type", "text/plain")
+			sw.WriteString(body)
+		}
+		sw.finish()
+		c.Writer = original
+	}
+}
+
+// handlePublicKeys 公布当前签名公钥以及轮换前预先公布的下一把公钥
+func handlePublicKeys(c *gin.Context) {
+	type publicKey struct {
+		ID        string `json:"id"`
+		PublicKey string `json:"public_key"`
+	}
+
+	current := signingKey.Public().(ed25519.PublicKey)
+	resp := gin.H{"current": publicKey{ID: signingKeyID, PublicKey: base64.StdEncoding.EncodeToString(current)}}
+	if nextPublicKey != nil {
+		resp["next"] = publicKey{ID: publicKeyID(nextPublicKey), PublicKey: base64.StdEncoding.EncodeToString(nextPublicKey)}
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ElieenAndBella/corn_server/client"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newSigningTestServer 启动一个只挂载签名中间件的服务器，不依赖 Redis
+func newSigningTestServer(t *testing.T, tamper bool) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	if tamper {
+		// 在签名之后修改响应体，模拟中间人篡改
+		router.Use(func(c *gin.Context) {
+			c.Next()
+			c.Writer.Write([]byte(" "))
+		})
+	}
+	router.Use(responseSigningMiddleware())
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	t.Cleanup(backend.Close)
+	router.GET("/apk/operations", OperationsProxy(ReverseProxy(backend.URL)))
+
+	router.POST("/authenticate", func(c *gin.Context) {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte("test"))
+		c.Header("server-timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+		c.JSON(http.StatusOK, gin.H{"jwt": token})
+	})
+	router.GET("/api/activities/getall", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"activity_ids": []int{1, 2, 3}})
+	})
+	router.GET("/api/activities/export", func(c *gin.Context) {
+		c.Set("longTermKey", testLongTermKey)
+		w, err := startEncryptedStream(c)
+		if err != nil {
+			respondErrorCode(c, http.StatusInternalServerError, msgActivitiesFetchFailed)
+			return
+		}
+		line, _ := json.Marshal(Activity{ActivityID: 7})
+		w.Write(append(line, '\n'))
+		w.Close()
+	})
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestResponseSignatureVerifiedByClient(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signingKey, signingKeyID = priv, publicKeyID(pub)
+
+	for _, tamper := range []bool{false, true} {
+		srv := newSigningTestServer(t, tamper)
+		c, err := client.New(client.Config{
+			BaseURL:         srv.URL,
+			Key:             testLongTermKey,
+			IntegritySecret: appIntegritySecretClient,
+			ServerKeys:      []ed25519.PublicKey{pub},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.UserActivityIDs(context.Background())
+		if tamper != errors.Is(err, client.ErrBadServerSignature) {
+			t.Errorf("tamper=%v: UserActivityIDs error = %v", tamper, err)
+		}
+	}
+}
+
+func TestStreamedResponseSignedInTrailer(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signingKey, signingKeyID = priv, publicKeyID(pub)
+
+	srv := newSigningTestServer(t, false)
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	for _, keys := range [][]ed25519.PublicKey{{pub}, {other}} {
+		cl, err := client.New(client.Config{
+			BaseURL:         srv.URL,
+			Key:             testLongTermKey,
+			IntegritySecret: appIntegritySecretClient,
+			ServerKeys:      keys,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		trusted := keys[0].Equal(pub)
+		err = cl.ExportActivities(context.Background(), func(client.Activity) error { return nil })
+		if trusted && err != nil {
+			t.Errorf("ExportActivities with pinned key: %v", err)
+		}
+		if !trusted && !errors.Is(err, client.ErrBadServerSignature) {
+			t.Errorf("ExportActivities with unknown key error = %v, want ErrBadServerSignature", err)
+		}
+	}
+}
+
+// verifySignedResponse 按客户端的方式校验签名：路径取客户端请求的路径
+func verifySignedResponse(t *testing.T, pub ed25519.PublicKey, path string) (*http.Response, []byte) {
+	t.Helper()
+	srv := newSigningTestServer(t, false)
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	sig, _ := base64.StdEncoding.DecodeString(resp.Header.Get(headerServerSignature))
+	bodyHash := sha256.Sum256(body)
+	message := responseSignatureMessage(resp.StatusCode, http.MethodGet, path, resp.Header.Get(headerServerTimestamp), bodyHash[:])
+	if !ed25519.Verify(pub, message, sig) {
+		t.Errorf("GET %s: signature does not verify over the client path", path)
+	}
+	return resp, body
+}
+
+func TestProxiedResponseSignedOverClientPath(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signingKey, signingKeyID = priv, publicKeyID(pub)
+
+	resp, body := verifySignedResponse(t, pub, "/apk/operations")
+	if resp.StatusCode != http.StatusOK || string(body) != `{"path":"/operations"}` {
+		t.Errorf("got %d %s, want the backend response", resp.StatusCode, body)
+	}
+}
+
+func TestUnknownRouteKeepsNotFound(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signingKey, signingKeyID = priv, publicKeyID(pub)
+
+	resp, body := verifySignedResponse(t, pub, "/nope")
+	if resp.StatusCode != http.StatusNotFound || string(body) != "404 page not found" {
+		t.Errorf("got %d %q, want 404 with gin's default body", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// --- 分块流式加密 ---
+//
+// 大响应不再整体加密，而是切成固定大小的块逐块 AES-GCM 加密并立即发送。
+// 流格式 (corn-aead-v1)：
+//
+//	header: version(1) | salt(8) | noncePrefix(7)
+//	frame:  length(4, big-endian) | ciphertext(length)
+//
+// 每块的 nonce = noncePrefix(7) | counter(4, big-endian) | final(1)。
+// 最后一块的 final 标志为 1，客户端据此判断流是否被截断；
+// 块序号参与 nonce，因此块被重排或重放都会导致认证失败。
+
+const (
+	streamFormatVersion = 1
+	streamFormatName    = "corn-aead-v1"
+	streamChunkSize     = 64 * 1024 // 每块明文的最大字节数
+	streamPrefixSize    = 7
+)
+
+var errStreamClosed = errors.New("encrypted stream already closed")
+
+// encryptedStreamWriter 将写入的明文按块加密后写入底层 Writer
+type encryptedStreamWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+	aead    cipher.AEAD
+	prefix  [streamPrefixSize]byte
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+// newEncryptedStreamWriter 派生密钥并写出流头部，密钥派生方式与 encrypt 一致
+func newEncryptedStreamWriter(w io.Writer, password []byte) (*encryptedStreamWriter, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	derivedKey := pbkdf2.Key(password, salt, pbkdf2Iterations, 32, sha256.New)
+	block, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	sw := &encryptedStreamWriter{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, streamChunkSize),
+	}
+	if f, ok := w.(http.Flusher); ok {
+		sw.flusher = f
+	}
+	if _, err := io.ReadFull(rand.Reader, sw.prefix[:]); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, 1+saltSize+streamPrefixSize)
+	header = append(header, streamFormatVersion)
+	header = append(header, salt...)
+	header = append(header, sw.prefix[:]...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// Write 缓存明文，凑满一块后立即加密发送
+// 缓冲区恰好满时先不发送，保证 Close 时最后一块总能带上 final 标志
+func (sw *encryptedStreamWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, errStreamClosed
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(sw.buf) == streamChunkSize {
+			if err := sw.writeChunk(sw.buf, false); err != nil {
+				return written, err
+			}
+			sw.buf = sw.buf[:0]
+		}
+		n := min(streamChunkSize-len(sw.buf), len(p))
+		sw.buf = append(sw.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close 发送带 final 标志的最后一块（可能为空），之后不能再写入
+func (sw *encryptedStreamWriter) Close() error {
+	if sw.closed {
+		return errStreamClosed
+	}
+	sw.closed = true
+	return sw.writeChunk(sw.buf, true)
+}
+
+func (sw *encryptedStreamWriter) writeChunk(plaintext []byte, final bool) error {
+	if sw.counter == math.MaxUint32 {
+		return errors.New("encrypted stream chunk counter overflow")
+	}
+
+	nonce := make([]byte, sw.aead.NonceSize())
+	copy(nonce, sw.prefix[:])
+	binary.BigEndian.PutUint32(nonce[streamPrefixSize:], sw.counter)
+	if final {
+		nonce[len(nonce)-1] = 1
+	}
+	sw.counter++
+
+	frame := make([]byte, 4, 4+len(plaintext)+sw.aead.Overhead())
+	frame = sw.aead.Seal(frame, nonce, plaintext, nil)
+	binary.BigEndian.PutUint32(frame[:4], uint32(len(frame)-4))
+	if _, err := sw.w.Write(frame); err != nil {
+		return err
+	}
+
+	if sw.flusher != nil {
+		sw.flusher.Flush()
+	}
+	return nil
+}
+
+// startEncryptedStream 写出流式响应头并返回加密 Writer，调用后响应状态码固定为 200
+// 流开始后的错误无法再以 JSON 返回，处理函数应直接中断，客户端会因缺少 final 块而判定失败
+func startEncryptedStream(c *gin.Context) (*encryptedStreamWriter, error) {
+	longTermKey := c.GetString("longTermKey")
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("X-Stream-Format", streamFormatName)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	return newEncryptedStreamWriter(c.Writer, []byte(longTermKey))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ElieenAndBella/corn_server/client"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// streamTestClient 返回一个 SDK 客户端，其导出接口返回 body(明文) 生成的加密流
+func streamTestClient(t *testing.T, body func(t *testing.T) []byte) *client.Client {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authenticate", func(w http.ResponseWriter, r *http.Request) {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": r.Header.Get("X-Token"),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte("test"))
+		w.Header().Set("server-timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+		json.NewEncoder(w).Encode(map[string]string{"jwt": token})
+	})
+	mux.HandleFunc("/api/activities/export", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body(t))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	c, err := client.New(client.Config{BaseURL: srv.URL, Key: testLongTermKey, Def: testClientDef, IntegritySecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// encryptStream 用 encryptedStreamWriter 加密 activities，close 为 false 时不发送 final 块
+func encryptStream(t *testing.T, activities []Activity, close bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	sw, err := newEncryptedStreamWriter(&buf, []byte(testLongTermKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := json.NewEncoder(sw)
+	for _, act := range activities {
+		if err := enc.Encode(act); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if close {
+		if err := sw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := sw.Close(); !errors.Is(err, errStreamClosed) {
+			t.Errorf("second Close = %v, want errStreamClosed", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// largeActivities 每条约 10KB，共跨越多个 64KB 的块
+func largeActivities() []Activity {
+	activities := make([]Activity, 20)
+	for i := range activities {
+		activities[i] = Activity{ActivityID: i + 1, Title: strings.Repeat("x", 10*1024)}
+	}
+	return activities
+}
+
+func exportCount(c *client.Client) (int, error) {
+	n := 0
+	err := c.ExportActivities(context.Background(), func(client.Activity) error { n++; return nil })
+	return n, err
+}
+
+func TestEncryptedStreamRoundTrip(t *testing.T) {
+	activities := largeActivities()
+
+	for _, tc := range []struct {
+		name       string
+		activities []Activity
+	}{
+		{"several chunks", activities},
+		{"empty", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := streamTestClient(t, func(t *testing.T) []byte { return encryptStream(t, tc.activities, true) })
+			n, err := exportCount(c)
+			if err != nil || n != len(tc.activities) {
+				t.Errorf("exported %d activities, err %v; want %d", n, err, len(tc.activities))
+			}
+		})
+	}
+}
+
+func TestEncryptedStreamRejectsTruncatedAndTampered(t *testing.T) {
+	activities := largeActivities()
+
+	t.Run("missing final frame", func(t *testing.T) {
+		c := streamTestClient(t, func(t *testing.T) []byte { return encryptStream(t, activities, false) })
+		if _, err := exportCount(c); !errors.Is(err, client.ErrTruncatedStream) {
+			t.Errorf("err = %v, want ErrTruncatedStream", err)
+		}
+	})
+
+	t.Run("cut mid-frame", func(t *testing.T) {
+		c := streamTestClient(t, func(t *testing.T) []byte {
+			data := encryptStream(t, activities, true)
+			return data[:len(data)-100]
+		})
+		if _, err := exportCount(c); !errors.Is(err, client.ErrTruncatedStream) {
+			t.Errorf("err = %v, want ErrTruncatedStream", err)
+		}
+	})
+
+	t.Run("tampered frame", func(t *testing.T) {
+		c := streamTestClient(t, func(t *testing.T) []byte {
+			data := encryptStream(t, activities, true)
+			data[len(data)-1] ^= 0xff
+			return data
+		})
+		if _, err := exportCount(c); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+			t.Errorf("err = %v, want authentication failure", err)
+		}
+	})
+}
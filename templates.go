@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- 统计报告模板 ---
+//
+// 报告模板以文件形式保存在 templates/ 下并嵌入二进制；TEMPLATES_DIR 指定的目录中的同名文件覆盖内置版本。
+// 加载时用 html/template（注册了 comma 等函数）解析，并以 reportTemplateSamples 中的样例数据试渲染一次，
+// 字段名写错、类型不符的模板会让加载失败：启动时拒绝启动，运行中重新加载时继续使用上一份模板。
+// 每个模板以内容的 SHA-256 前缀作为版本号，网关 template 目标通过 ETag 与 X-Template-Version 下发，
+// 客户端携带 If-None-Match 时版本未变化则返回 304，客户端可以长期缓存模板。
+
+const (
+	templateExt           = ".html"
+	templateVersionLength = 16
+
+	templateVersionHeader = "X-Template-Version"
+)
+
+//go:embed templates/*.html
+var embeddedTemplates embed.FS
+
+// templateFuncs 模板中可以使用的函数
+var templateFuncs = template.FuncMap{
+	"comma": comma,
+}
+
+// comma 为数字加上千分位分隔符，如 1234567 -> 1,234,567
+func comma(v any) (string, error) {
+	var s string
+	switch n := v.(type) {
+	case int:
+		s = strconv.Itoa(n)
+	case int32:
+		s = strconv.FormatInt(int64(n), 10)
+	case int64:
+		s = strconv.FormatInt(n, 10)
+	case float64:
+		s = strconv.FormatFloat(n, 'f', -1, 64)
+	case string:
+		if _, err := strconv.ParseFloat(n, 64); err != nil {
+			return "", fmt.Errorf("comma: %q is not a number", n)
+		}
+		s = n
+	default:
+		return "", fmt.Errorf("comma: unsupported type %T", v)
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i:]
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, ch := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(ch)
+	}
+	b.WriteString(frac)
+	return b.String(), nil
+}
+
+// reportTemplateSamples 每个模板的样例数据，加载时用于试渲染；新增模板必须同时提供样例
+var reportTemplateSamples = map[string]func() ReportData{
+	"farm": func() ReportData {
+		return ReportData{User: sampleReportUser(), Ecd: &CornFarmReport{
+			PopcornTotal: 1234567, TaskNum: 12, CsdGained: 30, CornGained: 45, PopcornGained: 200, GeneratedAt: "2024-01-02 15:04:05",
+		}}
+	},
+	"round": func() ReportData {
+		return ReportData{User: sampleReportUser(), Ed: &RoundReport{
+			PopcornTotal: 1234567, RoundCount: 8, RealRoundCount: 6, PopcornGained: 120, DrawCount: 18,
+			AveragePerDraw: 6.67, DurationMinutes: 3, GeneratedAt: "2024-01-02 15:04:05",
+			Rewards: []RoundReward{{Name: "5Q币", Source: "万能转盘"}},
+		}}
+	},
+	"game": func() ReportData {
+		return ReportData{User: sampleReportUser(), Egd: &MiniGameReport{
+			PopcornTotal: 1234567, GameNum: 5, JklGained: 10, CsdGained: 20, PopcornGained: 50, GeneratedAt: "2024-01-02 15:04:05",
+		}}
+	},
+	"lottery": func() ReportData {
+		return ReportData{User: sampleReportUser(), Eld: &LotteryReport{
+			LD:          []LotteryPrize{{Pic: "//img.3839.com/prize.png", Name: "奖券", Num: 3}},
+			GeneratedAt: "2024-01-02 15:04:05",
+		}}
+	},
+}
+
+func sampleReportUser() ReportUser {
+	var u ReportUser
+	u.Result.Data.BaseInfo.Avatar = "https://img.3839.com/avatar.png"
+	u.Result.Data.BaseInfo.NickName = "sample"
+	u.Result.Data.BaseInfo.UserAchievement.ShowCollect.Icon = "https://img.3839.com/icon.png"
+	return u
+}
+
+// reportTemplate 一个已解析并通过校验的模板
+type reportTemplate struct {
+	Name    string
+	Source  string // 模板原文，下发给客户端
+	Version string // 内容哈希
+	Origin  string // embedded 或覆盖文件的路径
+	tmpl    *template.Template
+}
+
+// ETag 模板的 HTTP 实体标签
+func (t *reportTemplate) ETag() string {
+	return `"` + t.Version + `"`
+}
+
+// Execute 用 data 渲染模板
+func (t *reportTemplate) Execute(data ReportData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// templateStore 模板快照，加载后只读
+type templateStore struct {
+	templates map[string]*reportTemplate
+	dir       string
+	loadedAt  time.Time
+}
+
+var reportTemplates atomic.Pointer[templateStore]
+
+// currentTemplateStore 当前模板；尚未加载时使用内置模板
+func currentTemplateStore() *templateStore {
+	if store := reportTemplates.Load(); store != nil {
+		return store
+	}
+	store, err := loadTemplateStore("")
+	if err != nil {
+		log.Fatalf("内置报告模板无效: %v", err)
+	}
+	reportTemplates.CompareAndSwap(nil, store)
+	return reportTemplates.Load()
+}
+
+// lookupTemplate 按名称查找当前模板
+func lookupTemplate(name string) (*reportTemplate, bool) {
+	t, ok := currentTemplateStore().templates[name]
+	return t, ok
+}
+
+// loadTemplateStore 加载内置模板，并用 dir 中的同名文件覆盖（dir 为空时只使用内置模板）
+func loadTemplateStore(dir string) (*templateStore, error) {
+	sources := make(map[string]string)
+	origins := make(map[string]string)
+
+	embedded, err := fs.Glob(embeddedTemplates, "templates/*"+templateExt)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range embedded {
+		data, err := embeddedTemplates.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(file), templateExt)
+		sources[name], origins[name] = string(data), "embedded"
+	}
+
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+		overrides, err := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range overrides {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			name := strings.TrimSuffix(filepath.Base(file), templateExt)
+			sources[name], origins[name] = string(data), file
+		}
+	}
+
+	store := &templateStore{templates: make(map[string]*reportTemplate, len(sources)), dir: dir, loadedAt: time.Now()}
+	for name, source := range sources {
+		t, err := parseReportTemplate(name, source)
+		if err != nil {
+			return nil, fmt.Errorf("%s (%s): %w", name, origins[name], err)
+		}
+		t.Origin = origins[name]
+		store.templates[name] = t
+	}
+	return store, nil
+}
+
+// parseReportTemplate 解析模板并用样例数据试渲染
+func parseReportTemplate(name, source string) (*reportTemplate, error) {
+	sample, ok := reportTemplateSamples[name]
+	if !ok {
+		return nil, errors.New("no sample data registered for this template")
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(source))
+	t := &reportTemplate{Name: name, Source: source, Version: hex.EncodeToString(sum[:])[:templateVersionLength], tmpl: tmpl}
+	if _, err := t.Execute(sample()); err != nil {
+		return nil, fmt.Errorf("render sample: %w", err)
+	}
+	return t, nil
+}
+
+// reloadTemplates 重新加载 TEMPLATES_DIR，失败时保留当前模板
+func reloadTemplates() error {
+	store, err := loadTemplateStore(templatesDir)
+	if err != nil {
+		return err
+	}
+	previous := reportTemplates.Swap(store)
+	if previous != nil {
+		for name, t := range store.templates {
+			if old, ok := previous.templates[name]; !ok || old.Version != t.Version {
+				log.Printf("报告模板 %s 已更新为版本 %s (%s)", name, t.Version, t.Origin)
+			}
+		}
+	}
+	log.Printf("已加载 %d 个报告模板", len(store.templates))
+	return nil
+}
+
+// serveTemplateSource 网关 template 目标的返回值：模板原文
+// 请求的 If-None-Match 与当前版本一致时直接返回 304 并返回 false
+func serveTemplateSource(c *gin.Context, name string) (any, bool) {
+	t, ok := lookupTemplate(name)
+	if !ok {
+		respondErrorCode(c, http.StatusInternalServerError, msgInvalidTargetDefinition)
+		return nil, false
+	}
+	c.Header("ETag", t.ETag())
+	c.Header(templateVersionHeader, t.Version)
+	if match := c.GetHeader("If-None-Match"); match == t.ETag() || match == t.Version {
+		c.AbortWithStatus(http.StatusNotModified)
+		return nil, false
+	}
+	return t.Source, true
+}
+
+// templateSummary 管理接口展示的模板概要
+type templateSummary struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Origin  string `json:"origin"`
+	Size    int    `json:"size"`
+}
+
+// summary 按名称列出全部模板
+func (s *templateStore) summary() []templateSummary {
+	out := make([]templateSummary, 0, len(s.templates))
+	for _, t := range s.templates {
+		out = append(out, templateSummary{Name: t.Name, Version: t.Version, Origin: t.Origin, Size: len(t.Source)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestComma(t *testing.T) {
+	cases := map[any]string{
+		0:              "0",
+		999:            "999",
+		1000:           "1,000",
+		int64(1234567): "1,234,567",
+		-1234567:       "-1,234,567",
+		1234.5:         "1,234.5",
+		"9876543":      "9,876,543",
+	}
+	for in, want := range cases {
+		if got, err := comma(in); err != nil || got != want {
+			t.Errorf("comma(%v) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := comma("abc"); err == nil {
+		t.Error("comma should reject non-numeric strings")
+	}
+}
+
+func TestEmbeddedTemplatesRenderSamples(t *testing.T) {
+	store, err := loadTemplateStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name := range reportTemplateSamples {
+		tpl, ok := store.templates[name]
+		if !ok {
+			t.Errorf("template %s is not embedded", name)
+			continue
+		}
+		if len(tpl.Version) != templateVersionLength || tpl.Origin != "embedded" {
+			t.Errorf("template %s: version %q, origin %q", name, tpl.Version, tpl.Origin)
+		}
+	}
+	html, err := store.templates["farm"].Execute(reportTemplateSamples["farm"]())
+	if err != nil || !strings.Contains(html, "1,234,567") {
+		t.Errorf("farm template did not render the formatted total: %v", err)
+	}
+}
+
+func TestTemplateDirOverrides(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("game.html", `<p>{{.User.Result.Data.BaseInfo.NickName}} {{comma .Egd.PopcornTotal}}</p>`)
+	store, err := loadTemplateStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builtin, _ := loadTemplateStore("")
+	if got := store.templates["game"]; got.Origin != filepath.Join(dir, "game.html") || got.Version == builtin.templates["game"].Version {
+		t.Errorf("game template was not overridden: %+v", got)
+	}
+	if store.templates["farm"].Origin != "embedded" {
+		t.Error("templates without an override should stay embedded")
+	}
+
+	// 字段名写错、引用了其它报告的数据或没有样例数据的模板都无法加载
+	for name, content := range map[string]string{
+		"game.html":    `{{.Egd.PopcornTotl}}`,
+		"round.html":   `{{.Ecd.TaskNum}}`,
+		"unknown.html": `<p></p>`,
+	} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadTemplateStore(dir); err == nil {
+			t.Errorf("%s: invalid template was accepted", name)
+		}
+	}
+}
+
+func TestTemplateTargetNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tpl, _ := lookupTemplate("round")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/lucy", nil)
+	c.Request.Header.Set("If-None-Match", tpl.ETag())
+	if _, ok := serveTemplateSource(c, "round"); ok || w.Code != http.StatusNotModified {
+		t.Errorf("matching If-None-Match: ok %v, status %d; want 304", ok, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/lucy", nil)
+	c.Request.Header.Set("If-None-Match", `"stale"`)
+	source, ok := serveTemplateSource(c, "round")
+	if !ok || source != tpl.Source || w.Header().Get(templateVersionHeader) != tpl.Version {
+		t.Errorf("stale If-None-Match should return the template with its version")
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// --- 用量计量与每日配额 ---
+//
+// 每个 Key 每天每个功能的用量记录在 Redis Hash usage:<日期>:<Key> 中（按北京时间划分日期），
+// 由 rollupUsage 定时汇总到 Postgres 的 usage_daily 表。
+// 配额按套餐在配置的 limits.quotas 中设置，随配置重新加载生效；
+// 路由级功能在 routePermissionRegistry 的 meter 中登记并由 permissionMiddleware 扣减，网关目标由处理函数调用 consumeQuota。
+// 请求最终以 4xx/5xx 结束时扣减的用量会被退还。
+
+const (
+	featureApkBuild  = "apk_build"
+	featureBoxSearch = "box_search"
+	featureRound     = "round"
+	featureLottery   = "lottery"
+	featureExport    = "export"
+	featureReport    = "report"
+
+	usageDateLayout = "2006-01-02"
+	// usageRetention Redis 中用量计数的保留时间，汇总任务需在此期间内完成
+	usageRetention = 3 * 24 * time.Hour
+)
+
+// meteredFeatures 全部计量的功能
+var meteredFeatures = []string{featureApkBuild, featureBoxSearch, featureRound, featureLottery, featureExport, featureReport}
+
+// consumeQuotaScript 未超出上限时计数加一，返回 {是否允许, 当前用量}
+var consumeQuotaScript = redis.NewScript(`
+local limit = tonumber(ARGV[2])
+local used = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+if limit >= 0 and used >= limit then
+	return {0, used}
+end
+used = redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {1, used}
+`)
+
+// refundQuotaScript 撤销一次计数，不会减到 0 以下
+var refundQuotaScript = redis.NewScript(`
+local used = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+if used > 0 then
+	return redis.call('HINCRBY', KEYS[1], ARGV[1], -1)
+end
+return 0
+`)
+
+// quotaCounter 每日用量计数的存储，默认使用 Redis；测试中替换为内存实现
+type quotaCounter interface {
+	// consume 未超出 limit（-1 表示不限量）时计数加一，返回是否允许与当前用量
+	consume(key, feature string, limit int64) (allowed bool, used int64, err error)
+	refund(key, feature string) error
+}
+
+type redisQuotaCounter struct{}
+
+func (redisQuotaCounter) consume(key, feature string, limit int64) (bool, int64, error) {
+	res, err := consumeQuotaScript.Run(ctx, swordRdb, []string{key}, feature, limit, usageRetention.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(res) != 2 {
+		return false, 0, fmt.Errorf("unexpected script result %v", res)
+	}
+	return res[0] == 1, res[1], nil
+}
+
+func (redisQuotaCounter) refund(key, feature string) error {
+	return refundQuotaScript.Run(ctx, swordRdb, []string{key}, feature).Err()
+}
+
+var usageCounter quotaCounter = redisQuotaCounter{}
+
+// quotaCharge 本次请求已扣减的一次用量，请求失败时由 refundFailedQuota 退还
+type quotaCharge struct {
+	key     string
+	feature string
+}
+
+const quotaChargesContextKey = "quotaCharges"
+
+func quotaCharges(c *gin.Context) []quotaCharge {
+	v, _ := c.Get(quotaChargesContextKey)
+	charges, _ := v.([]quotaCharge)
+	return charges
+}
+
+func usageRedisKey(day time.Time, longTermKey string) string {
+	return fmt.Sprintf("usage:%s:%s", day.Format(usageDateLayout), longTermKey)
+}
+
+// quotaFor 返回套餐下某功能的每日上限，-1 表示不限量
+func quotaFor(plan, feature string) int64 {
+	all := currentConfig().Limits.Quotas
+	quotas, ok := all[plan]
+	if !ok {
+		quotas = all[defaultPlan]
+	}
+	if limit, ok := quotas[feature]; ok {
+		return limit
+	}
+	return -1
+}
+
+// consumeQuota 记录一次功能使用；超出当日配额时写出 429 并返回 false
+// Redis 出错时放行，避免计量故障导致整个服务不可用
+func consumeQuota(c *gin.Context, feature string) bool {
+	longTermKey := c.GetString("longTermKey")
+	plan := defaultPlan
+	if record := currentKeyRecord(c); record != nil {
+		plan = record.Plan()
+	}
+	limit := quotaFor(plan, feature)
+
+	now := time.Now()
+	redisKey := usageRedisKey(now, longTermKey)
+	allowed, used, err := usageCounter.consume(redisKey, feature, limit)
+	if err != nil {
+		log.Printf("用量计数 %s 失败，已放行: %v", feature, err)
+		return true
+	}
+	if allowed {
+		c.Set(quotaChargesContextKey, append(quotaCharges(c), quotaCharge{key: redisKey, feature: feature}))
+	}
+	if limit < 0 {
+		return true
+	}
+
+	c.Header("X-Quota-Limit", strconv.FormatInt(limit, 10))
+	c.Header("X-Quota-Remaining", strconv.FormatInt(max(limit-used, 0), 10))
+	if allowed {
+		return true
+	}
+
+	year, month, day := now.Date()
+	tomorrow := time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+	c.Header("Retry-After", strconv.FormatInt(int64(tomorrow.Sub(now).Seconds())+1, 10))
+	log.Printf("Key '%s' 已用完今日 %s 配额 (%d, 套餐 %s)", longTermKey, feature, limit, plan)
+	respondErrorCode(c, http.StatusTooManyRequests, msgQuotaExceeded)
+	c.Abort()
+	return false
+}
+
+// refundFailedQuota 请求以 4xx/5xx 结束时退还本次扣减的用量，参数错误或服务器故障不占用配额
+// 由 permissionMiddleware 在处理函数返回后调用；流式响应开始后状态码固定为 200，不会退还
+func refundFailedQuota(c *gin.Context) {
+	if c.Writer.Status() < http.StatusBadRequest {
+		return
+	}
+	for _, charge := range quotaCharges(c) {
+		if err := usageCounter.refund(charge.key, charge.feature); err != nil {
+			log.Printf("退还 %s 用量失败: %v", charge.feature, err)
+		}
+	}
+	c.Set(quotaChargesContextKey, []quotaCharge(nil))
+}
+
+// todayUsage 读取 Key 今天各功能的实时用量
+func todayUsage(longTermKey string) (map[string]int64, error) {
+	raw, err := swordRdb.HGetAll(ctx, usageRedisKey(time.Now(), longTermKey)).Result()
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]int64, len(raw))
+	for feature, v := range raw {
+		n, _ := strconv.ParseInt(v, 10, 64)
+		usage[feature] = n
+	}
+	return usage, nil
+}
+
+// rollupUsage 将昨天与今天的 Redis 用量写入 usage_daily；重复执行是幂等的
+func rollupUsage() {
+	now := time.Now()
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+		if err := rollupUsageDay(day); err != nil {
+			log.Printf("汇总 %s 的用量失败: %v", day.Format(usageDateLayout), err)
+		}
+	}
+}
+
+func rollupUsageDay(day time.Time) error {
+	prefix := usageRedisKey(day, "")
+	var rows []UsageRecord
+
+	iter := swordRdb.Scan(ctx, 0, prefix+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		redisKey := iter.Val()
+		counts, err := swordRdb.HGetAll(ctx, redisKey).Result()
+		if err != nil {
+			return err
+		}
+		for feature, v := range counts {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				continue
+			}
+			rows = append(rows, UsageRecord{
+				Day:     day.Format(usageDateLayout),
+				UserKey: strings.TrimPrefix(redisKey, prefix),
+				Feature: feature,
+				Count:   n,
+			})
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if err := upsertUsageDaily(rows); err != nil {
+		return err
+	}
+	log.Printf("已汇总 %s 的用量，共 %d 条", day.Format(usageDateLayout), len(rows))
+	return nil
+}
+
+// getUsageHandler 返回当前 Key 的套餐、今日实时用量、配额以及近 30 天的历史用量
+func getUsageHandler(c *gin.Context) {
+	longTermKey := c.GetString("longTermKey")
+	plan := currentKeyRecord(c).Plan()
+
+	today, err := todayUsage(longTermKey)
+	if err != nil {
+		log.Printf("读取 Key '%s' 今日用量失败: %v", longTermKey, err)
+		respondErrorCode(c, http.StatusInternalServerError, msgUsageFetchFailed)
+		return
+	}
+
+	quotas := make(map[string]int64)
+	for _, feature := range meteredFeatures {
+		if limit := quotaFor(plan, feature); limit >= 0 {
+			quotas[feature] = limit
+		}
+	}
+
+	from := time.Now().AddDate(0, 0, -30).Format(usageDateLayout)
+	to := time.Now().Format(usageDateLayout)
+	history, err := getUsageDaily(longTermKey, from, to)
+	if err != nil {
+		log.Printf("读取 Key '%s' 历史用量失败: %v", longTermKey, err)
+		respondErrorCode(c, http.StatusInternalServerError, msgUsageFetchFailed)
+		return
+	}
+
+	respondEncrypted(c, http.StatusOK, UsageReport{Plan: plan, Today: today, Quotas: quotas, History: history})
+}
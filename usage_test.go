@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type memoryQuotaCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (m *memoryQuotaCounter) consume(key, feature string, limit int64) (bool, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	used := m.counts[key+"/"+feature]
+	if limit >= 0 && used >= limit {
+		return false, used, nil
+	}
+	m.counts[key+"/"+feature] = used + 1
+	return true, used + 1, nil
+}
+
+func (m *memoryQuotaCounter) refund(key, feature string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counts[key+"/"+feature] > 0 {
+		m.counts[key+"/"+feature]--
+	}
+	return nil
+}
+
+func (m *memoryQuotaCounter) used(feature string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for k, v := range m.counts {
+		if strings.HasSuffix(k, "/"+feature) {
+			n += v
+		}
+	}
+	return n
+}
+
+// newMeteredRouter 与 permissionMiddleware 相同地扣减并退还用量，处理函数按 ?status= 返回
+func newMeteredRouter(t *testing.T) (*gin.Engine, *memoryQuotaCounter) {
+	t.Helper()
+	counter := &memoryQuotaCounter{counts: map[string]int64{}}
+	old := usageCounter
+	usageCounter = counter
+	t.Cleanup(func() { usageCounter = old })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/metered", func(c *gin.Context) {
+		c.Set("longTermKey", "k")
+		if !consumeQuota(c, featureApkBuild) {
+			return
+		}
+		c.Next()
+		refundFailedQuota(c)
+	}, func(c *gin.Context) {
+		status, _ := strconv.Atoi(c.Query("status"))
+		c.JSON(status, gin.H{})
+	})
+	return router, counter
+}
+
+func meteredRequest(router *gin.Engine, status int) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metered?status="+strconv.Itoa(status), nil))
+	return w
+}
+
+func TestQuotaRefundedOnFailure(t *testing.T) {
+	router, counter := newMeteredRouter(t)
+
+	for _, status := range []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusBadGateway} {
+		if w := meteredRequest(router, status); w.Code != status {
+			t.Fatalf("got %d, want %d", w.Code, status)
+		}
+		if n := counter.used(featureApkBuild); n != 0 {
+			t.Errorf("status %d: used = %d, want the charge refunded", status, n)
+		}
+	}
+
+	meteredRequest(router, http.StatusOK)
+	if n := counter.used(featureApkBuild); n != 1 {
+		t.Errorf("used = %d after a successful request, want 1", n)
+	}
+}
+
+func TestQuotaExhausted(t *testing.T) {
+	router, counter := newMeteredRouter(t)
+	limit := quotaFor(defaultPlan, featureApkBuild)
+
+	for i := int64(0); i < limit; i++ {
+		w := meteredRequest(router, http.StatusOK)
+		if w.Code != http.StatusOK || w.Header().Get("X-Quota-Remaining") != strconv.FormatInt(limit-i-1, 10) {
+			t.Fatalf("request %d: got %d, remaining %q", i, w.Code, w.Header().Get("X-Quota-Remaining"))
+		}
+	}
+
+	w := meteredRequest(router, http.StatusOK)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("got %d, want 429 with Retry-After", w.Code)
+	}
+	// 被拒绝的请求既不计数也不退还已有的用量
+	if n := counter.used(featureApkBuild); n != limit {
+		t.Errorf("used = %d, want %d", n, limit)
+	}
+}
+
+func TestQuotaFor(t *testing.T) {
+	if got := quotaFor("pro", featureExport); got != -1 {
+		t.Errorf("pro export = %d, want unlimited", got)
+	}
+	if got, want := quotaFor("unknown-plan", featureReport), currentConfig().Limits.Quotas[defaultPlan][featureReport]; got != want {
+		t.Errorf("unknown plan report = %d, want default %d", got, want)
+	}
+}
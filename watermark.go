@@ -0,0 +1,338 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"math"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- 下发配置水印 ---
+//
+// 网关下发给每个 Key 的配置在格式允许的地方嵌入该 Key 独有、可复算的标记，
+// 配置出现在第三方工具中时，管理员可通过 POST /admin/watermark/identify 找出泄露的 Key。
+// 可用的通道（WATERMARK_CHANNELS，逗号分隔，默认关闭）：
+//   - query: URL 追加查询参数 _=<数字>，每个 URL 的数字不同，单个即可定位 Key（32 比特）；
+//   - regex: 正则中的 * + ? 随机改写为等价的 {0,} {1,} {0,1}，每个量词 1 比特；
+//   - order: 打乱与顺序无关的列表，n 个元素约 log2(n!) 比特。
+// 密钥（oh、going）本身无法改写，只能通过同一响应中的其它配置追溯。
+// 目标使用哪个通道由网关注册表中的 watermark 字段声明，见 gateway.go。
+// 标记由 HMAC(派生密钥, Key | 原值) 生成，不需要保存；识别时对 watermark:keys 中的每个 Key 复算比对。
+
+const (
+	watermarkChannelQuery = "query"
+	watermarkChannelRegex = "regex"
+	watermarkChannelOrder = "order"
+
+	watermarkQueryParam = "_"
+	watermarkKeysKey    = "watermark:keys"
+)
+
+// watermarkSeen 已写入 watermark:keys 的 Key，避免每个请求都访问 Redis
+var watermarkSeen sync.Map
+
+// watermarkEnabled 通道是否在 WATERMARK_CHANNELS 中开启
+func watermarkEnabled(channel string) bool {
+	return slices.Contains(currentConfig().Watermark.Channels, channel)
+}
+
+// watermarkDigest 标记的来源：HMAC(派生密钥, key | value)
+func watermarkDigest(key, value string) []byte {
+	mac := hmac.New(sha256.New, []byte(jwtSecretKey))
+	mac.Write([]byte("corn-watermark-v1|" + key + "|" + value))
+	return mac.Sum(nil)
+}
+
+// watermarkMarker URL 的查询参数标记，形如 _=1234567890
+func watermarkMarker(key, raw string) string {
+	n := binary.BigEndian.Uint32(watermarkDigest(key, raw))
+	return watermarkQueryParam + "=" + strconv.FormatUint(uint64(n), 10)
+}
+
+// watermarkURL 在 URL 末尾追加查询参数标记
+func watermarkURL(key, raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += watermarkMarker(key, raw)
+	return u.String()
+}
+
+// regexQuantifiers 正则中可以改写的量词（不在字符类内、未转义、不是 (? 语法或惰性修饰）的位置
+func regexQuantifiers(re string) []int {
+	var positions []int
+	inClass := false
+	for i := 0; i < len(re); i++ {
+		switch ch := re[i]; {
+		case ch == '\\':
+			i++
+		case inClass:
+			if ch == ']' {
+				inClass = false
+			}
+		case ch == '[':
+			inClass = true
+			// []a] 与 [^]a] 中紧跟的 ] 是普通字符
+			if i+1 < len(re) && re[i+1] == '^' {
+				i++
+			}
+			if i+1 < len(re) && re[i+1] == ']' {
+				i++
+			}
+		case ch == '{':
+			if end := strings.IndexByte(re[i:], '}'); end > 0 {
+				i += end
+				if i+1 < len(re) && re[i+1] == '?' {
+					i++
+				}
+			}
+		case ch == '*' || ch == '+' || ch == '?':
+			if i > 0 && re[i-1] == '(' {
+				continue
+			}
+			positions = append(positions, i)
+			if i+1 < len(re) && re[i+1] == '?' {
+				i++
+			}
+		}
+	}
+	return positions
+}
+
+var quantifierForms = map[byte]string{'*': "{0,}", '+': "{1,}", '?': "{0,1}"}
+
+// watermarkRegex 按标记比特把量词改写为等价的区间形式，匹配结果不变
+func watermarkRegex(key, re string) string {
+	positions := regexQuantifiers(re)
+	if len(positions) == 0 {
+		return re
+	}
+	digest := watermarkDigest(key, re)
+
+	var b strings.Builder
+	last := 0
+	for n, pos := range positions {
+		if digest[n/8%len(digest)]&(1<<(n%8)) == 0 {
+			continue
+		}
+		b.WriteString(re[last:pos])
+		b.WriteString(quantifierForms[re[pos]])
+		last = pos + 1
+	}
+	b.WriteString(re[last:])
+	return b.String()
+}
+
+// watermarkOrder 按标记确定性地打乱列表
+func watermarkOrder(key string, list []string) []string {
+	out := slices.Clone(list)
+	digest := watermarkDigest(key, strings.Join(list, "\x00"))
+	for i := len(out) - 1; i > 0; i-- {
+		j := int(digest[i%len(digest)]) % (i + 1)
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// watermarkValues 对字符串、列表或映射中的每个值应用通道
+func watermarkValues(key, channel string, data any) any {
+	apply := func(s string) string {
+		switch channel {
+		case watermarkChannelQuery:
+			return watermarkURL(key, s)
+		case watermarkChannelRegex:
+			return watermarkRegex(key, s)
+		}
+		return s
+	}
+
+	switch v := data.(type) {
+	case string:
+		return apply(v)
+	case []string:
+		if channel == watermarkChannelOrder {
+			return watermarkOrder(key, v)
+		}
+		out := make([]string, len(v))
+		for i, s := range v {
+			out[i] = apply(s)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(v))
+		for k, s := range v {
+			out[k] = apply(s)
+		}
+		return out
+	}
+	return data
+}
+
+// applyWatermark 为目标的返回值加水印（通道未开启时原样返回），并登记 Key 以便识别
+func applyWatermark(c *gin.Context, channel string, data any) any {
+	if !watermarkEnabled(channel) {
+		return data
+	}
+	key := c.GetString("longTermKey")
+	if _, seen := watermarkSeen.Load(key); !seen {
+		if err := swordRdb.SAdd(ctx, watermarkKeysKey, key).Err(); err != nil {
+			log.Printf("登记水印 Key 失败: %v", err)
+		} else {
+			watermarkSeen.Store(key, true)
+		}
+	}
+	return watermarkValues(key, channel, data)
+}
+
+// watermarkSource 网关注册表中一个加水印的目标的原始值
+type watermarkSource struct {
+	name    string
+	channel string
+	values  []string
+}
+
+// watermarkSources 从当前网关注册表收集所有声明了 watermark 的目标，按名称排序
+func watermarkSources() []watermarkSource {
+	var sources []watermarkSource
+	var collect func(name string, t *gatewayTarget)
+	collect = func(name string, t *gatewayTarget) {
+		for param, sub := range t.Params {
+			collect(name+":"+param, sub)
+		}
+		if t.Watermark == "" {
+			return
+		}
+		var values []string
+		switch t.Kind {
+		case gatewayKindString:
+			values = []string{t.String}
+		case gatewayKindList:
+			for _, item := range t.List {
+				values = append(values, item.Value)
+			}
+		case gatewayKindMap:
+			for _, v := range t.Map {
+				values = append(values, v)
+			}
+		}
+		if len(values) > 0 {
+			sources = append(sources, watermarkSource{name: name, channel: t.Watermark, values: values})
+		}
+	}
+	for endpoint, targets := range currentGatewayRegistry().targets {
+		for name, t := range targets {
+			collect(endpoint+"/"+name, t)
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].name < sources[j].name })
+	return sources
+}
+
+// sampleContains 泄露样本中是否出现 s（原样或 JSON 转义后）
+func sampleContains(sample, s string) bool {
+	if strings.Contains(sample, s) {
+		return true
+	}
+	escaped, _ := json.Marshal(s)
+	return strings.Contains(sample, strings.Trim(string(escaped), `"`))
+}
+
+// containsMarker 样本中是否出现完整的查询参数标记（其后不能紧跟数字）
+func containsMarker(sample, marker string) bool {
+	for {
+		i := strings.Index(sample, marker)
+		if i < 0 {
+			return false
+		}
+		sample = sample[i+len(marker):]
+		if sample == "" || sample[0] < '0' || sample[0] > '9' {
+			return true
+		}
+	}
+}
+
+// appearsInOrder 列表中的元素是否全部按顺序出现在样本中
+func appearsInOrder(sample string, list []string) bool {
+	pos := 0
+	for _, s := range list {
+		i := strings.Index(sample[pos:], s)
+		if i < 0 {
+			return false
+		}
+		pos += i + len(s)
+	}
+	return true
+}
+
+// watermarkEvidence 复算 key 的全部标记并与样本比对，返回匹配到的比特数与证据
+func watermarkEvidence(key, sample string) (int, []string) {
+	bits := 0
+	var evidence []string
+	seen := make(map[string]bool)
+
+	for _, src := range watermarkSources() {
+		values := src.values
+		switch src.channel {
+		case watermarkChannelQuery:
+			for _, raw := range values {
+				marker := watermarkMarker(key, raw)
+				if seen[marker] || !containsMarker(sample, marker) {
+					continue
+				}
+				seen[marker] = true
+				bits += 32
+				evidence = append(evidence, "query "+raw)
+			}
+		case watermarkChannelRegex:
+			for _, re := range values {
+				marked := watermarkRegex(key, re)
+				if marked == re || seen[marked] || !sampleContains(sample, marked) {
+					continue
+				}
+				seen[marked] = true
+				bits += len(regexQuantifiers(re))
+				evidence = append(evidence, "regex "+re)
+			}
+		case watermarkChannelOrder:
+			ordered := watermarkOrder(key, values)
+			if slices.Equal(ordered, values) || !appearsInOrder(sample, ordered) {
+				continue
+			}
+			lgamma, _ := math.Lgamma(float64(len(values) + 1))
+			bits += int(lgamma / math.Ln2)
+			evidence = append(evidence, "order "+src.name)
+		}
+	}
+	return bits, evidence
+}
+
+// identifyWatermark 对所有下发过水印的 Key 复算比对，按匹配比特数从高到低返回
+func identifyWatermark(sample string) ([]WatermarkMatch, error) {
+	keys, err := swordRdb.SMembers(ctx, watermarkKeysKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []WatermarkMatch
+	for _, key := range keys {
+		if bits, evidence := watermarkEvidence(key, sample); bits > 0 {
+			matches = append(matches, WatermarkMatch{Key: key, Bits: bits, Evidence: evidence})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Bits > matches[j].Bits })
+	return matches, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWatermarkRegexPreservesMatches(t *testing.T) {
+	patterns := currentConfig().Client.Patterns
+	inputs := map[string]string{
+		patterns.Extract:     "https://a.example/x?comm_id=42&b=1",
+		patterns.ExtractS:    `{"s": "abc"}`,
+		patterns.VarQuoted:   `var pageToken = 'tok123';`,
+		patterns.VarUnquoted: "var pageToken = 123;\n",
+		patterns.VarJSON:     `var pageToken = {"a":1};`,
+	}
+
+	changed := false
+	for re, input := range inputs {
+		for i := 0; i < 16; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			marked := watermarkRegex(key, re)
+			if marked != re {
+				changed = true
+			}
+			if watermarkRegex(key, re) != marked {
+				t.Fatalf("watermarkRegex(%q) is not deterministic", re)
+			}
+
+			want := regexp.MustCompile(strings.ReplaceAll(re, "%s", "pageToken")).FindStringSubmatch(input)
+			got := regexp.MustCompile(strings.ReplaceAll(marked, "%s", "pageToken")).FindStringSubmatch(input)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("%q -> %q changed the match: got %q, want %q", re, marked, got, want)
+			}
+		}
+	}
+	if !changed {
+		t.Error("no regex was ever watermarked")
+	}
+}
+
+func TestRegexQuantifiersSkipsSyntax(t *testing.T) {
+	// 字符类、转义、(?: 与惰性修饰都不是可改写的量词
+	got := regexQuantifiers(`(?:a+?)[*+?]\*b{2,3}?c?`)
+	if fmt.Sprint(got) != "[4 22]" {
+		t.Errorf("regexQuantifiers = %v, want [4 22]", got)
+	}
+}
+
+func TestWatermarkEvidence(t *testing.T) {
+	farmURLs := currentConfig().Upstream.FarmURLs
+	urls := watermarkValues("key-a", watermarkChannelQuery, farmURLs).([]string)
+	sample := strings.Join(urls, "\n")
+
+	bits, evidence := watermarkEvidence("key-a", sample)
+	if bits < 32 || len(evidence) == 0 {
+		t.Fatalf("leaking key not identified: bits=%d evidence=%v", bits, evidence)
+	}
+	if bits, _ := watermarkEvidence("key-b", sample); bits != 0 {
+		t.Errorf("unrelated key matched with %d bits", bits)
+	}
+	if bits, _ := watermarkEvidence("key-a", strings.Join(farmURLs, "\n")); bits != 0 {
+		t.Errorf("unwatermarked sample matched with %d bits", bits)
+	}
+}